@@ -0,0 +1,157 @@
+package amounts
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/build"
+)
+
+// Account is the part of the horizon account resource the funds of an
+// account are computed from. The liabilities and sponsorships are not part
+// of the horizon client.
+type Account struct {
+	SubentryCount int64     `json:"subentry_count"`
+	NumSponsoring int64     `json:"num_sponsoring"`
+	NumSponsored  int64     `json:"num_sponsored"`
+	Balances      []Balance `json:"balances"`
+}
+
+// Balance is a balance of an Account.
+type Balance struct {
+	Balance            string `json:"balance"`
+	SellingLiabilities string `json:"selling_liabilities"`
+	Type               string `json:"asset_type"`
+	Code               string `json:"asset_code"`
+	Issuer             string `json:"asset_issuer"`
+}
+
+// Funds are the balances an account can spend, net of the selling
+// liabilities of its open offers, and the minimum balance it should keep.
+type Funds struct {
+	Balances   map[build.Asset]int64
+	MinBalance int64
+}
+
+// NewFunds returns the funds of account, baseReserve being the reserve of
+// an entry of the ledger in stroops.
+func NewFunds(account Account, baseReserve int64) (*Funds, error) {
+	reserves := 2 + account.SubentryCount + account.NumSponsoring - account.NumSponsored
+	funds := &Funds{
+		Balances:   make(map[build.Asset]int64),
+		MinBalance: reserves * baseReserve,
+	}
+
+	for _, b := range account.Balances {
+		var asset build.Asset
+		switch b.Type {
+		case "native":
+			asset = build.NativeAsset()
+		case "credit_alphanum4", "credit_alphanum12":
+			asset = build.CreditAsset(b.Code, b.Issuer)
+		default:
+			continue
+		}
+
+		balance, err := amount.Parse(b.Balance)
+		if err != nil {
+			return nil, err
+		}
+
+		var selling int64
+		if b.SellingLiabilities != "" {
+			liabilities, err := amount.Parse(b.SellingLiabilities)
+			if err != nil {
+				return nil, err
+			}
+			selling = int64(liabilities)
+		}
+
+		funds.Balances[asset] = int64(balance) - selling
+	}
+
+	return funds, nil
+}
+
+// Spendable returns how much of asset can be sent in a transaction paying
+// fee: XLM also pays the fee and keeps the minimum balance.
+func (f *Funds) Spendable(asset build.Asset, fee int64) int64 {
+	b := f.Balances[asset]
+	if asset.Native {
+		b -= f.MinBalance + fee
+	}
+
+	if b < 0 {
+		return 0
+	}
+
+	return b
+}
+
+// Check returns an error if sending spent and paying fee would exceed the
+// balances, or push the XLM balance below the minimum balance.
+func (f *Funds) Check(spent map[build.Asset]int64, fee int64) error {
+	native := build.NativeAsset()
+	if xlm := spent[native]; xlm+fee > f.Spendable(native, 0) {
+		return fmt.Errorf("sending %s XLM and paying %s XLM of fees would leave the source account below its minimum balance of %s XLM, at most %s XLM can be sent",
+			amount.StringFromInt64(xlm), amount.StringFromInt64(fee), amount.StringFromInt64(f.MinBalance), amount.StringFromInt64(f.Spendable(native, fee)))
+	}
+
+	var credits []build.Asset
+	for asset := range spent {
+		if !asset.Native {
+			credits = append(credits, asset)
+		}
+	}
+	sort.Slice(credits, func(i, j int) bool {
+		return credits[i].Code+credits[i].Issuer < credits[j].Code+credits[j].Issuer
+	})
+
+	for _, asset := range credits {
+		if spent[asset] > f.Spendable(asset, fee) {
+			return fmt.Errorf("sending %s %s exceeds the %s %s the source account can spend",
+				amount.StringFromInt64(spent[asset]), asset.Code, amount.StringFromInt64(f.Spendable(asset, fee)), asset.Code)
+		}
+	}
+
+	return nil
+}
+
+// TransactionFee returns the fee of a transaction of ops operations.
+func TransactionFee(ops int) int64 {
+	return int64(build.DefaultBaseFee) * int64(ops)
+}
+
+// Request returns the amount of asset sent by req: its literal amount, or
+// the share of what can be spent for ALL, MAX and percentages.
+func (f *Funds) Request(req *parser.SendRequest, asset assets.Asset, fee int64) (string, error) {
+	if req.AmountKind == parser.AmountLiteralKind {
+		return req.Amount, nil
+	}
+
+	spendable := f.Spendable(asset.BuilderAsset, fee)
+	amt := spendable
+	if req.AmountKind == parser.AmountPercentKind {
+		pct, err := strconv.ParseFloat(req.Amount, 64)
+		if err != nil {
+			return "", err
+		}
+
+		// in hundredths of a percent, rounded down to the stroop
+		bps := big.NewInt(int64(math.Round(pct * 100)))
+		share := new(big.Int).Mul(big.NewInt(spendable), bps)
+		amt = share.Div(share, big.NewInt(10000)).Int64()
+	}
+
+	if amt <= 0 {
+		return "", fmt.Errorf("nothing to send, the source account can spend %s %s", amount.StringFromInt64(spendable), asset.CodeString())
+	}
+
+	return amount.StringFromInt64(amt), nil
+}
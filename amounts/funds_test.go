@@ -0,0 +1,156 @@
+package amounts
+
+import (
+	"testing"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/parser"
+	"github.com/stellar/go/build"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	baseReserve = 5000000
+	issuer      = "GA6HCMBLTZS5VYYBCATRBRZ3BZJMAFUDKYYF6AH6MVCMGWMRDNSWJPIH"
+)
+
+var (
+	native = build.NativeAsset()
+	mobi   = build.CreditAsset("MOBI", issuer)
+)
+
+// account holds 100 XLM and 50 MOBI, a tenth of each being sold by offers,
+// and needs 4 reserves.
+var account = Account{
+	SubentryCount: 3,
+	NumSponsoring: 1,
+	NumSponsored:  2,
+	Balances: []Balance{
+		{Balance: "100.0000000", SellingLiabilities: "10.0000000", Type: "native"},
+		{Balance: "50.0000000", SellingLiabilities: "5.0000000", Type: "credit_alphanum4", Code: "MOBI", Issuer: issuer},
+		{Balance: "12.0000000", Type: "liquidity_pool_shares"},
+	},
+}
+
+func TestNewFunds(t *testing.T) {
+	var tests = []struct {
+		name           string
+		account        Account
+		wantMinBalance int64
+	}{
+		{"no subentry", Account{}, 10000000},
+		{"subentries", Account{SubentryCount: 3}, 25000000},
+		{"sponsoring", Account{SubentryCount: 3, NumSponsoring: 2}, 35000000},
+		{"sponsored", Account{SubentryCount: 3, NumSponsored: 2}, 15000000},
+		{"all of them", account, 20000000},
+	}
+
+	for _, test := range tests {
+		funds, err := NewFunds(test.account, baseReserve)
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.wantMinBalance, funds.MinBalance, test.name)
+	}
+
+	// net of the selling liabilities
+	funds, err := NewFunds(account, baseReserve)
+	require.NoError(t, err)
+	require.Equal(t, map[build.Asset]int64{native: 900000000, mobi: 450000000}, funds.Balances)
+
+	_, err = NewFunds(Account{Balances: []Balance{{Balance: "a lot", Type: "native"}}}, baseReserve)
+	require.Error(t, err)
+	_, err = NewFunds(Account{Balances: []Balance{{Balance: "1", SellingLiabilities: "some", Type: "native"}}}, baseReserve)
+	require.Error(t, err)
+}
+
+func TestSpendable(t *testing.T) {
+	funds, err := NewFunds(account, baseReserve)
+	require.NoError(t, err)
+	poor := &Funds{Balances: map[build.Asset]int64{native: 10000000}, MinBalance: 15000000}
+
+	var tests = []struct {
+		name  string
+		funds *Funds
+		asset build.Asset
+		fee   int64
+		want  int64
+	}{
+		{"xlm", funds, native, 0, 880000000},
+		{"xlm paying the fee", funds, native, 100, 879999900},
+		{"credit asset, the fee is paid in xlm", funds, mobi, 100, 450000000},
+		{"not held", funds, build.CreditAsset("SLT", issuer), 100, 0},
+		{"below the minimum balance", poor, native, 100, 0},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.want, test.funds.Spendable(test.asset, test.fee), test.name)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	funds, err := NewFunds(account, baseReserve)
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name    string
+		spent   map[build.Asset]int64
+		fee     int64
+		wantErr string
+	}{
+		{"every xlm", map[build.Asset]int64{native: 879999800}, 200, ""},
+		{"a stroop over", map[build.Asset]int64{native: 879999801}, 200, "at most 87.9999800 XLM can be sent"},
+		{"the fee alone", nil, 880000001, "below its minimum balance of 2.0000000 XLM"},
+		{"every mobi", map[build.Asset]int64{mobi: 450000000}, 100, ""},
+		{"mobi sold by offers", map[build.Asset]int64{mobi: 450000001}, 100, "exceeds the 45.0000000 MOBI"},
+		{"both", map[build.Asset]int64{native: 100000000, mobi: 100000000}, 200, ""},
+	}
+
+	for _, test := range tests {
+		err := funds.Check(test.spent, test.fee)
+		if test.wantErr == "" {
+			require.NoError(t, err, test.name)
+		} else {
+			require.Error(t, err, test.name)
+			require.Contains(t, err.Error(), test.wantErr, test.name)
+		}
+	}
+}
+
+func TestRequest(t *testing.T) {
+	funds, err := NewFunds(account, baseReserve)
+	require.NoError(t, err)
+	poor := &Funds{Balances: map[build.Asset]int64{native: 10000000}, MinBalance: 15000000}
+
+	xlm := assets.Asset{BuilderAsset: native}
+	var tests = []struct {
+		name    string
+		funds   *Funds
+		req     parser.SendRequest
+		asset   assets.Asset
+		want    string
+		wantErr bool
+	}{
+		{"literal", funds, parser.SendRequest{Amount: "1000"}, xlm, "1000", false},
+		{"all", funds, parser.SendRequest{Amount: "ALL", AmountKind: parser.AmountAllKind}, xlm, "87.9999900", false},
+		{"all of a credit asset", funds, parser.SendRequest{Amount: "MAX", AmountKind: parser.AmountAllKind}, assets.Asset{BuilderAsset: mobi}, "45.0000000", false},
+		{"100%", funds, parser.SendRequest{Amount: "100", AmountKind: parser.AmountPercentKind}, xlm, "87.9999900", false},
+		{"50%", funds, parser.SendRequest{Amount: "50", AmountKind: parser.AmountPercentKind}, xlm, "43.9999950", false},
+		{"rounded down to the stroop", funds, parser.SendRequest{Amount: "33.33", AmountKind: parser.AmountPercentKind}, xlm, "29.3303966", false},
+		{"rounded to a hundredth of a percent", funds, parser.SendRequest{Amount: "0.005", AmountKind: parser.AmountPercentKind}, xlm, "0.0087999", false},
+		{"less than a hundredth of a percent", funds, parser.SendRequest{Amount: "0.004", AmountKind: parser.AmountPercentKind}, xlm, "", true},
+		{"below the minimum balance", poor, parser.SendRequest{Amount: "ALL", AmountKind: parser.AmountAllKind}, xlm, "", true},
+	}
+
+	for _, test := range tests {
+		got, err := test.funds.Request(&test.req, test.asset, 100)
+		if test.wantErr {
+			require.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		require.Equal(t, test.want, got, test.name)
+	}
+
+	// literal amounts are checked against the funds when sent
+	require.NoError(t, funds.Check(map[build.Asset]int64{native: 879999900}, 100))
+	require.Error(t, funds.Check(map[build.Asset]int64{native: 879999901}, 100))
+}
@@ -0,0 +1,36 @@
+// Package amounts computes the amounts sent by alfred: the share of a
+// balance an account can spend, and the most a path payment may cost.
+package amounts
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/stellar/go/amount"
+)
+
+// MaxSend returns quoted increased by slippage percent, rounded up to the
+// stroop.
+func MaxSend(quoted string, slippage float64) (string, error) {
+	if slippage < 0 || math.IsNaN(slippage) || math.IsInf(slippage, 0) {
+		return "", fmt.Errorf("max slippage should not be negative, got %v", slippage)
+	}
+
+	q, err := amount.Parse(quoted)
+	if err != nil {
+		return "", err
+	}
+
+	// in hundredths of a percent
+	bps := big.NewInt(int64(math.Round(slippage * 100)))
+	max := new(big.Int).Mul(big.NewInt(int64(q)), bps)
+	max.Add(max, big.NewInt(10000-1))
+	max.Div(max, big.NewInt(10000))
+	max.Add(max, big.NewInt(int64(q)))
+	if !max.IsInt64() {
+		return "", fmt.Errorf("%s with %v%% slippage is too large", quoted, slippage)
+	}
+
+	return amount.StringFromInt64(max.Int64()), nil
+}
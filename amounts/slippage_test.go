@@ -0,0 +1,44 @@
+package amounts
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxSend(t *testing.T) {
+	var tests = []struct {
+		quoted   string
+		slippage float64
+		want     string
+		wantErr  bool
+	}{
+		{"100", 1, "101.0000000", false},
+		{"100", 0, "100.0000000", false},
+		{"100", 100, "200.0000000", false},
+		{"100", 0.5, "100.5000000", false},
+		// rounded up to the stroop
+		{"0.0000001", 1, "0.0000002", false},
+		{"0.0000150", 1, "0.0000152", false},
+		{"10", 0.01, "10.0010000", false},
+		// hundredths of a percent, rounded to the nearest
+		{"100", 0.004, "100.0000000", false},
+		{"100", 0.005, "100.0100000", false},
+		{"100", -1, "", true},
+		{"100", math.NaN(), "", true},
+		{"100", math.Inf(1), "", true},
+		{"not a number", 1, "", true},
+		{"900000000000", 100, "", true},
+	}
+
+	for _, test := range tests {
+		got, err := MaxSend(test.quoted, test.slippage)
+		if test.wantErr {
+			require.Error(t, err, "%s with %v%%", test.quoted, test.slippage)
+			continue
+		}
+		require.NoError(t, err, "%s with %v%%", test.quoted, test.slippage)
+		require.Equal(t, test.want, got, "%s with %v%%", test.quoted, test.slippage)
+	}
+}
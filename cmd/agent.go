@@ -0,0 +1,165 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/celrenheit/alfred/wallet"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep the db unlocked for other commands",
+	Long: `The agent unlocks the db once and keeps it unlocked until it stays idle for --timeout or is locked with 'alfred agent lock'.
+Other commands use it automatically instead of asking for the password when no --secret is given. It listens on a Unix socket only accessible to the current user, and also serves signatures for the wallets of the db as a remote signer.`,
+	Example: `alfred agent &
+alfred agent --timeout 1h
+alfred agent lock`,
+	PreRunE: middlewares(checkDB, checkPassword),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		secret := viper.GetString("secret")
+		m, err := wallet.OpenSecretString(path, secret, dbOptions)
+		if err != nil {
+			fatal(err)
+		}
+
+		timeout := viper.GetDuration("agent-timeout")
+		agent, err := wallet.NewAgent(path, m, timeout)
+		if err != nil {
+			fatal(err)
+		}
+
+		l, err := wallet.ListenSigner(viper.GetString("agent"), nil)
+		if err != nil {
+			fatal(err)
+		}
+
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		go func() {
+			<-c
+			agent.Lock()
+		}()
+
+		fmt.Printf("agent listening on %s, locking after %s of inactivity\n", viper.GetString("agent"), timeout)
+		if err := agent.Serve(l); err != nil {
+			fatal(err)
+		}
+		fmt.Println("agent locked")
+	},
+}
+
+var agentLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock the agent immediately",
+	Run: func(cmd *cobra.Command, args []string) {
+		agent, err := wallet.DialAgent(viper.GetString("agent"))
+		if err != nil {
+			fatal("no agent running:", err)
+		}
+
+		if err := agent.Lock(); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+// dialAgent returns the agent holding the db at path, nil if none is
+// reachable.
+func dialAgent(path string) *wallet.AgentClient {
+	url := viper.GetString("agent")
+	if url == "" {
+		return nil
+	}
+
+	agent, err := wallet.DialAgent(url)
+	if err != nil {
+		return nil
+	}
+
+	store, err := wallet.NewStore(path, dbOptions)
+	if err != nil || store.String() != agent.DB {
+		return nil
+	}
+
+	return agent
+}
+
+// openAlfred opens the db at path with the password, or through the agent if
+// no password was given and one is running for this db.
+func openAlfred(path string) (*wallet.Alfred, error) {
+	secret := viper.GetString("secret")
+	if secret == "" {
+		if agent := dialAgent(path); agent != nil {
+			m, err := wallet.OpenAgent(path, agent, dbOptions)
+			if err != nil {
+				return nil, fmt.Errorf("agent: %v", err)
+			}
+			warnSkipped(m)
+			return m, nil
+		}
+	}
+
+	m, err := wallet.OpenSecretString(path, secret, dbOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	warnSkipped(m)
+	return m, nil
+}
+
+// warnSkipped reports the entries of m which could not be decrypted, and
+// the wallet keeping it on a weak KDF.
+func warnSkipped(m *wallet.Alfred) {
+	for _, subject := range m.Skipped() {
+		fmt.Fprintf(os.Stderr, "warning: %s could not be decrypted, it may be corrupted, run alfred doctor\n", subject)
+	}
+
+	if err := m.UpgradeBlocked(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+func defaultAgentSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return ""
+		}
+		return "unix://" + filepath.Join(home, ".alfred-agent.sock")
+	}
+
+	return "unix://" + filepath.Join(dir, "alfred-agent.sock")
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	agentCmd.AddCommand(agentLockCmd)
+
+	agentCmd.Flags().Duration("timeout", 15*time.Minute, "lock after this period of inactivity, 0 to never lock")
+	viper.BindPFlag("agent-timeout", agentCmd.Flags().Lookup("timeout"))
+}
@@ -0,0 +1,148 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Split a seed into shares and rebuild it",
+	Long:  `Split the seed of a wallet into shares using Shamir's secret sharing, a threshold of them being needed to rebuild it`,
+}
+
+var backupSplitCmd = &cobra.Command{
+	Use:     "split",
+	Short:   "Split the seed of a wallet into printable shares",
+	Long:    `Split the seed of a wallet into printable shares, any threshold of them being enough to rebuild it`,
+	Example: "alfred backup split --wallet treasury --shares 5 --threshold 3",
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		w, err := getOrSelectWallet(m, cmd.Flag("wallet").Value.String())
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := unlockWallet(w); err != nil {
+			fatal(err)
+		}
+
+		kp, ok := w.Full()
+		if !ok {
+			fatalf("seed of %s is not available", w)
+		}
+
+		n, _ := cmd.Flags().GetInt("shares")
+		threshold, _ := cmd.Flags().GetInt("threshold")
+		shares, err := wallet.SplitSeed(kp, n, threshold)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("Seed of %s split into %d shares, %d of them are needed to rebuild it.\n\n", w, n, threshold)
+		for _, s := range shares {
+			fmt.Printf("Share %d/%d of %s:\n%s\n\n", s.Index, n, s.Address, s)
+		}
+	},
+}
+
+var backupCombineCmd = &cobra.Command{
+	Use:     "combine",
+	Short:   "Rebuild a wallet from its shares",
+	Long:    `Rebuild a wallet from its shares and add it to the db`,
+	Example: "alfred backup combine --name treasury",
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		var shares []wallet.Share
+		for _, arg := range args {
+			s, err := wallet.ParseShare(arg)
+			if err != nil {
+				fatal(err)
+			}
+			shares = append(shares, s)
+		}
+
+		for len(shares) == 0 || len(shares) < shares[0].Threshold {
+			label := "Share"
+			if len(shares) > 0 {
+				label = fmt.Sprintf("Share (%d/%d)", len(shares)+1, shares[0].Threshold)
+			}
+
+			str, err := (&promptui.Prompt{
+				Label: label,
+				Validate: func(input string) error {
+					_, err := wallet.ParseShare(input)
+					return err
+				},
+			}).Run()
+			if err != nil {
+				fatal(err)
+			}
+
+			s, _ := wallet.ParseShare(str)
+			shares = append(shares, s)
+		}
+
+		kp, err := wallet.CombineShares(shares)
+		if err != nil {
+			fatal(err)
+		}
+
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		w := wallet.New(cmd.Flag("name").Value.String(), kp)
+		if err := bindNetwork(m, w); err != nil {
+			fatal(err)
+		}
+
+		if err := m.AddWallet(w); err != nil {
+			fatal(err)
+		}
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("%s restored\n", w)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupSplitCmd)
+	backupCmd.AddCommand(backupCombineCmd)
+
+	backupSplitCmd.Flags().String("wallet", "", "name or address of the wallet")
+	backupSplitCmd.Flags().Int("shares", 5, "number of shares")
+	backupSplitCmd.Flags().Int("threshold", 3, "number of shares needed to rebuild the seed")
+	backupCombineCmd.Flags().String("name", "", "name of the wallet")
+}
@@ -0,0 +1,148 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// backupsCmd represents the backups command
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Manage the backups kept on every write",
+	Long:  `Manage the backups of the db kept on every write`,
+}
+
+var backupsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List backups, most recent first",
+	Long:    `List backups, most recent first`,
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := dbFile(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		backups, err := wallet.ListBackups(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"#", "Date", "Size", "File"})
+		for i, b := range backups {
+			table.Append([]string{
+				strconv.Itoa(i + 1),
+				b.Time.Local().Format(time.RFC1123),
+				strconv.FormatInt(b.Size, 10),
+				filepath.Base(b.Path),
+			})
+		}
+		table.Render()
+	},
+}
+
+var backupsRestoreCmd = &cobra.Command{
+	Use:     "restore",
+	Short:   "Restore a backup",
+	Long:    `Restore a backup using its number in the list or its file name. The current version is kept as a backup.`,
+	Example: "alfred backups restore 1",
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fatal("one argument is expected, either the number of the backup or its file name")
+		}
+
+		path, err := dbFile(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		backups, err := wallet.ListBackups(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		backup, err := findBackup(backups, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+			_, err = (&promptui.Prompt{
+				Label:     fmt.Sprintf("Restore backup from %s", backup.Time.Local().Format(time.RFC1123)),
+				IsConfirm: true,
+			}).Run()
+			if err != nil {
+				fatal(err)
+			}
+		}
+
+		if err := wallet.RestoreBackup(path, backup, dbOptions); err != nil {
+			fatal("error restoring backup:", err)
+		}
+	},
+}
+
+// dbFile returns the path of the db file, only file dbs having backups.
+func dbFile(db string) (string, error) {
+	store, err := wallet.NewStore(db, dbOptions)
+	if err != nil {
+		return "", err
+	}
+
+	fs, ok := store.(*wallet.FileStore)
+	if !ok {
+		return "", fmt.Errorf("%s has no backups, only file dbs do", store)
+	}
+
+	return fs.Path, nil
+}
+
+func findBackup(backups []wallet.Backup, arg string) (wallet.Backup, error) {
+	if i, err := strconv.Atoi(arg); err == nil {
+		if i < 1 || i > len(backups) {
+			return wallet.Backup{}, fmt.Errorf("backup #%d not found", i)
+		}
+		return backups[i-1], nil
+	}
+
+	for _, b := range backups {
+		if filepath.Base(b.Path) == filepath.Base(arg) {
+			return b, nil
+		}
+	}
+
+	return wallet.Backup{}, fmt.Errorf("backup '%s' not found", arg)
+}
+
+func init() {
+	RootCmd.AddCommand(backupsCmd)
+	backupsCmd.AddCommand(backupsListCmd)
+	backupsCmd.AddCommand(backupsRestoreCmd)
+
+	backupsRestoreCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+}
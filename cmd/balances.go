@@ -20,7 +20,6 @@ import (
 
 	"github.com/olekukonko/tablewriter"
 
-	"github.com/celrenheit/alfred/wallet"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -33,8 +32,7 @@ var balancesCmd = &cobra.Command{
 	PreRunE: middlewares(checkDB),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			return err
 		}
@@ -42,9 +40,21 @@ var balancesCmd = &cobra.Command{
 		table := tablewriter.NewWriter(os.Stdout)
 		header := []string{"Wallet", "Currency", "Balance"}
 
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+
 		var rows [][]string
 		for _, w := range m.Stellar.Wallets {
-			balances, err := w.Balances(viper.GetBool("testnet"))
+			if !w.Tags.Match(tags) {
+				continue
+			}
+
+			network, err := walletNetwork(m, w)
+			if err != nil {
+				rows = append(rows, []string{w.String(), "error", err.Error()})
+				continue
+			}
+
+			balances, err := w.Balances(network)
 			if err != nil {
 				row := []string{w.Name, "error", err.Error()}
 				rows = append(rows, row)
@@ -80,5 +90,7 @@ var balancesCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(balancesCmd)
 
+	balancesCmd.Flags().StringSlice("tag", nil, "only show wallets having all these tags")
+
 	viper.BindPFlags(balancesCmd.Flags())
 }
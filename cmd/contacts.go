@@ -0,0 +1,329 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/keypair"
+)
+
+// contactsCmd represents the contacts command
+var contactsCmd = &cobra.Command{
+	Use:     "contacts",
+	Short:   "List and manage contacts",
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		contactsListCmd.Run(cmd, args)
+	},
+}
+
+var contactsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List contacts",
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := openAlfred(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Name", "Address", "Memo", "Tags"})
+		for _, name := range contactNames(m) {
+			c := m.Stellar.Contacts[name]
+			if !c.Tags.Match(tags) {
+				continue
+			}
+			table.Append([]string{name, c.Address, memoString(c.Memo), c.Tags.String()})
+		}
+		table.Render()
+	},
+}
+
+var contactsShowCmd = &cobra.Command{
+	Use:     "show [contact]",
+	Short:   "Show the details of a contact",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := openAlfred(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		name, err := getOrSelectContact(m, argOrEmpty(args))
+		if err != nil {
+			fatal(err)
+		}
+
+		c := m.Stellar.Contacts[name]
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.Append([]string{"Name", name})
+		table.Append([]string{"Address", c.Address})
+		table.Append([]string{"Memo", memoString(c.Memo)})
+		table.Append([]string{"Tags", c.Tags.String()})
+		table.Append([]string{"Notes", c.Notes})
+		table.Render()
+	},
+}
+
+var contactsRenameCmd = &cobra.Command{
+	Use:     "rename <contact> <new name>",
+	Short:   "Rename a contact",
+	Args:    cobra.ExactArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := m.RenameContact(args[0], args[1]); err != nil {
+			fatal(err)
+		}
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+var contactsEditCmd = &cobra.Command{
+	Use:   "edit [contact]",
+	Short: "Change the address or the memo of a contact",
+	Long:  `Change the address or the memo of a contact, both are prompted for if no flag is given.`,
+	Example: `alfred contacts edit jennifer --address GXXX
+alfred contacts edit exchange --memo-type id --memo 12345
+alfred contacts edit jennifer --no-memo`,
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		name, err := getOrSelectContact(m, argOrEmpty(args))
+		if err != nil {
+			fatal(err)
+		}
+
+		c := m.Stellar.Contacts[name]
+		addr, memo := c.Address, c.Memo
+		noMemo, _ := cmd.Flags().GetBool("no-memo")
+		memoType := cmd.Flag("memo-type").Value.String()
+
+		switch {
+		case cmd.Flags().Changed("address"), memoType != "", noMemo:
+			if cmd.Flags().Changed("address") {
+				addr = cmd.Flag("address").Value.String()
+			}
+
+			if noMemo {
+				memo = nil
+			} else if memoType != "" {
+				kind, err := wallet.ParseMemoKind(memoType)
+				if err != nil {
+					fatal(err)
+				}
+
+				memo, err = wallet.MemoFromString(kind, cmd.Flag("memo").Value.String())
+				if err != nil {
+					fatal(err)
+				}
+			}
+		default:
+			addr, err = (&promptui.Prompt{
+				Label:   "Contact's address",
+				Default: c.Address,
+				Validate: func(input string) error {
+					_, err := keypair.Parse(input)
+					return err
+				},
+			}).Run()
+			if err != nil {
+				fatal(err)
+			}
+
+			memo, err = promptMemo()
+			if err != nil {
+				fatal(err)
+			}
+		}
+
+		if err := m.EditContact(name, addr, memo); err != nil {
+			fatal(err)
+		}
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+var contactsRemoveCmd = &cobra.Command{
+	Use:     "remove [contact]",
+	Short:   "Remove a contact, a backup of the db is kept",
+	Aliases: []string{"rm"},
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		name, err := getOrSelectContact(m, argOrEmpty(args))
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := confirmRemoval(cmd, fmt.Sprintf("Remove %s (%s)", name, m.Stellar.Contacts[name].Address)); err != nil {
+			fatal(err)
+		}
+
+		if err := m.RemoveContact(name); err != nil {
+			fatal(err)
+		}
+
+		if err := writeKeepingBackup(cmd, path, m); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+var contactsTagCmd = &cobra.Command{
+	Use:   "tag <contact> <tag>...",
+	Short: "Tag a contact",
+	Long: `Tag a contact. Contacts sharing a tag form a group which can be paid at once:
+alfred please send 10 XLM to each of payroll`,
+	Example: "alfred contacts tag jennifer payroll employees",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editContact(func(m *wallet.Alfred) error { return m.TagContact(args[0], args[1:]...) })
+	},
+}
+
+var contactsUntagCmd = &cobra.Command{
+	Use:     "untag <contact> <tag>...",
+	Short:   "Remove tags from a contact",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editContact(func(m *wallet.Alfred) error { return m.UntagContact(args[0], args[1:]...) })
+	},
+}
+
+var contactsNoteCmd = &cobra.Command{
+	Use:     "note <contact> <notes>",
+	Short:   "Set the notes of a contact, empty to remove them",
+	Example: `alfred contacts note exchange "deposit address, memo required"`,
+	Args:    cobra.ExactArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editContact(func(m *wallet.Alfred) error { return m.SetContactNotes(args[0], args[1]) })
+	},
+}
+
+func editContact(edit func(*wallet.Alfred) error) {
+	path := viper.GetString("db")
+	m, err := openAlfred(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := edit(m); err != nil {
+		fatal(err)
+	}
+
+	if err := wallet.Write(path, m); err != nil {
+		fatal(err)
+	}
+}
+
+func contactNames(m *wallet.Alfred) []string {
+	var names []string
+	for name := range m.Stellar.Contacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func getOrSelectContact(m *wallet.Alfred, name string) (string, error) {
+	if name != "" {
+		if _, ok := m.Stellar.Contacts[name]; !ok {
+			return "", fmt.Errorf("contact '%s' not found", name)
+		}
+		return name, nil
+	}
+
+	names := contactNames(m)
+	if len(names) == 0 {
+		return "", errors.New("no contacts")
+	}
+
+	_, name, err := (&promptui.Select{
+		Label: "Select Contact",
+		Items: names,
+	}).Run()
+	return name, err
+}
+
+func memoString(memo *wallet.Memo) string {
+	if memo == nil {
+		return "-"
+	}
+
+	return memo.String()
+}
+
+func init() {
+	RootCmd.AddCommand(contactsCmd)
+	contactsCmd.AddCommand(contactsListCmd)
+	contactsCmd.AddCommand(contactsShowCmd)
+	contactsCmd.AddCommand(contactsRenameCmd)
+	contactsCmd.AddCommand(contactsEditCmd)
+	contactsCmd.AddCommand(contactsRemoveCmd)
+	contactsCmd.AddCommand(contactsTagCmd)
+	contactsCmd.AddCommand(contactsUntagCmd)
+	contactsCmd.AddCommand(contactsNoteCmd)
+
+	contactsCmd.Flags().StringSlice("tag", nil, "only list contacts having all these tags")
+	contactsListCmd.Flags().StringSlice("tag", nil, "only list contacts having all these tags")
+
+	contactsEditCmd.Flags().String("address", "", "new address of the contact")
+	contactsEditCmd.Flags().String("memo-type", "", "type of the new memo: text, id, hash or return")
+	contactsEditCmd.Flags().String("memo", "", "value of the new memo")
+	contactsEditCmd.Flags().Bool("no-memo", false, "remove the memo of the contact")
+	contactsRemoveCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+	contactsRemoveCmd.Flags().Bool("no-backup", false, "remove even if the db keeps no backups, as directory dbs and dbs with a decoy set")
+}
@@ -0,0 +1,159 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/wallet"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// decoyCmd represents the decoy command
+var decoyCmd = &cobra.Command{
+	Use:   "decoy",
+	Short: "Hide the wallets behind a decoy set unlocked by a second password",
+	Long: `Keep a harmless set of wallets in the db, unlocked by a decoy password to be given under duress, while the real wallets stay available with the current password.
+Every db carries a padding of random bytes, renewed on every write, which the real wallets replace once a decoy set is set up, so the file gives no sign that a second set exists.`,
+	Example: `alfred decoy init --wallet pocket
+alfred -s <decoy password> new --name savings
+alfred decoy remove`,
+}
+
+var decoyInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Set up a decoy set holding copies of the given wallets",
+	Long: `Move the wallets, contacts and roots out of sight and set up a decoy set, unlocked by the decoy password, holding copies of the wallets given with --wallet.
+Open the db with the decoy password to add more wallets to the decoy set, they do not touch the real ones.
+The decoy password is read from ALFRED_DECOY_SECRET, or from stdin when it is not a terminal, and asked for otherwise.
+Backups of the db list the real wallets and are removed, unless --keep-backups is set. Writes with the real password do not keep backups anymore.`,
+	Example: `alfred decoy init --wallet pocket --wallet savings`,
+	PreRunE: middlewares(checkDB, checkPassword),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		file, err := dbFile(path)
+		if err != nil {
+			fatal("only file dbs can have a decoy set")
+		}
+
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"), dbOptions)
+		if err != nil {
+			fatal("error opening db:", err)
+		}
+
+		names, _ := cmd.Flags().GetStringSlice("wallet")
+		var wallets []*wallet.Wallet
+		for _, name := range names {
+			w := m.WalletByName(name)
+			if w == nil {
+				fatalf("wallet '%s' not found", name)
+			}
+			wallets = append(wallets, w)
+		}
+
+		password, err := readSecret("ALFRED_DECOY_SECRET", promptDecoyPassword)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := m.InitDecoy([]byte(password), wallets); err != nil {
+			fatal("error setting up decoy:", err)
+		}
+
+		// not backed up, the previous version lists the real wallets
+		if err := wallet.Write(path, m); err != nil {
+			fatal("error writing db:", err)
+		}
+
+		fmt.Printf("%d wallet(s) hidden behind a decoy set of %d wallet(s)\n", len(m.Stellar.Wallets), len(wallets))
+
+		if keep, _ := cmd.Flags().GetBool("keep-backups"); keep {
+			fmt.Println("backups of the db still list the real wallets, remove them before relying on the decoy set")
+			return
+		}
+
+		list, err := wallet.ListBackups(file)
+		if err != nil {
+			fatal(err)
+		}
+		for _, b := range list {
+			if err := os.Remove(b.Path); err != nil {
+				fatal(err)
+			}
+		}
+		if len(list) > 0 {
+			fmt.Printf("%d backup(s) removed\n", len(list))
+		}
+	},
+}
+
+var decoyRemoveCmd = &cobra.Command{
+	Use:     "remove",
+	Short:   "Drop the decoy set and make the real wallets visible again",
+	Example: `alfred decoy remove`,
+	PreRunE: middlewares(checkDB, checkPassword),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"), dbOptions)
+		if err != nil {
+			fatal("error opening db:", err)
+		}
+
+		if err := confirmRemoval(cmd, "Remove the decoy set"); err != nil {
+			fatal(err)
+		}
+
+		if err := m.RemoveDecoy(); err != nil {
+			fatal(err)
+		}
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal("error writing db:", err)
+		}
+	},
+}
+
+func promptDecoyPassword() (string, error) {
+	password, err := promptPasswordLabel("Decoy password")
+	if err != nil {
+		return "", err
+	}
+
+	confirm, err := promptPasswordLabel("Confirm decoy password")
+	if err != nil {
+		return "", err
+	}
+
+	if password != confirm {
+		return "", errors.New("passwords do not match")
+	}
+
+	return password, nil
+}
+
+func init() {
+	RootCmd.AddCommand(decoyCmd)
+	decoyCmd.AddCommand(decoyInitCmd)
+	decoyCmd.AddCommand(decoyRemoveCmd)
+
+	decoyInitCmd.Flags().StringSlice("wallet", nil, "wallet to copy into the decoy set")
+	decoyInitCmd.Flags().Bool("keep-backups", false, "keep the backups of the db, which list the real wallets")
+
+	decoyRemoveCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+}
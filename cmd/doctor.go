@@ -0,0 +1,100 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the db and the accounts of its wallets",
+	Long: `Check the db and the accounts of its wallets:
+seeds decrypt and match their addresses, contact addresses and memos are valid, no name is shared by several wallets or contacts and the password is not derived with a weak KDF.
+A file db which does not match its schema, such as one edited by hand, is loaded anyway and its problems are listed with their line and column.
+Unless --offline is set, the account of every wallet is looked up on its network to find unfunded or merged accounts, and accounts locked by their signers.
+Exits with a non-zero status when something needs attention.`,
+	Example: `alfred doctor
+alfred doctor --offline`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		dbOptions.DiagnoseSchema = true
+		m, err := openAlfred(viper.GetString("db"))
+		if e, ok := err.(*wallet.SchemaError); ok {
+			// could not even be decoded
+			printFindings(e.Findings())
+			return
+		} else if err != nil {
+			fatal(err)
+		}
+
+		findings := m.Diagnose()
+
+		if offline, _ := cmd.Flags().GetBool("offline"); !offline {
+			for _, w := range m.Stellar.Wallets {
+				subject := "wallet " + w.Name
+				network, err := walletNetwork(m, w)
+				if err != nil {
+					findings = append(findings, wallet.Finding{Severity: wallet.Warning, Subject: subject, Message: err.Error()})
+					continue
+				}
+
+				found, err := w.DiagnoseAccount(network)
+				if err != nil {
+					findings = append(findings, wallet.Finding{Severity: wallet.Warning, Subject: subject, Message: fmt.Sprintf("account could not be checked: %v", err)})
+					continue
+				}
+				findings = append(findings, found...)
+			}
+		}
+
+		printFindings(findings)
+	},
+}
+
+// printFindings lists findings and exits with a non-zero status when one of
+// them needs attention.
+func printFindings(findings []wallet.Finding) {
+	if len(findings) == 0 {
+		fmt.Println("Everything looks fine")
+		return
+	}
+
+	attention := false
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Level", "Subject", "Message"})
+	for _, f := range findings {
+		attention = attention || f.Severity > wallet.Info
+		table.Append([]string{f.Severity.String(), f.Subject, f.Message})
+	}
+	table.Render()
+
+	if attention {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().Bool("offline", false, "do not look up the accounts of the wallets")
+}
@@ -19,7 +19,6 @@ import (
 	"strconv"
 
 	"github.com/celrenheit/alfred/parser"
-	"github.com/celrenheit/alfred/wallet"
 	"github.com/manifoldco/promptui"
 
 	"github.com/spf13/cobra"
@@ -59,22 +58,19 @@ var donateCmd = &cobra.Command{
 			}
 		}
 
-		client := getClient(viper.GetBool("testnet"))
-
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			fatal(err)
 		}
 
-		err = sendRequest(m, client, cmd, &parser.SendRequest{
+		err = sendRequest(m, cmd, &parser.SendRequest{
 			Amount:   amount,
 			Currency: "XLM",
 			To:       alfredAddress,
 		})
 		if err != nil {
-			fatalf(describeHorizonError(err))
+			fatal(describeHorizonError(err))
 		}
 		fmt.Println("Thank You ♥️")
 		fmt.Println("Keep on rockin' 🚀")
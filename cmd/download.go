@@ -36,8 +36,7 @@ var downloadCmd = &cobra.Command{
 	PreRunE: middlewares(checkDB),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			fatal(err)
 		}
@@ -49,8 +48,21 @@ var downloadCmd = &cobra.Command{
 		accName, filename := args[0], args[1]
 
 		kp := getAddress(m, accName)
+		if kp == nil {
+			fatalf("address not found for '%s'", accName)
+		}
+
+		var network wallet.Network
+		if w := m.WalletByAddress(kp.Address()); w != nil {
+			network, err = walletNetwork(m, w)
+		} else {
+			network, err = currentNetwork(m)
+		}
+		if err != nil {
+			fatal(err)
+		}
 
-		client := getClient(viper.GetBool("testnet"))
+		client := network.Client()
 		acc, exists, err := getAccount(client, kp.Address())
 		if err != nil {
 			fatal(err)
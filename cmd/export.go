@@ -16,10 +16,13 @@ package cmd
 
 import (
 	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
-
-	"github.com/stellar/go/keypair"
+	"path/filepath"
 
 	"github.com/celrenheit/alfred/wallet"
 
@@ -29,14 +32,16 @@ import (
 
 // exportCmd represents the wallets command
 var exportCmd = &cobra.Command{
-	Use:     "export",
-	Short:   "Export wallets in plaintext csv",
-	Long:    `Export wallets in plaintext csv`,
+	Use:   "export",
+	Short: "Export wallets in plaintext csv or in an encrypted keystore",
+	Long: `Export wallets in plaintext csv, or with --format keystore in a JSON keystore protected by its own password.
+Both can be imported back with 'alfred import --file'. The password of the keystore is read from ALFRED_KEYSTORE_SECRET, or from stdin when it is not a terminal, and asked for otherwise.`,
+	Example: `alfred export > wallets.csv
+alfred export --format keystore --wallet savings --output savings.json`,
 	PreRunE: middlewares(checkDB, checkSecret),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			fatal(err)
 		}
@@ -45,31 +50,125 @@ var exportCmd = &cobra.Command{
 			log.Fatal("you need to unlocked your wallet")
 		}
 
-		w := csv.NewWriter(os.Stdout)
-		rows := make([][]string, 0)
-		rows = append(rows, []string{"name", "address", "seed"})
+		var wallets []*wallet.Wallet
+		tags, _ := cmd.Flags().GetStringSlice("tag")
 		for _, w := range m.Stellar.Wallets {
-			switch kp := w.Keypair.(type) {
-			case (*keypair.FromAddress):
-				log.Fatal("keypair is not unlocked")
-			case (*keypair.Full):
-				row := []string{w.Name, kp.Address(), kp.Seed()}
-				rows = append(rows, row)
+			if w.Tags.Match(tags) {
+				wallets = append(wallets, w)
+			}
+		}
+		if names, _ := cmd.Flags().GetStringSlice("wallet"); len(names) > 0 {
+			wallets = nil
+			for _, name := range names {
+				w, err := getOrSelectWallet(m, name)
+				if err != nil {
+					fatal(err)
+				}
+				wallets = append(wallets, w)
 			}
 		}
 
-		if err := w.WriteAll(rows); err != nil {
-			fatal(err)
+		for _, w := range wallets {
+			if err := unlockWallet(w); err != nil {
+				fatal(err)
+			}
 		}
 
-		w.Flush()
+		var export func(io.Writer) error
+		switch format := cmd.Flag("format").Value.String(); format {
+		case "csv":
+			export = func(out io.Writer) error { return exportCSV(out, wallets) }
+		case "keystore":
+			secret, err := readSecret("ALFRED_KEYSTORE_SECRET", promptNewPassword)
+			if err != nil {
+				fatal(err)
+			}
+			export = func(out io.Writer) error { return exportKeystore(out, wallets, secret) }
+		default:
+			fatalf("unknown format '%s', should be csv or keystore", format)
+		}
 
-		if err := w.Error(); err != nil {
+		if err := writeOutput(cmd.Flag("output").Value.String(), export); err != nil {
 			fatal(err)
 		}
 	},
 }
 
+// writeOutput writes to stdout, or to the file output which must not exist.
+// The file is only created once everything was written, so that a failed
+// export does not leave part of it behind.
+func writeOutput(output string, write func(io.Writer) error) error {
+	if output == "" {
+		return write(os.Stdout)
+	}
+
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf("%s already exists", output)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(output), "."+filepath.Base(output))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), output)
+}
+
+func exportCSV(out io.Writer, wallets []*wallet.Wallet) error {
+	w := csv.NewWriter(out)
+	rows := make([][]string, 0)
+	rows = append(rows, []string{"name", "address", "seed", "signer"})
+	for _, w := range wallets {
+		if w.WatchOnly || w.RemoteSigner != "" {
+			rows = append(rows, []string{w.Name, w.Keypair.Address(), "", w.RemoteSigner})
+			continue
+		}
+
+		kp, ok := w.Full()
+		if !ok {
+			if err := w.SeedError(); err != nil {
+				return fmt.Errorf("wallet %s: %v", w, err)
+			}
+			return fmt.Errorf("wallet %s is not unlocked", w)
+		}
+		rows = append(rows, []string{w.Name, kp.Address(), kp.Seed(), ""})
+	}
+
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+func exportKeystore(out io.Writer, wallets []*wallet.Wallet, secret string) error {
+	ks, err := wallet.NewKeystore(wallets, []byte(secret))
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ks)
+}
+
 func init() {
 	RootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().String("format", "csv", "csv or keystore")
+	exportCmd.Flags().StringSlice("wallet", nil, "name or address of a wallet to export, all by default")
+	exportCmd.Flags().StringSlice("tag", nil, "only export wallets having all these tags")
+	exportCmd.Flags().StringP("output", "o", "", "file to write to instead of stdout")
 }
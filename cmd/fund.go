@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"github.com/celrenheit/alfred/wallet"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stellar/go/keypair"
@@ -29,7 +30,13 @@ var fundCmd = &cobra.Command{
 	Example: "alfred fund GXX",
 	PreRunE: middlewares(checkDB),
 	Run: func(cmd *cobra.Command, args []string) {
-		if !viper.GetBool("testnet") {
+		// only the test network is supported, its passphrase is known
+		network, err := currentNetwork(nil)
+		if err != nil {
+			fatal(err)
+		}
+
+		if network.Name != wallet.TestNetworkName {
 			fatal("not yet supported on main net, stay tuned")
 		}
 
@@ -44,7 +51,7 @@ var fundCmd = &cobra.Command{
 			fatal(err)
 		}
 
-		friendbotFund(addr.Address())
+		friendbotFund(network, addr.Address())
 	},
 }
 
@@ -0,0 +1,66 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/celrenheit/alfred/amounts"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// loadAccountFunds looks up the balances and the reserves of address.
+func loadAccountFunds(client *horizon.Client, address string) (*amounts.Funds, error) {
+	var account amounts.Account
+	if err := horizonGet(client, "/accounts/"+url.PathEscape(address), &account); err != nil {
+		return nil, err
+	}
+
+	var ledgers struct {
+		Embedded struct {
+			Records []struct {
+				BaseReserve int64 `json:"base_reserve_in_stroops"`
+			} `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := horizonGet(client, "/ledgers?order=desc&limit=1", &ledgers); err != nil {
+		return nil, err
+	}
+	if len(ledgers.Embedded.Records) == 0 {
+		return nil, errors.New("horizon returned no ledger, the base reserve is unknown")
+	}
+
+	return amounts.NewFunds(account, ledgers.Embedded.Records[0].BaseReserve)
+}
+
+// horizonGet decodes the JSON resource at path of the horizon of client
+// into v.
+func horizonGet(client *horizon.Client, path string, v interface{}) error {
+	resp, err := client.HTTP.Get(client.URL + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("horizon returned %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
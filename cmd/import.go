@@ -15,7 +15,11 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"strings"
 
@@ -29,11 +33,38 @@ import (
 
 // importCmd represents the import command
 var importCmd = &cobra.Command{
-	Use:     "import",
-	Short:   "Import a wallet",
-	Long:    `Import a wallet (should be private key)`,
+	Use:   "import",
+	Short: "Import a wallet",
+	Long:  `Import a wallet (should be private key), or track an address as a watch-only wallet`,
+	Example: `alfred import --name savings
+alfred import --watch GXXX --name cold
+alfred import --mnemonic --accounts 3
+alfred import --signer unix:///run/alfred/signer.sock
+alfred import --file wallets.csv
+alfred import --file savings.json`,
 	PreRunE: middlewares(checkDB, checkSecret),
 	Run: func(cmd *cobra.Command, args []string) {
+		if addr := cmd.Flag("watch").Value.String(); addr != "" {
+			importWatchOnly(cmd.Flag("name").Value.String(), addr)
+			return
+		}
+
+		if file := cmd.Flag("file").Value.String(); file != "" {
+			importFile(file)
+			return
+		}
+
+		if url := cmd.Flag("signer").Value.String(); url != "" {
+			importRemote(url)
+			return
+		}
+
+		if mnemonic, _ := cmd.Flags().GetBool("mnemonic"); mnemonic {
+			accounts, _ := cmd.Flags().GetInt("accounts")
+			importMnemonic(cmd.Flag("name").Value.String(), accounts)
+			return
+		}
+
 		validate := func(input string) error {
 			if !strings.HasPrefix(input, "S") {
 				return fmt.Errorf("this should be a private key")
@@ -54,8 +85,7 @@ var importCmd = &cobra.Command{
 		}
 
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			fatal(err)
 		}
@@ -74,8 +104,12 @@ var importCmd = &cobra.Command{
 
 		}
 
-		name := cmd.Flag("name").Value.String()
-		err = m.AddWallet(wallet.New(name, kpFull))
+		w := wallet.New(cmd.Flag("name").Value.String(), kpFull)
+		if err := bindNetwork(m, w); err != nil {
+			fatal(err)
+		}
+
+		err = m.AddWallet(w)
 		if err != nil {
 			fatal(err)
 		}
@@ -86,9 +120,278 @@ var importCmd = &cobra.Command{
 	},
 }
 
+func importWatchOnly(name, addr string) {
+	w, err := wallet.NewWatchOnly(name, addr)
+	if err != nil {
+		fatal(err)
+	}
+
+	path := viper.GetString("db")
+	m, err := openAlfred(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := bindNetwork(m, w); err != nil {
+		fatal(err)
+	}
+
+	if err := m.AddWallet(w); err != nil {
+		fatal(err)
+	}
+
+	if err := wallet.Write(path, m); err != nil {
+		fatal(err)
+	}
+}
+
+// importFile restores the wallets of a keystore or of a csv export. Wallets
+// that cannot be added are reported and skipped.
+func importFile(file string) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		fatal(err)
+	}
+
+	var wallets []*wallet.Wallet
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		wallets, err = readKeystore(b)
+	} else {
+		wallets, err = readCSV(b)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	path := viper.GetString("db")
+	m, err := openAlfred(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	var imported int
+	for _, w := range wallets {
+		if w.Network == nil {
+			if err := bindNetwork(m, w); err != nil {
+				fatal(err)
+			}
+		}
+
+		if err := m.AddWallet(w); err != nil {
+			fmt.Printf("skipping %s: %v\n", w, err)
+			continue
+		}
+		imported++
+	}
+
+	if err := wallet.Write(path, m); err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("%d of %d wallet(s) imported\n", imported, len(wallets))
+}
+
+func readKeystore(b []byte) ([]*wallet.Wallet, error) {
+	ks, err := wallet.ParseKeystore(b)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := readSecret("ALFRED_KEYSTORE_SECRET", func() (string, error) {
+		return promptPasswordLabel("Keystore password")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.Decrypt([]byte(secret))
+}
+
+// readCSV reads the wallets of a csv export, rows without a seed being
+// imported as remote signer wallets if they have a signer, as watch-only
+// wallets otherwise. Exports without the signer column are accepted.
+func readCSV(b []byte) ([]*wallet.Wallet, error) {
+	rows, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("not a csv export, header should be name,address,seed,signer")
+	}
+	switch strings.Join(rows[0], ",") {
+	case "name,address,seed,signer", "name,address,seed":
+	default:
+		return nil, errors.New("not a csv export, header should be name,address,seed,signer")
+	}
+
+	var wallets []*wallet.Wallet
+	for i, row := range rows[1:] {
+		name, address, seed := row[0], row[1], row[2]
+		if len(row) > 3 && row[3] != "" {
+			if seed != "" {
+				return nil, fmt.Errorf("line %d: wallets of a remote signer should not have a seed", i+2)
+			}
+
+			w, err := wallet.NewRemote(name, address, row[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+2, err)
+			}
+			wallets = append(wallets, w)
+			continue
+		}
+
+		if seed == "" {
+			w, err := wallet.NewWatchOnly(name, address)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+2, err)
+			}
+			wallets = append(wallets, w)
+			continue
+		}
+
+		kp, err := keypair.Parse(seed)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+
+		full, ok := kp.(*keypair.Full)
+		if !ok {
+			return nil, fmt.Errorf("line %d: seed column should contain a seed", i+2)
+		}
+
+		if full.Address() != address {
+			return nil, fmt.Errorf("line %d: seed does not match address %s", i+2, address)
+		}
+
+		wallets = append(wallets, wallet.New(name, full))
+	}
+
+	return wallets, nil
+}
+
+// importRemote adds the wallets served by the remote signer at url, their
+// transactions being signed by it. Wallets already present are left as is.
+func importRemote(url string) {
+	remotes, err := wallet.ListRemoteWallets(url, dbOptions)
+	if err != nil {
+		fatal(err)
+	}
+
+	path := viper.GetString("db")
+	m, err := openAlfred(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	for _, r := range remotes {
+		if m.WalletByAddress(r.Address) != nil {
+			fmt.Printf("%s already present, skipping\n", r.Address)
+			continue
+		}
+
+		w, err := wallet.NewRemote(r.Name, r.Address, url)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := bindNetwork(m, w); err != nil {
+			fatal(err)
+		}
+
+		if err := m.AddWallet(w); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s imported\n", w)
+	}
+
+	if err := wallet.Write(path, m); err != nil {
+		fatal(err)
+	}
+}
+
+// importMnemonic restores the first accounts derived from a mnemonic.
+// Wallets already present are left as is.
+func importMnemonic(name string, accounts int) {
+	if accounts < 1 {
+		fatal("at least one account should be restored")
+	}
+
+	prompt := promptui.Prompt{
+		Label: "What is the mnemonic ?",
+		Validate: func(input string) error {
+			_, err := wallet.SeedFromMnemonic(input, "")
+			return err
+		},
+		Mask: '*',
+	}
+
+	mnemonic, err := prompt.Run()
+	if err != nil {
+		fatal(err)
+	}
+
+	passphrase, err := readSecret("ALFRED_PASSPHRASE", promptPassphrase)
+	if err != nil {
+		fatal(err)
+	}
+
+	seed, err := wallet.SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		fatal(err)
+	}
+
+	root, err := wallet.NewRoot(seed)
+	if err != nil {
+		fatal(err)
+	}
+
+	path := viper.GetString("db")
+	m, err := openAlfred(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	root = m.AddRoot(root)
+	for i := 0; i < accounts; i++ {
+		wname := name
+		if name != "" && i > 0 {
+			wname = fmt.Sprintf("%s %d", name, i)
+		}
+
+		w, err := m.DeriveWallet(root, wname, uint32(i))
+		if err != nil {
+			fatal(err)
+		}
+
+		if m.WalletByAddress(w.Keypair.Address()) != nil {
+			fmt.Printf("%s already present, skipping\n", w.Keypair.Address())
+			continue
+		}
+
+		if err := bindNetwork(m, w); err != nil {
+			fatal(err)
+		}
+
+		if err := m.AddWallet(w); err != nil {
+			fatal(err)
+		}
+		fmt.Printf("%s restored (%s)\n", w, w.Derivation.Path())
+	}
+
+	if err := wallet.Write(path, m); err != nil {
+		fatal(err)
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(importCmd)
 
 	importCmd.Flags().String("name", "", "name of the wallet")
+	importCmd.Flags().String("watch", "", "address to import as a watch-only wallet, without its seed")
+	importCmd.Flags().String("file", "", "keystore or csv export to restore wallets from")
+	importCmd.Flags().String("signer", "", "https:// or unix:// url of a remote signer whose wallets are imported without their seed")
+	importCmd.Flags().Bool("mnemonic", false, "restore wallets from a mnemonic (SEP-0005)")
+	importCmd.Flags().Int("accounts", 1, "number of accounts to restore from the mnemonic")
 	viper.BindPFlags(importCmd.Flags())
 }
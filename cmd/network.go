@@ -0,0 +1,100 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/spf13/viper"
+)
+
+// currentNetwork returns the network selected by the flags, the public
+// network by default. Other networks are looked up among the networks of the
+// wallets of m, if not nil, unless their passphrase is given.
+func currentNetwork(m *wallet.Alfred) (wallet.Network, error) {
+	name := viper.GetString("network")
+	if viper.GetBool("testnet") {
+		if name != "" && name != wallet.TestNetworkName {
+			return wallet.Network{}, fmt.Errorf("--testnet cannot be used with --network %s", name)
+		}
+		name = wallet.TestNetworkName
+	}
+	if name == "" {
+		name = wallet.PublicNetworkName
+	}
+
+	if viper.GetString("network-passphrase") == "" && m != nil {
+		if n, ok := m.KnownNetwork(name); ok {
+			horizon := viper.GetString("horizon")
+			if horizon == "" {
+				horizon = n.Horizon
+			}
+			return wallet.NewNetwork(n.Name, n.Passphrase, horizon)
+		}
+	}
+
+	return wallet.NewNetwork(name, viper.GetString("network-passphrase"), viper.GetString("horizon"))
+}
+
+// networkSelected reports whether a network was explicitly chosen with the flags.
+func networkSelected() bool {
+	return viper.GetBool("testnet") || viper.GetString("network") != "" || viper.GetString("network-passphrase") != ""
+}
+
+// walletNetwork returns the network transactions of w should use. Wallets
+// not bound to a network follow the flags. Using a bound wallet on another
+// network is refused unless --force-network is set.
+func walletNetwork(m *wallet.Alfred, w *wallet.Wallet) (wallet.Network, error) {
+	if w.Network != nil && !networkSelected() {
+		n := *w.Network
+		if h := viper.GetString("horizon"); h != "" {
+			n.Horizon = h
+		}
+		return n, nil
+	}
+
+	n, err := currentNetwork(m)
+	if err != nil {
+		return n, err
+	}
+
+	if err := checkNetwork(w, n); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+// checkNetwork returns an error if w belongs to another network than n and
+// --force-network is not set.
+func checkNetwork(w *wallet.Wallet, n wallet.Network) error {
+	if err := w.CheckNetwork(n); err != nil && !viper.GetBool("force-network") {
+		return fmt.Errorf("%v, use --force-network to proceed anyway", err)
+	}
+
+	return nil
+}
+
+// bindNetwork binds a newly created wallet to the network selected by the flags.
+func bindNetwork(m *wallet.Alfred, w *wallet.Wallet) error {
+	n, err := currentNetwork(m)
+	if err != nil {
+		return err
+	}
+
+	w.Network = &n
+	return nil
+}
@@ -39,9 +39,12 @@ import (
 
 // newCmd represents the new command
 var newCmd = &cobra.Command{
-	Use:     "new",
-	Short:   "Command for creating new wallets",
-	Long:    `Command for creating new wallets`,
+	Use:   "new",
+	Short: "Command for creating new wallets",
+	Long:  `Command for creating new wallets`,
+	Example: `alfred new --name savings
+alfred new --mnemonic --name main
+alfred new --derive main --name second`,
 	PreRunE: middlewares(checkDB, checkSecret),
 	Run: func(cmd *cobra.Command, args []string) {
 		switch {
@@ -49,27 +52,43 @@ var newCmd = &cobra.Command{
 			fmt.Printf("too many arguments '%v'\n", args)
 		case len(args) == 0 || args[0] == "wallet":
 			path := viper.GetString("db")
-			secret := viper.GetString("secret")
-			m, err := wallet.OpenSecretString(path, secret)
+			m, err := openAlfred(path)
 			if err != nil {
 				fatal("error opening backup:", err)
 			}
 
-			prefix := strings.ToUpper(viper.GetString("prefix"))
-			suffix := strings.ToUpper(viper.GetString("suffix"))
-			kp, err := generateKP(prefix, suffix)
+			name := cmd.Flag("name").Value.String()
+
+			var w *wallet.Wallet
+			if mnemonic, _ := cmd.Flags().GetBool("mnemonic"); mnemonic {
+				words, _ := cmd.Flags().GetInt("words")
+				var passphrase string
+				if passphrase, err = readSecret("ALFRED_PASSPHRASE", promptPassphrase); err != nil {
+					fatal(err)
+				}
+				w, err = newMnemonicWallet(m, name, words, passphrase)
+			} else if derive := cmd.Flag("derive").Value.String(); derive != "" {
+				w, err = deriveWallet(m, name, derive)
+			} else {
+				prefix := strings.ToUpper(viper.GetString("prefix"))
+				suffix := strings.ToUpper(viper.GetString("suffix"))
+				var kp *keypair.Full
+				kp, err = generateKP(prefix, suffix)
+				w = wallet.New(name, kp)
+			}
+			if err == nil {
+				err = bindNetwork(m, w)
+			}
 			if err != nil {
 				fatal("error creating new wallet:", err)
 			}
 
-			name := cmd.Flag("name").Value.String()
-			w := wallet.New(name, kp)
 			err = m.AddWallet(w)
 			if err != nil {
 				fatal("error opening backup:", err)
 			}
 
-			if viper.GetBool("print-seed") {
+			if kp, _ := w.Full(); viper.GetBool("print-seed") {
 				table := tablewriter.NewWriter(os.Stdout)
 				table.SetHeader([]string{"Address", "Seed"})
 				table.Append([]string{kp.Address(), kp.Seed()})
@@ -81,8 +100,7 @@ var newCmd = &cobra.Command{
 			}
 		case args[0] == "contact":
 			path := viper.GetString("db")
-			secret := viper.GetString("secret")
-			m, err := wallet.OpenSecretString(path, secret)
+			m, err := openAlfred(path)
 			if err != nil {
 				fatal("error opening backup:", err)
 			}
@@ -130,8 +148,6 @@ var newCmd = &cobra.Command{
 				fatal("error opening backup:", err)
 			}
 
-			m.Unlock([]byte(secret))
-
 			if err := wallet.Write(path, m); err != nil {
 				fatal("error opening backup:", err)
 			}
@@ -141,6 +157,61 @@ var newCmd = &cobra.Command{
 	},
 }
 
+// newMnemonicWallet generates a mnemonic and returns the first account derived
+// from it. The mnemonic is printed as it is the only way to recover the wallets
+// derived from it.
+func newMnemonicWallet(m *wallet.Alfred, name string, words int, passphrase string) (*wallet.Wallet, error) {
+	mnemonic, err := wallet.NewMnemonic(words)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := wallet.SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := wallet.NewRoot(seed)
+	if err != nil {
+		return nil, err
+	}
+	root = m.AddRoot(root)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Mnemonic"})
+	table.Append([]string{mnemonic})
+	table.Render()
+	fmt.Println("Write these words down and keep them in a safe place, they are needed to recover the wallets derived from them.")
+
+	return m.DeriveWallet(root, name, m.NextIndex(root))
+}
+
+// deriveWallet derives the next account from the root of the wallet named
+// from, or from the root having this id.
+func deriveWallet(m *wallet.Alfred, name, from string) (*wallet.Wallet, error) {
+	root := m.RootByID(from)
+	if root == nil {
+		w := m.WalletByName(from)
+		if w == nil {
+			w = m.WalletByAddress(from)
+		}
+		if w == nil {
+			return nil, fmt.Errorf("wallet '%s' not found", from)
+		}
+		if w.Derivation == nil {
+			return nil, fmt.Errorf("wallet '%s' was not derived from a mnemonic", from)
+		}
+		root = m.RootByID(w.Derivation.Root)
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("root of '%s' not found", from)
+	}
+
+	return m.DeriveWallet(root, name, m.NextIndex(root))
+}
+
 func promptMemo() (memo *wallet.Memo, err error) {
 	sl := promptui.Select{
 		Label: "Memo",
@@ -242,5 +313,8 @@ func init() {
 	newCmd.Flags().String("name", "", "name of the wallet")
 	newCmd.Flags().String("prefix", "", "prefix for vanity addresses")
 	newCmd.Flags().String("suffix", "", "suffix for vanity addresses")
+	newCmd.Flags().Bool("mnemonic", false, "generate a mnemonic (SEP-0005) and derive the wallet from it")
+	newCmd.Flags().Int("words", 24, "number of words of the mnemonic, 12 or 24")
+	newCmd.Flags().String("derive", "", "derive the next account from the mnemonic of this wallet")
 	viper.BindPFlags(newCmd.Flags())
 }
@@ -8,9 +8,8 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/manifoldco/promptui"
+	"github.com/celrenheit/alfred/wallet"
 	"github.com/stellar/go/build"
-	"github.com/stellar/go/keypair"
 )
 
 var (
@@ -118,59 +117,18 @@ func (p *Part) Value() []byte {
 	return p.Data
 }
 
-func submitData(testnet, yes bool, src *keypair.Full, kvs []KVData) error {
-	var sopts []build.TransactionMutator
-	for _, kv := range kvs {
-		sopts = append(sopts, build.SetData(kv.Key(), kv.Value()))
-	}
-
-	client := getClient(testnet)
-
-	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
-	}
-
-	opts = append(opts, sopts...)
-
-	if testnet {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
-
-	tx, err := build.Transaction(opts...)
+func submitData(m *wallet.Alfred, yes bool, src *wallet.Wallet, kvs []KVData) error {
+	network, err := walletNetwork(m, src)
 	if err != nil {
 		return err
 	}
 
-	txe, err := tx.Sign(src.Seed())
-	if err != nil {
-		return err
-	}
-
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
-	}
-
-	if !yes {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
-		}
-	}
-
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
+	var sopts []build.TransactionMutator
+	for _, kv := range kvs {
+		sopts = append(sopts, build.SetData(kv.Key(), kv.Value()))
 	}
 
-	fmt.Println(resp.Hash)
-	return nil
+	return submitTransaction(network, src, yes, nil, sopts...)
 }
 
 func GetData(header *Header, parts []*Part) ([]byte, error) {
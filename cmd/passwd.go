@@ -0,0 +1,92 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/celrenheit/alfred/wallet"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// passwdCmd represents the passwd command
+var passwdCmd = &cobra.Command{
+	Use:   "passwd",
+	Short: "Change the password used to encrypt the wallets",
+	Long: `Re-encrypt every seed under a new password. The file is left untouched if anything fails.
+A key file can be added or replaced with --new-keyfile, or removed with --no-keyfile.
+The new password is prompted for, or read from $ALFRED_NEW_SECRET, or from stdin when it is not a terminal.`,
+	Example: `alfred passwd
+alfred passwd --keyfile /media/usb/alfred.key --no-keyfile
+alfred passwd --new-keyfile /media/usb/alfred.key`,
+	PreRunE: middlewares(checkDB, checkPassword),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		secret := viper.GetString("secret")
+		m, err := wallet.OpenSecretString(path, secret, dbOptions)
+		if err != nil {
+			fatal("error opening backup:", err)
+		}
+
+		newSecret, err := readSecret("ALFRED_NEW_SECRET", promptNewPassword)
+		if err != nil {
+			fatal(err)
+		}
+
+		newKeyFile := cmd.Flag("new-keyfile").Value.String()
+		noKeyFile, _ := cmd.Flags().GetBool("no-keyfile")
+		switch {
+		case newKeyFile != "":
+			if m.Options().KeyFile, err = readKeyFile(newKeyFile); err != nil {
+				fatal(err)
+			}
+		case noKeyFile, !m.KDF().KeyFile:
+			// do not start requiring the key file given with --keyfile
+			m.Options().KeyFile = nil
+		}
+
+		if err := m.ChangePassword([]byte(newSecret)); err != nil {
+			fatal("error changing password:", err)
+		}
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal("error writing backup:", err)
+		}
+
+		// the agent only knows the old key
+		if agent := dialAgent(path); agent != nil {
+			if err := agent.Lock(); err != nil {
+				fatal("error locking agent:", err)
+			}
+		}
+
+		if v := m.Vault(); v != nil {
+			fmt.Printf("password of member %s changed\n", v.Member().Name)
+			return
+		}
+
+		fmt.Printf("%d wallet(s) re-encrypted\n", len(m.Stellar.Wallets))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(passwdCmd)
+
+	passwdCmd.Flags().String("new-keyfile", "", "key file to combine with the new password, replacing the current one if any")
+	passwdCmd.Flags().Bool("no-keyfile", false, "stop requiring a key file")
+	viper.BindPFlags(passwdCmd.Flags())
+}
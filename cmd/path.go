@@ -0,0 +1,122 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+)
+
+// paymentPath is a conversion found through the DEX, paying the destination
+// amount for SourceAmount of the source asset.
+type paymentPath struct {
+	SourceAmount string          `json:"source_amount"`
+	Path         []horizon.Asset `json:"path"`
+}
+
+// String describes the assets the payment goes through, from the source
+// asset to the destination asset.
+func (p paymentPath) String(from, to assets.Asset) string {
+	codes := []string{from.CodeString()}
+	for _, a := range p.Path {
+		code := a.Code
+		if a.Type == "native" {
+			code = "XLM"
+		}
+		codes = append(codes, code)
+	}
+	codes = append(codes, to.CodeString())
+
+	return strings.Join(codes, " -> ")
+}
+
+// builderPath returns the intermediate assets of p for build.PayWith.
+func (p paymentPath) builderPath() []build.Asset {
+	var path []build.Asset
+	for _, a := range p.Path {
+		if a.Type == "native" {
+			path = append(path, build.NativeAsset())
+		} else {
+			path = append(path, build.CreditAsset(a.Code, a.Issuer))
+		}
+	}
+
+	return path
+}
+
+// findPath asks horizon for the cheapest path paying destAmount of to with
+// from.
+func findPath(client *horizon.Client, from, to assets.Asset, destAmount string) (paymentPath, error) {
+	dest := to.ToHorizonAsset()
+	q := url.Values{}
+	q.Set("destination_asset_type", dest.Type)
+	if dest.Type != "native" {
+		q.Set("destination_asset_code", dest.Code)
+		q.Set("destination_asset_issuer", dest.Issuer)
+	}
+	q.Set("destination_amount", destAmount)
+	q.Set("source_assets", "native")
+	if !from.BuilderAsset.Native {
+		q.Set("source_assets", from.BuilderAsset.Code+":"+from.BuilderAsset.Issuer)
+	}
+
+	resp, err := client.HTTP.Get(strings.TrimRight(client.URL, "/") + "/paths/strict-receive?" + q.Encode())
+	if err != nil {
+		return paymentPath{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return paymentPath{}, fmt.Errorf("path could not be found, horizon returned %s", resp.Status)
+	}
+
+	var page struct {
+		Embedded struct {
+			Records []paymentPath `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return paymentPath{}, err
+	}
+
+	var (
+		best     paymentPath
+		bestCost int64 = math.MaxInt64
+	)
+	for _, p := range page.Embedded.Records {
+		cost, err := amount.Parse(p.SourceAmount)
+		if err != nil {
+			continue
+		}
+		if int64(cost) < bestCost {
+			best, bestCost = p, int64(cost)
+		}
+	}
+
+	if bestCost == math.MaxInt64 {
+		return paymentPath{}, fmt.Errorf("no path found to send %s %s using %s", destAmount, to.CodeString(), from.CodeString())
+	}
+
+	return best, nil
+}
@@ -0,0 +1,638 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/celrenheit/alfred/assets"
+	"github.com/celrenheit/alfred/payouts"
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+)
+
+// payoutCmd represents the payout command
+var payoutCmd = &cobra.Command{
+	Use:   "payout <file>",
+	Short: "Send the payments listed in a CSV or JSON file",
+	Long: `Send the payments listed in a CSV or JSON file, packed into transactions of at most 100 payments.
+
+CSV files have one payment per line: recipient, amount, asset and an optional memo, with an optional header row.
+JSON files hold a list of objects with the fields to, amount, asset and memo.
+Recipients are addresses, wallets or contacts. Assets are codes, such as XLM or MOBI, or CODE:ISSUER for assets alfred does not know; XLM is used when it is empty.
+Memos are given as type:value, such as id:12345, or as a plain text; they replace the memo of the contact. A transaction has only one memo, payments are grouped by memo. When resuming, rows whose memo changed since the payout was planned fail.
+
+Progress is saved after every transaction to <file>.state.json: an interrupted payout is resumed by running the same command again, without paying anyone twice.
+Once done, the transaction and the outcome of every row are written to <file>.report.csv.`,
+	Example: `alfred payout payroll.csv --from treasury
+alfred payout payroll.json --from treasury --yes`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		file := args[0]
+		from, _ := cmd.Flags().GetString("from")
+		yes, _ := cmd.Flags().GetBool("yes")
+		statePath, _ := cmd.Flags().GetString("state")
+		if statePath == "" {
+			statePath = file + ".state.json"
+		}
+		reportPath, _ := cmd.Flags().GetString("report")
+		if reportPath == "" {
+			reportPath = file + ".report.csv"
+		}
+
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			fatal(err)
+		}
+
+		rows, err := readPayoutRows(file, b)
+		if err != nil {
+			fatal(err)
+		}
+
+		m, err := openAlfred(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		src, err := getOrSelectWallet(m, from)
+		if err != nil {
+			fatal(err)
+		}
+
+		if src.WatchOnly {
+			fatalf("%s is watch-only, payouts need to be signed by alfred", src)
+		}
+
+		network, err := walletNetwork(m, src)
+		if err != nil {
+			fatal(err)
+		}
+
+		sum := sha256.Sum256(b)
+		checksum := hex.EncodeToString(sum[:])
+
+		state, resumed, err := payouts.LoadState(statePath)
+		if err != nil {
+			fatalf("%s: %v", statePath, err)
+		}
+
+		switch {
+		case !resumed:
+			state = planPayout(m, network, rows)
+			state.Source = src.Keypair.Address()
+			state.Checksum = checksum
+			if err := state.Save(statePath); err != nil {
+				fatal(err)
+			}
+		case state.Checksum != checksum:
+			fatalf("%s changed since the payout started, remove %s to start a new payout", file, statePath)
+		case state.Source != src.Keypair.Address():
+			fatalf("the payout was started from %s, remove %s to start a new payout", state.Source, statePath)
+		}
+
+		p := &payout{
+			m:       m,
+			network: network,
+			client:  network.Client(),
+			src:     src,
+			rows:    rows,
+			state:   state,
+			resumed: resumed,
+			save:    func() error { return state.Save(statePath) },
+		}
+
+		if !state.Done() {
+			if err := p.run(yes); err != nil {
+				fatal(err)
+			}
+		}
+
+		if err := p.writeReport(reportPath); err != nil {
+			fatal(err)
+		}
+
+		paid, failed := state.Count()
+		fmt.Printf("%d payment(s) sent, %d failed, see %s\n", paid, failed, reportPath)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(payoutCmd)
+
+	payoutCmd.Flags().String("from", "", "wallet sending the payments")
+	payoutCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+	payoutCmd.Flags().String("state", "", "file saving the progress of the payout (default <file>.state.json)")
+	payoutCmd.Flags().String("report", "", "file the report is written to (default <file>.report.csv)")
+}
+
+// payoutRow is a payment read from a payout file.
+type payoutRow struct {
+	Line   int    `json:"-"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+	Asset  string `json:"asset"`
+	Memo   string `json:"memo"`
+}
+
+// readPayoutRows reads the rows of the payout file path, in JSON when its
+// extension is .json and in CSV otherwise.
+func readPayoutRows(path string, b []byte) ([]payoutRow, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []payoutRow
+		if err := json.Unmarshal(b, &rows); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for i := range rows {
+			rows[i].Line = i + 1
+		}
+		return rows, nil
+	}
+
+	// one row per line, read on their own to know their line
+	var rows []payoutRow
+	for i, text := range strings.Split(string(b), "\n") {
+		line, text := i+1, strings.TrimSuffix(text, "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		r := csv.NewReader(strings.NewReader(text))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		record, err := r.Read()
+		if perr, ok := err.(*csv.ParseError); ok {
+			return nil, fmt.Errorf("%s: line %d, column %d: %v", path, line, perr.Column, perr.Err)
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %v", path, line, err)
+		}
+
+		if len(record) < 3 || len(record) > 4 {
+			return nil, fmt.Errorf("%s: line %d: expected recipient, amount, asset and an optional memo", path, line)
+		}
+		if len(rows) == 0 && strings.EqualFold(strings.TrimSpace(record[1]), "amount") {
+			continue // header
+		}
+
+		row := payoutRow{Line: line, To: record[0], Amount: record[1], Asset: record[2]}
+		if len(record) == 4 {
+			row.Memo = record[3]
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no payment found", path)
+	}
+
+	return rows, nil
+}
+
+// planPayout checks the rows and groups the valid ones by memo into batches
+// of at most maxTransactionOps payments.
+func planPayout(m *wallet.Alfred, network wallet.Network, rows []payoutRow) *payouts.State {
+	state := &payouts.State{Invalid: make(map[int]string)}
+
+	var keys []string
+	groups := make(map[string][]int)
+	for _, row := range rows {
+		p, err := resolvePayoutRow(m, network, row)
+		if err != nil {
+			state.Invalid[row.Line] = err.Error()
+			continue
+		}
+
+		key := memoKey(p.memo)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row.Line)
+	}
+
+	for _, key := range keys {
+		lines := groups[key]
+		for len(lines) > 0 {
+			n := len(lines)
+			if n > maxTransactionOps {
+				n = maxTransactionOps
+			}
+			state.Batches = append(state.Batches, &payouts.Batch{Lines: lines[:n], Memo: key, Status: payouts.Pending})
+			lines = lines[n:]
+		}
+	}
+
+	return state
+}
+
+// memoKey returns the memo batches are grouped by, empty for none.
+func memoKey(memo *wallet.Memo) string {
+	if memo == nil {
+		return ""
+	}
+
+	return memo.String()
+}
+
+// payoutPayment is a row resolved against the db.
+type payoutPayment struct {
+	row   payoutRow
+	to    recipient
+	asset assets.Asset
+	memo  *wallet.Memo
+}
+
+func resolvePayoutRow(m *wallet.Alfred, network wallet.Network, row payoutRow) (payoutPayment, error) {
+	p := payoutPayment{row: row}
+
+	if strings.TrimSpace(row.To) == "" {
+		return p, errors.New("recipient is missing")
+	}
+
+	to, err := getOrSelectRecipient(m, network, strings.TrimSpace(row.To))
+	if err != nil {
+		return p, err
+	}
+	p.to = to
+	p.memo = to.memo
+	p.to.memo = nil
+
+	asset, err := payoutAsset(row.Asset)
+	if err != nil {
+		return p, err
+	}
+	p.asset = asset
+
+	amt, err := amount.Parse(strings.TrimSpace(row.Amount))
+	if err != nil {
+		return p, fmt.Errorf("invalid amount '%s'", row.Amount)
+	}
+	if amt <= 0 {
+		return p, fmt.Errorf("amount should be positive, not '%s'", row.Amount)
+	}
+	p.row.Amount = amount.String(amt)
+
+	if memo := strings.TrimSpace(row.Memo); memo != "" {
+		kind, value := wallet.MEMO_TEXT, memo
+		if i := strings.Index(memo, ":"); i > 0 {
+			if k, err := wallet.ParseMemoKind(memo[:i]); err == nil {
+				kind, value = k, memo[i+1:]
+			}
+		}
+
+		p.memo, err = wallet.MemoFromString(kind, value)
+		if err != nil {
+			return p, err
+		}
+	}
+
+	return p, nil
+}
+
+// payoutAsset returns the asset named by code, or by code:issuer. Payouts
+// run unattended, ambiguous codes are rejected instead of prompted for.
+func payoutAsset(code string) (assets.Asset, error) {
+	code = strings.TrimSpace(code)
+	if code == "" || strings.EqualFold(code, "lumens") {
+		code = "XLM"
+	}
+
+	if i := strings.Index(code, ":"); i >= 0 {
+		code, issuer := strings.ToUpper(code[:i]), code[i+1:]
+		if _, err := keypair.Parse(issuer); err != nil {
+			return assets.Asset{}, fmt.Errorf("invalid issuer of %s: %v", code, err)
+		}
+		if a := assets.GetByCodeIssuer(code, issuer); a != nil {
+			return *a, nil
+		}
+		return assets.Asset{BuilderAsset: build.CreditAsset(code, issuer)}, nil
+	}
+
+	asts := assets.GetAssets(code)
+	switch len(asts) {
+	case 0:
+		return assets.Asset{}, fmt.Errorf("asset %s is unknown, give it as CODE:ISSUER", code)
+	case 1:
+		return asts[0], nil
+	default:
+		return assets.Asset{}, fmt.Errorf("several assets are named %s, give it as CODE:ISSUER", code)
+	}
+}
+
+// payout sends the batches of a payouts.State.
+type payout struct {
+	m       *wallet.Alfred
+	network wallet.Network
+	client  *horizon.Client
+	src     *wallet.Wallet
+	signer  wallet.Signer
+	rows    []payoutRow
+	state   *payouts.State
+	resumed bool
+	save    func() error
+}
+
+func (p *payout) row(line int) payoutRow {
+	for _, row := range p.rows {
+		if row.Line == line {
+			return row
+		}
+	}
+
+	return payoutRow{Line: line}
+}
+
+// run confirms and sends the batches left.
+func (p *payout) run(yes bool) error {
+	if !yes {
+		printSummaryTable(p.network, p.summary())
+		_, err := (&promptui.Prompt{
+			Label:     "Are you sure",
+			IsConfirm: true,
+		}).Run()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := unlockWallet(p.src); err != nil {
+		return err
+	}
+
+	signer, err := p.src.Signer(dbOptions)
+	if err != nil {
+		return err
+	}
+	p.signer = signer
+
+	sender := &payouts.Sender{
+		Horizon: payoutHorizon{p.client},
+		Build:   p.build,
+		Save:    p.save,
+	}
+	for i, batch := range p.state.Batches {
+		if err := sender.Pay(batch); err != nil {
+			return fmt.Errorf("transaction %d/%d: %s\nthe payout is interrupted, run the same command again to resume it", i+1, len(p.state.Batches), describeHorizonError(err))
+		}
+		if batch.Status == payouts.Paid {
+			fmt.Printf("transaction %d/%d: %s\n", i+1, len(p.state.Batches), batch.Hash)
+		} else {
+			fmt.Printf("transaction %d/%d: failed: %s\n", i+1, len(p.state.Batches), batch.Error)
+		}
+	}
+
+	return nil
+}
+
+// summary describes the payments left to send.
+func (p *payout) summary() map[string]string {
+	var batches, payments int
+	totals := make(map[string]int64)
+	for _, b := range p.state.Batches {
+		if b.Status == payouts.Paid || b.Status == payouts.Failed {
+			continue
+		}
+		batches++
+
+		for _, line := range b.Lines {
+			if _, failed := b.Failed[line]; failed {
+				continue
+			}
+			payments++
+
+			row := p.row(line)
+			asset, _ := payoutAsset(row.Asset)
+			amt, _ := amount.Parse(strings.TrimSpace(row.Amount))
+			totals[asset.CodeString()] += int64(amt)
+		}
+	}
+
+	var total []string
+	for code, amt := range totals {
+		total = append(total, amount.StringFromInt64(amt)+" "+code)
+	}
+	sort.Strings(total)
+
+	summary := map[string]string{
+		"Source":       p.state.Source,
+		"Payments":     strconv.Itoa(payments),
+		"Transactions": strconv.Itoa(batches),
+		"Total":        strings.Join(total, ", "),
+	}
+	if len(p.state.Invalid) > 0 {
+		summary["Invalid rows"] = strconv.Itoa(len(p.state.Invalid))
+	}
+	if p.resumed {
+		summary["Resuming"] = "yes"
+	}
+
+	return summary
+}
+
+// build signs the transaction paying lines with the memo their batch was
+// planned with, adding the rows which cannot be paid to failed. Rows whose
+// memo changed since, such as the memo of their contact, fail instead of
+// being paid with the memo of the batch.
+func (p *payout) build(lines []int, planned string, failed map[int]string) (*payouts.Transaction, error) {
+	srcAcc, exists, err := getAccount(p.client, p.src.Keypair.Address())
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("source account does not exist, please fund it first")
+	}
+
+	var (
+		ops  []build.TransactionMutator
+		sent []int
+		memo *wallet.Memo
+	)
+	for _, line := range lines {
+		payment, err := resolvePayoutRow(p.m, p.network, p.row(line))
+		if err != nil {
+			failed[line] = err.Error()
+			continue
+		}
+
+		if key := memoKey(payment.memo); key != planned {
+			if key == "" {
+				key = "none"
+			}
+			failed[line] = fmt.Sprintf("memo changed to %s since the payout was planned", key)
+			continue
+		}
+
+		if !hasTrustline(srcAcc, payment.asset) {
+			failed[line] = fmt.Sprintf("source account does not hold %s", payment.asset.CodeString())
+			continue
+		}
+
+		op, err := paymentOps(p.client, srcAcc, payment.asset, payment.row.Amount, payment.to)
+		if err != nil {
+			failed[line] = err.Error()
+			continue
+		}
+
+		ops = append(ops, op...)
+		sent = append(sent, line)
+		memo = payment.memo
+	}
+
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	muts := []build.TransactionMutator{
+		build.SourceAccount{AddressOrSeed: p.src.Keypair.Address()},
+		build.AutoSequence{SequenceProvider: p.client},
+		p.network.Mutator(),
+	}
+	muts = append(muts, ops...)
+	if memo != nil {
+		muts = append(muts, memo.ToTransactionMutator())
+	}
+
+	tx, err := build.Transaction(muts...)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope, err := signEnvelope(p.network, p.signer, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := tx.HashHex()
+	if err != nil {
+		return nil, err
+	}
+
+	return &payouts.Transaction{Envelope: envelope, Hash: hash, Sent: sent}, nil
+}
+
+// payoutHorizon submits the transactions of a payout to horizon.
+type payoutHorizon struct {
+	client *horizon.Client
+}
+
+func (h payoutHorizon) Applied(hash string) (bool, error) {
+	return transactionApplied(h.client, hash)
+}
+
+func (h payoutHorizon) Submit(envelope string) error {
+	_, err := h.client.SubmitTransaction(envelope)
+	herr, ok := err.(*horizon.Error)
+	if !ok || herr.Response == nil || herr.Response.StatusCode != http.StatusBadRequest {
+		return err
+	}
+
+	rejected := &payouts.Rejected{Message: describeHorizonError(err)}
+	if codes, _ := herr.ResultCodes(); codes != nil {
+		rejected.Code, rejected.Operations = codes.TransactionCode, codes.OperationCodes
+	}
+
+	return rejected
+}
+
+// transactionApplied tells whether the transaction hash was applied
+// successfully.
+func transactionApplied(client *horizon.Client, hash string) (bool, error) {
+	resp, err := client.HTTP.Get(fmt.Sprintf("%s/transactions/%s", client.URL, hash))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("horizon returned %s", resp.Status)
+	}
+
+	var tx struct {
+		Successful *bool `json:"successful"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tx); err != nil {
+		return false, err
+	}
+
+	// older horizons only list successful transactions
+	return tx.Successful == nil || *tx.Successful, nil
+}
+
+// writeReport writes the outcome of every row to path, in the order of the
+// payout file.
+func (p *payout) writeReport(path string) error {
+	type outcome struct {
+		status, hash, err string
+	}
+
+	outcomes := make(map[int]outcome)
+	for line, err := range p.state.Invalid {
+		outcomes[line] = outcome{status: "invalid", err: err}
+	}
+	for _, b := range p.state.Batches {
+		for _, line := range b.Lines {
+			o := outcome{status: b.Status}
+			switch err, rowFailed := b.Failed[line]; {
+			case rowFailed:
+				o = outcome{status: payouts.Failed, err: err}
+			case b.Status == payouts.Paid:
+				o.hash = b.Hash
+			case b.Status == payouts.Failed:
+				o.err = b.Error
+			}
+			outcomes[line] = o
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"line", "to", "amount", "asset", "status", "transaction", "error"})
+	for _, row := range p.rows {
+		o := outcomes[row.Line]
+		w.Write([]string{strconv.Itoa(row.Line), row.To, row.Amount, row.Asset, o.status, o.hash, o.err})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -19,9 +19,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/celrenheit/alfred/amounts"
 	"github.com/celrenheit/alfred/assets"
 	"github.com/celrenheit/alfred/parser"
 	"github.com/celrenheit/alfred/wallet"
@@ -29,6 +31,7 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/stellar/go/amount"
 	"github.com/stellar/go/build"
 	"github.com/stellar/go/clients/horizon"
 	"github.com/stellar/go/keypair"
@@ -42,6 +45,13 @@ var pleaseCmd = &cobra.Command{
 	Long:    `please command allows to execute command`,
 	Example: `alfred please send 20 XLM from master to jennifer
 alfred please send 33 MOBI from master to jennifer
+alfred please send 10 XLM from master to each of payroll (contacts tagged payroll)
+alfred please send 10 XLM from master to exchange with memo id 12345 (replaces the memo of the contact)
+alfred please send 10 XLM to GXXX with memo text "invoice 42"
+alfred please send 100 MOBI to bob using XLM (converted through the DEX)
+alfred please send 10 XLM to alice, 25 MOBI to bob and 5 XLM to carol from master (in one transaction)
+alfred please send all XLM from master to savings (keeps the minimum balance and the fee)
+alfred please send 50% of MOBI from master to bob
 
 alfred please buy 100 MOBI using XLM (will pick the best price)
 alfred please buy MOBI using 100 XLM (will pick the best price)
@@ -63,30 +73,27 @@ alfred please sell 100 MOBI FOR XLM (will pick the best price)
 			fatal(err)
 		}
 
-		client := getClient(viper.GetBool("testnet"))
-
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			fatal(err)
 		}
 
 		switch req := statement.(type) {
 		case *parser.SendRequest:
-			err = sendRequest(m, client, cmd, req)
+			err = sendRequest(m, cmd, req)
 		case *parser.ShareAccountRequest:
-			err = shareRequest(m, client, cmd, req)
+			err = shareRequest(m, cmd, req)
 		case *parser.SetDataRequest:
-			err = setData(m, client, cmd, req)
+			err = setData(m, cmd, req)
 		case *parser.Offer:
-			err = createOffer(m, client, cmd, req)
+			err = createOffer(m, cmd, req)
 		default:
 			fatalf("unsupported statement type: %T", statement.Kind())
 		}
 
 		if err != nil {
-			fatalf(describeHorizonError(err))
+			fatal(describeHorizonError(err))
 		}
 	},
 }
@@ -108,45 +115,361 @@ func init() {
 	RootCmd.AddCommand(pleaseCmd)
 
 	pleaseCmd.Flags().BoolP("yes", "y", false, "if set, no confirmation prompt will be shown")
+	pleaseCmd.Flags().Float64("max-slippage", 1, "percentage the amount sent by a path payment may exceed its quote")
 	viper.BindPFlags(pleaseCmd.Flags())
 }
 
-func sendRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.SendRequest) error {
+func sendRequest(m *wallet.Alfred, cmd *cobra.Command, req *parser.SendRequest) error {
+	if len(req.Payments) > 0 {
+		return sendPayments(m, cmd, req)
+	}
+
 	// Check choosen currency
 	asset, err := selectAsset(req.Currency)
 	if err != nil {
 		return err
 	}
 
-	// Check trust
+	src, err := getOrSelectWallet(m, req.From)
+	if err != nil {
+		return err
+	}
+
+	network, err := walletNetwork(m, src)
+	if err != nil {
+		return err
+	}
+	client := network.Client()
+
+	var recipients []recipient
+	if req.ToGroup != "" {
+		recipients, err = groupRecipients(m, req.ToGroup)
+	} else {
+		var r recipient
+		r, err = getOrSelectRecipient(m, network, req.To)
+		recipients = append(recipients, r)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(recipients) > maxTransactionOps {
+		return fmt.Errorf("%d contacts in group '%s' do not fit in a transaction of at most %d operations, use alfred payout", len(recipients), req.ToGroup, maxTransactionOps)
+	}
+
+	var memo *wallet.Memo
+	if req.MemoType != "" {
+		if memo, err = memoFromRequest(req); err != nil {
+			return err
+		}
+	}
+	if memo, err = transactionMemo(memo, recipients); err != nil {
+		return err
+	}
+
+	srcAcc, exists, err := getAccount(client, src.Keypair.Address())
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("source account does exists, please fund it first")
+	}
 
 	var (
-		from = req.From
-		to   = req.To
+		sendAsset *assets.Asset
+		path      paymentPath
+		sendMax   string
 	)
+	if req.SourceCurrency != "" {
+		if sendAsset, err = selectAsset(req.SourceCurrency); err != nil {
+			return err
+		}
+
+		if sendAsset.BuilderAsset == asset.BuilderAsset {
+			sendAsset = nil
+		} else if !hasTrustline(srcAcc, *sendAsset) {
+			return fmt.Errorf("source account does not hold %v", sendAsset)
+		}
+	}
+
+	funds, err := loadAccountFunds(client, src.Keypair.Address())
+	if err != nil {
+		return err
+	}
+
+	// a single transaction pays every recipient, trusting the asset first
+	// if needed
+	ops := len(recipients)
+	if sendAsset == nil && !hasTrustline(srcAcc, *asset) {
+		ops++
+	}
+	if ops > maxTransactionOps {
+		return fmt.Errorf("the payments take %d operations, more than the %d a transaction can hold, use alfred payout", ops, maxTransactionOps)
+	}
+	fee := amounts.TransactionFee(ops)
+
+	amt, err := funds.Request(req, *asset, fee)
+	if err != nil {
+		return err
+	}
+
+	summary := map[string]string{
+		"Amount":   amt,
+		"Currency": req.Currency,
+		"Source":   src.Keypair.Address(),
+	}
+	switch req.AmountKind {
+	case parser.AmountAllKind:
+		summary["Amount"] = fmt.Sprintf("%s (%s)", amt, req.Amount)
+	case parser.AmountPercentKind:
+		summary["Amount"] = fmt.Sprintf("%s (%s%%)", amt, req.Amount)
+	}
+
+	if sendAsset != nil {
+		if path, err = findPath(client, *sendAsset, *asset, amt); err != nil {
+			return err
+		}
+
+		slippage, _ := cmd.Flags().GetFloat64("max-slippage")
+		if sendMax, err = amounts.MaxSend(path.SourceAmount, slippage); err != nil {
+			return err
+		}
 
-	src, err := getOrSelectWallet(m, from)
+		summary["Path"] = path.String(*sendAsset, *asset)
+		summary["Quote"] = fmt.Sprintf("%s %s", path.SourceAmount, sendAsset.CodeString())
+		summary["Max sent"] = fmt.Sprintf("%s %s (%v%% slippage)", sendMax, sendAsset.CodeString(), slippage)
+	}
+
+	spentAsset, spentAmount := *asset, amt
+	if sendAsset != nil {
+		spentAsset, spentAmount = *sendAsset, sendMax
+	}
+	spent, err := amount.Parse(spentAmount)
 	if err != nil {
+		return fmt.Errorf("invalid amount '%s': %v", spentAmount, err)
+	}
+	n := int64(len(recipients))
+	if err := funds.Check(map[build.Asset]int64{spentAsset.BuilderAsset: int64(spent) * n}, fee); err != nil {
 		return err
 	}
 
-	var memo build.TransactionMutator
-	if to != "" {
-		if addr, err := keypair.Parse(to); err == nil { // to custom address
-			to = addr.Address()
+	// every recipient is paid or none is
+	var txOps []build.TransactionMutator
+	for i, r := range recipients {
+		r.memo = nil
+
+		var payment []build.TransactionMutator
+		if sendAsset != nil {
+			payment, err = pathPaymentOps(client, *sendAsset, sendMax, path, *asset, amt, r)
 		} else {
-			if w := m.WalletByName(to); w != nil { // between wallet
-				to = w.Keypair.Address()
-			} else if contact, ok := m.Stellar.Contacts[to]; ok { // to contact
-				to = contact.Address
-				if contact.Memo != nil {
-					memo = contact.Memo.ToTransactionMutator()
-				}
-			} else {
-				return fmt.Errorf("destination '%s' not found", to)
+			payment, err = paymentOps(client, srcAcc, *asset, amt, r)
+		}
+		if err != nil {
+			if req.ToGroup != "" {
+				return fmt.Errorf("%s: %v", r, err)
 			}
+			return err
 		}
-	} else {
+
+		// the asset is only trusted once
+		if i > 0 {
+			payment = payment[:1]
+		}
+		txOps = append(txOps, payment...)
+
+		if req.ToGroup != "" {
+			summary[fmt.Sprintf("Payment %d", i+1)] = fmt.Sprintf("%s %s to %s", amt, asset.CodeString(), r)
+		} else {
+			summary["Destination"] = r.String()
+		}
+	}
+
+	if memo != nil {
+		summary["Memo"] = memo.String()
+		txOps = append(txOps, memo.ToTransactionMutator())
+	}
+
+	return submitTransaction(network, src, viper.GetBool("yes"), summary, txOps...)
+}
+
+// maxTransactionOps is the most operations a transaction can hold.
+const maxTransactionOps = 100
+
+// sendPayments sends the payments of req in a single transaction, so that
+// they all succeed or fail together for a single fee. A transaction has only
+// one memo: recipients wanting different memos are refused, unless the memo
+// to use is given.
+func sendPayments(m *wallet.Alfred, cmd *cobra.Command, req *parser.SendRequest) error {
+	if req.SourceCurrency != "" {
+		return errors.New("USING cannot be combined with several payments, send them separately")
+	}
+
+	src, err := getOrSelectWallet(m, req.From)
+	if err != nil {
+		return err
+	}
+
+	network, err := walletNetwork(m, src)
+	if err != nil {
+		return err
+	}
+	client := network.Client()
+
+	srcAcc, exists, err := getAccount(client, src.Keypair.Address())
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fmt.Errorf("source account does exists, please fund it first")
+	}
+
+	var memo *wallet.Memo
+	if req.MemoType != "" {
+		if memo, err = memoFromRequest(req); err != nil {
+			return err
+		}
+	}
+
+	payments := append([]parser.Payment{{Amount: req.Amount, Currency: req.Currency, To: req.To}}, req.Payments...)
+	if len(payments) > maxTransactionOps {
+		return fmt.Errorf("%d payments do not fit in a transaction of at most %d operations, send them separately or use alfred payout", len(payments), maxTransactionOps)
+	}
+	summary := map[string]string{"Source": src.Keypair.Address()}
+
+	var (
+		ops        []build.TransactionMutator
+		recipients []recipient
+		spent      = make(map[build.Asset]int64)
+	)
+	for i, p := range payments {
+		asset, err := selectAsset(p.Currency)
+		if err != nil {
+			return err
+		}
+
+		r, err := getOrSelectRecipient(m, network, p.To)
+		if err != nil {
+			return err
+		}
+
+		recipients = append(recipients, r)
+		r.memo = nil
+
+		payment, err := paymentOps(client, srcAcc, *asset, p.Amount, r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", r, err)
+		}
+		ops = append(ops, payment...)
+
+		amt, err := amount.Parse(p.Amount)
+		if err != nil {
+			return fmt.Errorf("invalid amount '%s': %v", p.Amount, err)
+		}
+		spent[asset.BuilderAsset] += int64(amt)
+
+		summary[fmt.Sprintf("Payment %d", i+1)] = fmt.Sprintf("%s %s to %s", p.Amount, asset.CodeString(), r)
+	}
+
+	// accounts to create or trustlines to add take operations of their own
+	if len(ops) > maxTransactionOps {
+		return fmt.Errorf("the payments take %d operations, more than the %d a transaction can hold, send them separately", len(ops), maxTransactionOps)
+	}
+
+	if memo, err = transactionMemo(memo, recipients); err != nil {
+		return err
+	}
+
+	funds, err := loadAccountFunds(client, src.Keypair.Address())
+	if err != nil {
+		return err
+	}
+	if err := funds.Check(spent, amounts.TransactionFee(len(ops))); err != nil {
+		return err
+	}
+
+	if memo != nil {
+		summary["Memo"] = memo.String()
+		ops = append(ops, memo.ToTransactionMutator())
+	}
+
+	return submitTransaction(network, src, viper.GetBool("yes"), summary, ops...)
+}
+
+// transactionMemo returns the memo of a transaction paying recipients, given
+// being the memo of WITH MEMO which replaces theirs. A transaction has only
+// one memo: recipients wanting different memos are refused unless it is
+// given, since a payment without its memo may be lost, such as an exchange
+// deposit.
+func transactionMemo(given *wallet.Memo, recipients []recipient) (*wallet.Memo, error) {
+	if given != nil {
+		return given, nil
+	}
+
+	var (
+		wanted   []string
+		common   *wallet.Memo
+		conflict bool
+	)
+	for _, r := range recipients {
+		if r.memo == nil {
+			continue
+		}
+
+		wanted = append(wanted, fmt.Sprintf("%s wants %s", r, r.memo))
+		if common == nil {
+			common = r.memo
+		} else if *common != *r.memo {
+			conflict = true
+		}
+	}
+
+	if conflict {
+		return nil, fmt.Errorf("recipients want different memos (%s), give the memo to use with WITH MEMO or send separately", strings.Join(wanted, ", "))
+	}
+
+	return common, nil
+}
+
+// memoFromRequest returns the memo given with WITH MEMO, which replaces the
+// memo of the recipients.
+func memoFromRequest(req *parser.SendRequest) (*wallet.Memo, error) {
+	kind, err := wallet.ParseMemoKind(req.MemoType)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet.MemoFromString(kind, req.Memo)
+}
+
+// recipient is the destination of a payment.
+type recipient struct {
+	name    string
+	address string
+	memo    *wallet.Memo
+}
+
+func (r recipient) String() string {
+	if r.name == "" || r.name == r.address {
+		return r.address
+	}
+
+	return fmt.Sprintf("%s (%s)", r.name, r.address)
+}
+
+func contactRecipient(name string, contact wallet.Contact) recipient {
+	return recipient{
+		name:    name,
+		address: contact.Address,
+		memo:    contact.Memo,
+	}
+}
+
+// getOrSelectRecipient resolves to as an address, a wallet or a contact,
+// prompting for it if empty.
+func getOrSelectRecipient(m *wallet.Alfred, network wallet.Network, to string) (recipient, error) {
+	if to == "" {
 		var toList []string
 		for name, _ := range m.Stellar.Contacts {
 			toList = append(toList, name)
@@ -163,140 +486,153 @@ func sendRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, r
 
 		_, name, err := prompt.Run()
 		if err != nil {
-			return err
+			return recipient{}, err
 		}
+		to = name
+	}
+
+	if addr, err := keypair.Parse(to); err == nil { // to custom address
+		return recipient{address: addr.Address()}, nil
+	}
 
-		contact := m.Stellar.Contacts[name]
-		to = contact.Address
-		if contact.Memo != nil {
-			memo = contact.Memo.ToTransactionMutator()
+	if w := m.WalletByName(to); w != nil { // between wallet
+		if err := checkNetwork(w, network); err != nil {
+			return recipient{}, err
 		}
+		return recipient{name: w.Name, address: w.Keypair.Address()}, nil
 	}
 
-	srcAcc, exists, err := getAccount(client, src.Address())
-	if err != nil {
-		return err
+	if contact, ok := m.Stellar.Contacts[to]; ok { // to contact
+		return contactRecipient(to, contact), nil
 	}
 
-	if !exists {
-		return fmt.Errorf("source account does exists, please fund it first")
+	return recipient{}, fmt.Errorf("destination '%s' not found", to)
+}
+
+// groupRecipients returns the contacts tagged with group.
+func groupRecipients(m *wallet.Alfred, group string) ([]recipient, error) {
+	names := m.ContactGroup(group)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no contact in group '%s', tag contacts with 'alfred contacts tag <contact> %s'", group, group)
 	}
 
-	destAcc, exists, err := getAccount(client, to)
+	var recipients []recipient
+	for _, name := range names {
+		recipients = append(recipients, contactRecipient(name, m.Stellar.Contacts[name]))
+	}
+
+	return recipients, nil
+}
+
+// paymentOps returns the operations paying amount of asset to r, creating
+// its account if needed.
+func paymentOps(client *horizon.Client, srcAcc horizon.Account, asset assets.Asset, amount string, r recipient) ([]build.TransactionMutator, error) {
+	destAcc, exists, err := getAccount(client, r.address)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if !hasTrustline(destAcc, *asset) {
-		return fmt.Errorf("destination account needs to trust %v", asset)
+	if !hasTrustline(destAcc, asset) {
+		return nil, fmt.Errorf("destination account needs to trust %v", asset)
 	}
 
-	var amount interface{}
+	var amt interface{}
 	if asset.BuilderAsset.Native {
-		amount = build.NativeAmount{Amount: req.Amount}
+		amt = build.NativeAmount{Amount: amount}
 	} else {
-		amount = build.CreditAmount{
+		amt = build.CreditAmount{
 			Code:   asset.BuilderAsset.Code,
 			Issuer: asset.BuilderAsset.Issuer,
-			Amount: req.Amount,
+			Amount: amount,
 		}
 	}
 
 	var txnMutator build.TransactionMutator
 	if exists {
 		txnMutator = build.Payment(
-			build.Destination{AddressOrSeed: to},
-			amount,
+			build.Destination{AddressOrSeed: r.address},
+			amt,
 		)
 	} else {
 		txnMutator = build.CreateAccount(
-			build.Destination{AddressOrSeed: to},
-			amount,
+			build.Destination{AddressOrSeed: r.address},
+			amt,
 		)
 	}
 
 	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
 		txnMutator,
 	}
-	if memo != nil {
-		opts = append(opts, memo)
+	if r.memo != nil {
+		opts = append(opts, r.memo.ToTransactionMutator())
 	}
 
-	if !hasTrustline(srcAcc, *asset) {
+	if !hasTrustline(srcAcc, asset) {
 		opts = append(opts, build.Trust(asset.BuilderAsset.Code, asset.BuilderAsset.Issuer))
 	}
 
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
+	return opts, nil
+}
 
-	tx, err := build.Transaction(opts...)
+// pathPaymentOps returns the operations paying amount of asset to r with at
+// most sendMax of sendAsset, converted through path.
+func pathPaymentOps(client *horizon.Client, sendAsset assets.Asset, sendMax string, path paymentPath, asset assets.Asset, amount string, r recipient) ([]build.TransactionMutator, error) {
+	destAcc, exists, err := getAccount(client, r.address)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	txe, err := tx.Sign(src.Seed())
-	if err != nil {
-		return err
+	if !exists {
+		return nil, errors.New("destination account does not exist, it cannot be created by a path payment")
 	}
 
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
+	if !hasTrustline(destAcc, asset) {
+		return nil, fmt.Errorf("destination account needs to trust %v", asset)
 	}
 
-	if !viper.GetBool("yes") {
-		printSummaryTable(map[string]string{
-			"Amount":      req.Amount,
-			"Currency":    req.Currency,
-			"Source":      src.Address(),
-			"Destination": to,
-		})
-
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
+	var amt interface{}
+	if asset.BuilderAsset.Native {
+		amt = build.NativeAmount{Amount: amount}
+	} else {
+		amt = build.CreditAmount{
+			Code:   asset.BuilderAsset.Code,
+			Issuer: asset.BuilderAsset.Issuer,
+			Amount: amount,
 		}
 	}
 
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
+	payWith := build.PayWith(sendAsset.BuilderAsset, sendMax)
+	for _, a := range path.builderPath() {
+		payWith = payWith.Through(a)
 	}
 
-	fmt.Println(resp.Hash)
-	return nil
+	opts := []build.TransactionMutator{
+		build.Payment(
+			build.Destination{AddressOrSeed: r.address},
+			amt,
+			payWith,
+		),
+	}
+	if r.memo != nil {
+		opts = append(opts, r.memo.ToTransactionMutator())
+	}
+
+	return opts, nil
 }
 
-func shareRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.ShareAccountRequest) error {
-	getAddress := func(in string) keypair.KP {
-		if kp, err := keypair.Parse(in); err == nil { // to custom address
-			return kp
-		} else {
-			if w := m.WalletByName(in); w != nil { // between wallet
-				return w.Keypair
-			} else if contact, ok := m.Stellar.Contacts[in]; ok { // to contact
-				return keypair.MustParse(contact.Address)
-			}
-		}
-		return nil
+func shareRequest(m *wallet.Alfred, cmd *cobra.Command, req *parser.ShareAccountRequest) error {
+	src, err := getOrSelectWallet(m, req.Account)
+	if err != nil {
+		return err
 	}
 
-	addr := getAddress(req.Account)
-	if addr == nil {
-		return fmt.Errorf("'%v' wallet not found", req.Account)
+	network, err := walletNetwork(m, src)
+	if err != nil {
+		return err
 	}
+	client := network.Client()
 
-	src := addr.(*keypair.Full)
-
-	masterAcc, exists, err := getAccount(client, addr.Address())
+	masterAcc, exists, err := getAccount(client, src.Keypair.Address())
 	if err != nil {
 		return err
 	}
@@ -306,7 +642,7 @@ func shareRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command,
 
 	var newSigners []horizon.Account
 	for _, name := range req.AdditionnalSigners {
-		addr := getAddress(name)
+		addr := getAddress(m, name)
 		if addr == nil {
 			return fmt.Errorf("address not found for '%v'", name)
 		}
@@ -333,54 +669,16 @@ func shareRequest(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command,
 		build.SetThresholds(1, 1, threshold),
 	)
 
-	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
-		build.SetOptions(sopts...),
-	}
-
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
-
-	tx, err := build.Transaction(opts...)
-	if err != nil {
-		return err
-	}
-
-	txe, err := tx.Sign(src.Seed())
-	if err != nil {
-		return err
-	}
-
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
-	}
-
-	if !viper.GetBool("yes") {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
-		}
-	}
+	return submitTransaction(network, src, viper.GetBool("yes"), nil, build.SetOptions(sopts...))
+}
 
-	resp, err := client.SubmitTransaction(txeB64)
+func setData(m *wallet.Alfred, cmd *cobra.Command, req *parser.SetDataRequest) error {
+	src, err := selectWallet(m)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(resp.Hash)
-	return nil
-}
-
-func setData(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.SetDataRequest) error {
-	src, err := selectWallet(m)
+	network, err := walletNetwork(m, src)
 	if err != nil {
 		return err
 	}
@@ -402,58 +700,20 @@ func setData(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *
 		sopts = append(sopts, build.SetData(key, data))
 	}
 
-	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
-	}
-
-	opts = append(opts, sopts...)
-
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
-
-	tx, err := build.Transaction(opts...)
-	if err != nil {
-		return err
-	}
-
-	txe, err := tx.Sign(src.Seed())
-	if err != nil {
-		return err
-	}
-
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
-	}
-
-	if !viper.GetBool("yes") {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
-		}
-	}
+	return submitTransaction(network, src, viper.GetBool("yes"), nil, sopts...)
+}
 
-	resp, err := client.SubmitTransaction(txeB64)
+func createOffer(m *wallet.Alfred, cmd *cobra.Command, req *parser.Offer) error {
+	src, err := getOrSelectWallet(m, req.Account)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(resp.Hash)
-	return nil
-}
-
-func createOffer(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, req *parser.Offer) error {
-	src, err := getOrSelectWallet(m, req.Account)
+	network, err := walletNetwork(m, src)
 	if err != nil {
 		return err
 	}
+	client := network.Client()
 
 	buying, err := selectAsset(req.Buying)
 	if err != nil {
@@ -520,60 +780,18 @@ func createOffer(m *wallet.Alfred, client *horizon.Client, cmd *cobra.Command, r
 		amountDescr = fmt.Sprintf("%f %s", amount, selling.CodeString())
 	}
 
-	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
-		build.CreateOffer(build.Rate{
-			Buying:  buying.BuilderAsset,
-			Selling: selling.BuilderAsset,
-			Price:   build.Price(price),
-		}, build.Amount(strAmount)),
-	}
-
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
-
-	tx, err := build.Transaction(opts...)
-	if err != nil {
-		return err
-	}
-
-	txe, err := tx.Sign(src.Seed())
-	if err != nil {
-		return err
-	}
-
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
-	}
+	offer := build.CreateOffer(build.Rate{
+		Buying:  buying.BuilderAsset,
+		Selling: selling.BuilderAsset,
+		Price:   build.Price(price),
+	}, build.Amount(strAmount))
 
-	if !viper.GetBool("yes") {
-		printSummaryTable(map[string]string{
-			"Amount":  amountDescr,
-			"Buying":  buying.String(),
-			"Selling": selling.String(),
-			"Price":   price,
-		})
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
-		}
-	}
-
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(resp.Hash)
-	return nil
+	return submitTransaction(network, src, viper.GetBool("yes"), map[string]string{
+		"Amount":  amountDescr,
+		"Buying":  buying.String(),
+		"Selling": selling.String(),
+		"Price":   price,
+	}, offer)
 }
 
 func hasTrustline(acc horizon.Account, asset assets.Asset) bool {
@@ -602,7 +820,7 @@ func getAddress(m *wallet.Alfred, in string) keypair.KP {
 	return nil
 }
 
-func selectWallet(m *wallet.Alfred) (*keypair.Full, error) {
+func selectWallet(m *wallet.Alfred) (*wallet.Wallet, error) {
 	sel := promptui.Select{
 		Label: "Select Wallet",
 		Items: m.Stellar.Wallets,
@@ -613,7 +831,7 @@ func selectWallet(m *wallet.Alfred) (*keypair.Full, error) {
 		return nil, err
 	}
 
-	return m.Stellar.Wallets[idx].Keypair.(*keypair.Full), nil
+	return m.Stellar.Wallets[idx], nil
 }
 
 func selectAsset(cur string) (*assets.Asset, error) {
@@ -644,7 +862,7 @@ func selectAsset(cur string) (*assets.Asset, error) {
 	return &asset, nil
 }
 
-func getOrSelectWallet(m *wallet.Alfred, from string) (src *keypair.Full, err error) {
+func getOrSelectWallet(m *wallet.Alfred, from string) (src *wallet.Wallet, err error) {
 	if from != "" {
 		var w *wallet.Wallet
 		if addr, err := keypair.Parse(from); err == nil {
@@ -657,7 +875,7 @@ func getOrSelectWallet(m *wallet.Alfred, from string) (src *keypair.Full, err er
 			return nil, fmt.Errorf("wallet '%s' not found", from)
 		}
 
-		src = w.Keypair.(*keypair.Full)
+		src = w
 	} else {
 		src, err = selectWallet(m)
 		if err != nil {
@@ -668,18 +886,19 @@ func getOrSelectWallet(m *wallet.Alfred, from string) (src *keypair.Full, err er
 	return src, nil
 }
 
-func printSummaryTable(kvs map[string]string) {
+func printSummaryTable(network wallet.Network, kvs map[string]string) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAlignment(tablewriter.ALIGN_RIGHT)
 
-	network := "PUBLIC"
-	if viper.GetBool("testnet") {
-		network = "TESTNET"
+	kvs["Network"] = strings.ToUpper(network.String())
+	keys := make([]string, 0, len(kvs))
+	for k := range kvs {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	kvs["Network"] = network
-	for k, v := range kvs {
-		table.Append([]string{k, v})
+	for _, k := range keys {
+		table.Append([]string{k, kvs[k]})
 	}
 	table.Render()
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/celrenheit/alfred/wallet"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -29,6 +30,10 @@ const (
 
 var cfgFile string
 
+// dbOptions are the options dbs are opened with, read from the flags and the
+// config file by initConfig.
+var dbOptions = wallet.DefaultOptions()
+
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "alfred",
@@ -47,8 +52,19 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	RootCmd.PersistentFlags().StringP("secret", "s", "", "secret used for encryption of the wallet")
-	RootCmd.PersistentFlags().StringP("db", "d", "alfred.yaml", "path of file where everything will be stored")
+	RootCmd.PersistentFlags().StringP("db", "d", "alfred.yaml", "path of file where everything will be stored, or dir://path to store one file per wallet and contact")
+	RootCmd.PersistentFlags().Int("backups", wallet.DefaultBackups, "number of previous versions of the db kept as backups")
 	RootCmd.PersistentFlags().Bool("testnet", false, "use testnet")
+	RootCmd.PersistentFlags().String("network", "", "network to use: public, testnet or the name of a custom network")
+	RootCmd.PersistentFlags().String("network-passphrase", "", "passphrase of a custom network")
+	RootCmd.PersistentFlags().String("horizon", "", "url of the horizon server of the network")
+	RootCmd.PersistentFlags().String("signer-tokens", "", "file of the tokens sent to remote signers, one 'url token' per line")
+	RootCmd.PersistentFlags().String("signer-ca", "", "PEM certificates trusted to verify https remote signers, instead of the system ones")
+	RootCmd.PersistentFlags().Bool("force-network", false, "use wallets on another network than the one they are bound to")
+	RootCmd.PersistentFlags().String("agent", defaultAgentSocket(), "socket of the agent keeping the db unlocked")
+	RootCmd.PersistentFlags().String("keyfile", "", "key file combined with the password, e.g. kept on removable media")
+	RootCmd.PersistentFlags().String("member", "", "member of the team vault to open the db as")
+	RootCmd.PersistentFlags().String("member-key", "", "key file of the member, for members without a password")
 	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.alfred.yaml)")
 
 	viper.BindPFlags(RootCmd.PersistentFlags())
@@ -79,6 +95,41 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
+
+	dbOptions.Backups = viper.GetInt("backups")
+	if path := viper.GetString("signer-tokens"); path != "" {
+		tokens, err := readSignerTokens(path)
+		if err != nil {
+			fmt.Println("error reading signer tokens:", err)
+			os.Exit(1)
+		}
+		dbOptions.SignerTokens = tokens
+	}
+	if path := viper.GetString("signer-ca"); path != "" {
+		roots, err := readCertPool(path)
+		if err != nil {
+			fmt.Println("error reading signer certificates:", err)
+			os.Exit(1)
+		}
+		dbOptions.SignerRoots = roots
+	}
+	if path := viper.GetString("keyfile"); path != "" {
+		keyfile, err := readKeyFile(path)
+		if err != nil {
+			fmt.Println("error reading key file:", err)
+			os.Exit(1)
+		}
+		dbOptions.KeyFile = keyfile
+	}
+	dbOptions.Member = viper.GetString("member")
+	if path := viper.GetString("member-key"); path != "" {
+		key, err := readMemberKey(path)
+		if err != nil {
+			fmt.Println("error reading member key:", err)
+			os.Exit(1)
+		}
+		dbOptions.MemberKey = key
+	}
 }
 
 func fatal(a ...interface{}) {
@@ -0,0 +1,90 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/wallet"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// sealCmd represents the seal command
+var sealCmd = &cobra.Command{
+	Use:   "seal",
+	Short: "Encrypt and authenticate the whole db",
+	Long: `Encrypt and authenticate the whole db, including contacts and wallet names, instead of only the seeds.
+Wallet addresses are kept readable without the password in a separate public section, which is checked against the sealed content once unlocked.
+Backups of the db are not sealed, and could be put back in its place to edit contacts unnoticed: they are removed when sealing, unless --keep-backups is set.`,
+	Example: `alfred seal
+alfred seal --off`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		file, err := dbFile(path)
+		if err != nil {
+			fatal("only file dbs can be sealed, entries of directory dbs are always encrypted")
+		}
+
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal("error opening backup:", err)
+		}
+
+		if m.HasDecoy() {
+			fatal("the real wallets are always sealed behind the decoy set, open the db with the decoy password to seal it")
+		}
+
+		off, _ := cmd.Flags().GetBool("off")
+		wasSealed := m.IsSealed()
+		m.Seal(!off)
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal("error writing backup:", err)
+		}
+
+		if off || wasSealed {
+			return
+		}
+
+		if keep, _ := cmd.Flags().GetBool("keep-backups"); keep {
+			fmt.Println("backups of the db are not sealed, remove them before relying on the seal")
+			return
+		}
+
+		list, err := wallet.ListBackups(file)
+		if err != nil {
+			fatal(err)
+		}
+		for _, b := range list {
+			if err := os.Remove(b.Path); err != nil {
+				fatal(err)
+			}
+		}
+		if len(list) > 0 {
+			fmt.Printf("%d unsealed backup(s) removed\n", len(list))
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sealCmd)
+
+	sealCmd.Flags().Bool("off", false, "only encrypt the seeds")
+	sealCmd.Flags().Bool("keep-backups", false, "keep the backups of the db, which are not sealed")
+}
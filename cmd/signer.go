@@ -0,0 +1,196 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stellar/go/xdr"
+)
+
+// signerCmd represents the signer command
+var signerCmd = &cobra.Command{
+	Use:   "signer",
+	Short: "Run a signing process holding the seeds",
+	Long: `A signer holds the seeds of some wallets and signs transactions on behalf of alfred processes that do not have them.
+Wallets are added to another db with 'alfred import --signer URL', signing then goes through the signer.`,
+}
+
+var signerServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve signatures for the wallets of the db",
+	Example: `alfred signer serve --listen unix:///run/alfred/signer.sock --wallet treasury
+ALFRED_SIGNER_TOKEN=$(cat signer.token) alfred signer serve --listen 127.0.0.1:7500 --tls-cert signer.crt --tls-key signer.key --confirm`,
+	PreRunE: middlewares(checkDB, checkPassword),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		secret := viper.GetString("secret")
+		m, err := wallet.OpenSecretString(path, secret, dbOptions)
+		if err != nil {
+			fatal(err)
+		}
+
+		names, _ := cmd.Flags().GetStringSlice("wallet")
+		wallets, err := signerWallets(m, names)
+		if err != nil {
+			fatal(err)
+		}
+
+		listen := cmd.Flag("listen").Value.String()
+		token := os.Getenv("ALFRED_SIGNER_TOKEN")
+		var config *tls.Config
+		if !strings.HasPrefix(listen, "unix://") {
+			if token == "" {
+				token, err = readSecret("ALFRED_SIGNER_TOKEN", func() (string, error) {
+					return promptPasswordLabel("Token required from clients")
+				})
+				if err != nil {
+					fatal(err)
+				}
+			}
+			if token == "" {
+				fatal("a token is required to listen on the network")
+			}
+
+			certFile, _ := cmd.Flags().GetString("tls-cert")
+			keyFile, _ := cmd.Flags().GetString("tls-key")
+			if certFile == "" || keyFile == "" {
+				fatal("--tls-cert and --tls-key are required to listen on the network")
+			}
+
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				fatal("error loading TLS certificate:", err)
+			}
+			config = &tls.Config{Certificates: []tls.Certificate{cert}}
+		}
+
+		confirm, _ := cmd.Flags().GetBool("confirm")
+		server := &wallet.SignerServer{
+			Wallets:   wallets,
+			Token:     token,
+			Authorize: authorizeSignature(confirm),
+		}
+
+		l, err := wallet.ListenSigner(listen, config)
+		if err != nil {
+			fatal(err)
+		}
+
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		go func() {
+			<-c
+			l.Close()
+		}()
+
+		for _, w := range wallets {
+			log.Printf("signing for %s", w)
+		}
+		log.Printf("listening on %s", listen)
+
+		if err := http.Serve(l, server); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+			fatal(err)
+		}
+	},
+}
+
+// signerWallets returns the wallets named in names, or all the wallets
+// whose seed is available if names is empty.
+func signerWallets(m *wallet.Alfred, names []string) ([]*wallet.Wallet, error) {
+	var wallets []*wallet.Wallet
+	if len(names) == 0 {
+		for _, w := range m.Stellar.Wallets {
+			if _, ok := w.Full(); ok {
+				wallets = append(wallets, w)
+			}
+		}
+	}
+
+	for _, name := range names {
+		w, err := getOrSelectWallet(m, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := unlockWallet(w); err != nil {
+			return nil, err
+		}
+		if _, ok := w.Full(); !ok {
+			return nil, fmt.Errorf("seed of %s is not available", w)
+		}
+		wallets = append(wallets, w)
+	}
+
+	if len(wallets) == 0 {
+		return nil, errors.New("no wallet to sign for")
+	}
+
+	return wallets, nil
+}
+
+// authorizeSignature logs every request, asking for confirmation first if
+// confirm is set.
+func authorizeSignature(confirm bool) func(*wallet.Wallet, string, *xdr.Transaction) error {
+	var mu sync.Mutex
+	return func(w *wallet.Wallet, passphrase string, tx *xdr.Transaction) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if w.Network != nil && w.Network.Passphrase != passphrase && !viper.GetBool("force-network") {
+			log.Printf("refused signature for %s on another network (%s)", w, passphrase)
+			return fmt.Errorf("wallet %s belongs to the %s network", w, w.Network)
+		}
+
+		desc := fmt.Sprintf("%s: %d operation(s), fee %d, network %q", w, len(tx.Operations), tx.Fee, passphrase)
+		if !confirm {
+			log.Printf("signing for %s", desc)
+			return nil
+		}
+
+		_, err := (&promptui.Prompt{
+			Label:     "Sign for " + desc,
+			IsConfirm: true,
+		}).Run()
+		if err != nil {
+			log.Printf("refused signature for %s", desc)
+			return errors.New("signature refused")
+		}
+
+		return nil
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(signerCmd)
+	signerCmd.AddCommand(signerServeCmd)
+
+	signerServeCmd.Flags().String("listen", "unix://alfred-signer.sock", "unix:///path/to/socket or host:port to listen on")
+	signerServeCmd.Flags().StringSlice("wallet", nil, "name or address of a wallet to sign for, all by default")
+	signerServeCmd.Flags().Bool("confirm", false, "ask for confirmation before each signature")
+	signerServeCmd.Flags().String("tls-cert", "", "certificate served when listening on host:port")
+	signerServeCmd.Flags().String("tls-key", "", "private key of --tls-cert")
+}
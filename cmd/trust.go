@@ -16,11 +16,9 @@ package cmd
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/celrenheit/alfred/assets"
 	"github.com/celrenheit/alfred/wallet"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stellar/go/build"
@@ -35,8 +33,7 @@ var trustCmd = &cobra.Command{
 	PreRunE: middlewares(checkDB, checkSecret),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			fatal(err)
 		}
@@ -69,14 +66,18 @@ func init() {
 }
 
 func trust(m *wallet.Alfred, asset assets.Asset) error {
-	client := getClient(viper.GetBool("testnet"))
-
 	src, err := selectWallet(m)
 	if err != nil {
 		return err
 	}
 
-	acc, exists, err := getAccount(client, src.Address())
+	network, err := walletNetwork(m, src)
+	if err != nil {
+		return err
+	}
+	client := network.Client()
+
+	acc, exists, err := getAccount(client, src.Keypair.Address())
 	if err != nil {
 		return err
 	}
@@ -89,48 +90,7 @@ func trust(m *wallet.Alfred, asset assets.Asset) error {
 		return errors.New("account already has this trustline")
 	}
 
-	opts := []build.TransactionMutator{
-		build.SourceAccount{src.Seed()},
-		build.AutoSequence{SequenceProvider: client},
+	return submitTransaction(network, src, viper.GetBool("yes"), nil,
 		build.Trust(asset.BuilderAsset.Code, asset.BuilderAsset.Issuer),
-	}
-
-	if viper.GetBool("testnet") {
-		opts = append(opts, build.TestNetwork)
-	} else {
-		opts = append(opts, build.PublicNetwork)
-	}
-
-	tx, err := build.Transaction(opts...)
-	if err != nil {
-		return err
-	}
-
-	txe, err := tx.Sign(src.Seed())
-	if err != nil {
-		return err
-	}
-
-	txeB64, err := txe.Base64()
-	if err != nil {
-		return err
-	}
-
-	if !viper.GetBool("yes") {
-		_, err = (&promptui.Prompt{
-			Label:     "Are you sure",
-			IsConfirm: true,
-		}).Run()
-		if err != nil {
-			return err
-		}
-	}
-
-	resp, err := client.SubmitTransaction(txeB64)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(resp.Hash)
-	return nil
+	)
 }
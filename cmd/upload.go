@@ -18,8 +18,6 @@ import (
 	"io/ioutil"
 	"path/filepath"
 
-	"github.com/celrenheit/alfred/wallet"
-
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -34,8 +32,7 @@ var uploadCmd = &cobra.Command{
 	PreRunE: middlewares(checkDB, checkSecret),
 	Run: func(cmd *cobra.Command, args []string) {
 		path := viper.GetString("db")
-		secret := viper.GetString("secret")
-		m, err := wallet.OpenSecretString(path, secret)
+		m, err := openAlfred(path)
 		if err != nil {
 			fatal(err)
 		}
@@ -70,7 +67,7 @@ var uploadCmd = &cobra.Command{
 			fatal(err)
 		}
 
-		err = submitData(viper.GetBool("testnet"), viper.GetBool("yes"), src, kvs)
+		err = submitData(m, viper.GetBool("yes"), src, kvs)
 		if err != nil {
 			fatal(err)
 		}
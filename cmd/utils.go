@@ -1,13 +1,22 @@
 package cmd
 
 import (
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"strings"
 
+	"github.com/celrenheit/alfred/wallet"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/stellar/go/build"
 	"github.com/stellar/go/clients/horizon"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 type handler func() error
@@ -34,7 +43,24 @@ func checkDB(next handler) handler {
 	}
 }
 
+// checkSecret prompts for the password unless it was given, an agent
+// holds the db unlocked or a vault member key is used.
 func checkSecret(next handler) handler {
+	return func() error {
+		if viper.GetString("secret") == "" && viper.GetString("member-key") != "" {
+			return next()
+		}
+
+		if viper.GetString("secret") == "" && dialAgent(viper.GetString("db")) != nil {
+			return next()
+		}
+
+		return checkPassword(next)()
+	}
+}
+
+// checkPassword prompts for the password unless it was given.
+func checkPassword(next handler) handler {
 	return func() error {
 		if viper.GetString("secret") == "" {
 			secret, err := promptPassword()
@@ -49,8 +75,30 @@ func checkSecret(next handler) handler {
 }
 
 func promptPassword() (string, error) {
+	return promptPasswordLabel("Password")
+}
+
+func promptNewPassword() (string, error) {
+	password, err := promptPasswordLabel("New password")
+	if err != nil {
+		return "", err
+	}
+
+	confirm, err := promptPasswordLabel("Confirm new password")
+	if err != nil {
+		return "", err
+	}
+
+	if password != confirm {
+		return "", errors.New("passwords do not match")
+	}
+
+	return password, nil
+}
+
+func promptPasswordLabel(label string) (string, error) {
 	prompt := promptui.Prompt{
-		Label: "Password",
+		Label: label,
 		Validate: func(input string) error {
 			if len(input) < 8 {
 				return errors.New("length be greater than 8")
@@ -62,13 +110,55 @@ func promptPassword() (string, error) {
 	return prompt.Run()
 }
 
-func getClient(testnet bool) *horizon.Client {
-	client := horizon.DefaultPublicNetClient
-	if testnet {
-		client = horizon.DefaultTestNetClient
+// promptPassphrase prompts for the optional passphrase of a mnemonic.
+func promptPassphrase() (string, error) {
+	return (&promptui.Prompt{
+		Label: "Mnemonic passphrase (empty for none)",
+		Mask:  '*',
+	}).Run()
+}
+
+// readSecret returns the secret held by the environment variable env,
+// falling back to a line of stdin when it is not a terminal, and to prompt
+// otherwise. Secrets are not accepted as flags, which end up in the shell
+// history and in the process list.
+func readSecret(env string, prompt func() (string, error)) (string, error) {
+	if secret, ok := os.LookupEnv(env); ok {
+		return secret, nil
+	}
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return readLine(os.Stdin)
 	}
 
-	return client
+	return prompt()
+}
+
+// readLine reads a single line from r, one byte at a time so that nothing
+// after it is consumed.
+func readLine(r io.Reader) (string, error) {
+	var (
+		line []byte
+		b    = make([]byte, 1)
+	)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF && len(line) == 0 {
+			return "", errors.New("expected a line on stdin")
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSuffix(string(line), "\r"), nil
 }
 
 func getAccount(client *horizon.Client, account string) (horizon.Account, bool, error) {
@@ -83,10 +173,179 @@ func getAccount(client *horizon.Client, account string) (horizon.Account, bool,
 	return hAccount, true, nil
 }
 
-func friendbotFund(addr string) {
-	friendBotResp, err := http.Get("https://horizon-testnet.stellar.org/friendbot?addr=" + addr)
+// submitTransaction builds a transaction from src with muts for network, then
+// signs it with the signer of src and submits it once confirmed. Watch-only
+// wallets cannot sign, the unsigned envelope is printed instead so that it
+// can be signed elsewhere.
+func submitTransaction(network wallet.Network, src *wallet.Wallet, yes bool, summary map[string]string, muts ...build.TransactionMutator) error {
+	if err := checkNetwork(src, network); err != nil {
+		return err
+	}
+
+	client := network.Client()
+	opts := []build.TransactionMutator{
+		build.SourceAccount{AddressOrSeed: src.Keypair.Address()},
+		build.AutoSequence{SequenceProvider: client},
+		network.Mutator(),
+	}
+	opts = append(opts, muts...)
+
+	tx, err := build.Transaction(opts...)
+	if err != nil {
+		return err
+	}
+
+	var signer wallet.Signer
+	if !src.WatchOnly {
+		if err := unlockWallet(src); err != nil {
+			return err
+		}
+
+		signer, err = src.Signer(dbOptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !yes {
+		if summary != nil {
+			printSummaryTable(network, summary)
+		}
+
+		if signer != nil {
+			_, err = (&promptui.Prompt{
+				Label:     "Are you sure",
+				IsConfirm: true,
+			}).Run()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if signer == nil {
+		txe, err := tx.Sign()
+		if err != nil {
+			return err
+		}
+
+		txeB64, err := txe.Base64()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s is watch-only, unsigned transaction envelope:\n", src)
+		fmt.Println(txeB64)
+		return nil
+	}
+
+	txeB64, err := signEnvelope(network, signer, tx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.SubmitTransaction(txeB64)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(resp.Hash)
+	return nil
+}
+
+// signEnvelope returns the envelope of tx signed by signer, encoded in base64.
+func signEnvelope(network wallet.Network, signer wallet.Signer, tx *build.TransactionBuilder) (string, error) {
+	txe, err := tx.Sign()
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := signer.SignTransaction(network.Passphrase, &txe.E.Tx)
+	if err != nil {
+		return "", err
+	}
+	txe.E.Signatures = append(txe.E.Signatures, sig)
+
+	return txe.Base64()
+}
+
+func friendbotFund(network wallet.Network, addr string) {
+	friendBotResp, err := http.Get(network.Horizon + "/friendbot?addr=" + addr)
 	if err != nil {
 		fatal(err)
 	}
 	defer friendBotResp.Body.Close()
 }
+
+// readKeyFile reads a key file to combine with the password.
+func readKeyFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, fmt.Errorf("key file %s is empty", path)
+	}
+
+	return b, nil
+}
+
+func readCertPool(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no PEM certificate found in %s", path)
+	}
+
+	return roots, nil
+}
+
+// readSignerTokens reads a file of remote signer urls, each followed by the
+// token to send to it. Empty lines and lines starting with # are ignored.
+func readSignerTokens(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := map[string]string{}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: should be 'url token'", path, i+1)
+		}
+		if err := wallet.CheckSignerURL(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, i+1, err)
+		}
+		tokens[fields[0]] = fields[1]
+	}
+
+	return tokens, nil
+}
+
+// unlockWallet reads the password of w if it has one of its own, from
+// ALFRED_WALLET_SECRET, stdin when it is not a terminal or a prompt.
+func unlockWallet(w *wallet.Wallet) error {
+	if w.SeedError() != wallet.ErrWalletPassword {
+		return nil
+	}
+
+	password, err := readSecret("ALFRED_WALLET_SECRET", func() (string, error) {
+		return promptPasswordLabel(fmt.Sprintf("Password of %s", w.Name))
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.Unlock([]byte(password))
+}
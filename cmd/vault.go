@@ -0,0 +1,329 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// vaultCmd represents the vault command
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Share the db between members, each accessing only the wallets granted to them",
+	Long: `Turn the db into a team vault shared between members. Each seed is encrypted with its own key, wrapped for the members granted access to the wallet.
+Members unlock the vault either with a password or with an X25519 key file, and are selected with --member (and --member-key). Anyone can open the vault, but only decrypts the wallets granted to them.`,
+	Example: `alfred vault init alice
+alfred vault keygen -o bob.key
+alfred vault add bob --public-key <key printed by keygen> --member alice
+alfred vault grant bob --wallet payroll --member alice
+alfred -d alfred.yaml --member bob --member-key bob.key balances`,
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		vaultListCmd.Run(cmd, args)
+	},
+}
+
+var vaultListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the members of the vault and the wallets granted to them",
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := openAlfred(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		v, err := openedVault(m)
+		if err != nil {
+			fatal(err)
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Member", "Unlocks with", "Wallets"})
+		for _, member := range v.Members {
+			var granted []string
+			for _, w := range m.Stellar.Wallets {
+				for _, name := range w.Access() {
+					if name == member.Name {
+						granted = append(granted, w.Name)
+					}
+				}
+			}
+
+			name := member.Name
+			if member == v.Member() {
+				name += " (you)"
+			}
+
+			unlock := "key"
+			if member.HasPassword() {
+				unlock = "password"
+			}
+
+			table.Append([]string{name, unlock, strings.Join(granted, ", ")})
+		}
+		table.Render()
+	},
+}
+
+var vaultInitCmd = &cobra.Command{
+	Use:   "init <member>",
+	Short: "Turn the db into a team vault",
+	Long: `Turn the db into a team vault, granting every wallet to its first member. The member unlocks the vault with a new password, or with the private key of --public-key.
+Mnemonic roots cannot be kept in a vault, since they give access to every wallet derived from them.`,
+	Example: "alfred vault init alice",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkPassword),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := wallet.OpenSecretString(path, viper.GetString("secret"), dbOptions)
+		if err != nil {
+			fatal(err)
+		}
+
+		member, private, err := newMember(cmd, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := m.InitVault(member, private); err != nil {
+			fatal(err)
+		}
+
+		if err := writeKeepingBackup(cmd, path, m); err != nil {
+			fatal(err)
+		}
+
+		fmt.Printf("vault created, open it with --member %s (or member: %s in the config file)\n", member.Name, member.Name)
+	},
+}
+
+var vaultKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a key to unlock a vault with",
+	Long: `Generate an X25519 key to unlock a vault with instead of a password.
+The private key is written to --output, the public key to give to the members adding you to a vault is printed.`,
+	Example: "alfred vault keygen -o ~/.alfred-vault.key",
+	Run: func(cmd *cobra.Command, args []string) {
+		output := cmd.Flag("output").Value.String()
+		if output == "" {
+			fatal("--output should be set")
+		}
+
+		key, err := wallet.GenerateMemberKey()
+		if err != nil {
+			fatal(err)
+		}
+
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			fatal(err)
+		}
+
+		if _, err := fmt.Fprintln(f, base64.StdEncoding.EncodeToString(key.Bytes())); err != nil {
+			f.Close()
+			fatal(err)
+		}
+
+		if err := f.Close(); err != nil {
+			fatal(err)
+		}
+
+		fmt.Println("public key:", base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()))
+	},
+}
+
+var vaultAddCmd = &cobra.Command{
+	Use:     "add <member>",
+	Short:   "Add a member to the vault, without granting any wallet",
+	Long:    `Add a member to the vault, without granting any wallet. The member unlocks the vault with a new password, or with the private key of --public-key.`,
+	Example: "alfred vault add bob --public-key 0xVZbUiYp8Fw+ro8mqgz4pHrfTprqaaXTbKOq8CCrBk=",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editVault(func(m *wallet.Alfred) error {
+			member, _, err := newMember(cmd, args[0])
+			if err != nil {
+				return err
+			}
+
+			return m.AddMember(member)
+		})
+	},
+}
+
+var vaultGrantCmd = &cobra.Command{
+	Use:     "grant <member>",
+	Short:   "Give a member access to wallets",
+	Long:    `Give a member access to the seed of wallets you have access to.`,
+	Example: "alfred vault grant alice --wallet payroll",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editVaultWallets(cmd, func(m *wallet.Alfred, w *wallet.Wallet) error {
+			return m.Grant(args[0], w)
+		})
+	},
+}
+
+var vaultRevokeCmd = &cobra.Command{
+	Use:   "revoke <member>",
+	Short: "Remove the access of a member to wallets",
+	Long: `Remove the access of a member to the seed of wallets you have access to. The seeds are encrypted with new keys, which the member cannot unwrap.
+The member may have kept the seeds themselves though, move the funds to new accounts to be sure.`,
+	Example: "alfred vault revoke alice --wallet payroll",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editVaultWallets(cmd, func(m *wallet.Alfred, w *wallet.Wallet) error {
+			return m.Revoke(args[0], w)
+		})
+	},
+}
+
+var vaultRemoveCmd = &cobra.Command{
+	Use:     "remove <member>",
+	Short:   "Remove a member from the vault, revoking all their wallets",
+	Example: "alfred vault remove alice",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := confirmRemoval(cmd, fmt.Sprintf("Remove member %s", args[0])); err != nil {
+			fatal(err)
+		}
+
+		editVault(func(m *wallet.Alfred) error {
+			return m.RemoveMember(args[0])
+		})
+	},
+}
+
+func openedVault(m *wallet.Alfred) (*wallet.Vault, error) {
+	switch {
+	case m.Vault() == nil:
+		return nil, fmt.Errorf("db is not a team vault, see alfred vault init")
+	case m.Vault().Member() == nil:
+		return nil, fmt.Errorf("vault is locked, --member should be set")
+	}
+
+	return m.Vault(), nil
+}
+
+func editVault(edit func(*wallet.Alfred) error) {
+	path := viper.GetString("db")
+	m, err := openAlfred(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	if _, err := openedVault(m); err != nil {
+		fatal(err)
+	}
+
+	if err := edit(m); err != nil {
+		fatal(err)
+	}
+
+	if err := wallet.Write(path, m); err != nil {
+		fatal(err)
+	}
+}
+
+func editVaultWallets(cmd *cobra.Command, edit func(*wallet.Alfred, *wallet.Wallet) error) {
+	names, _ := cmd.Flags().GetStringSlice("wallet")
+	if len(names) == 0 {
+		fatal("--wallet should be set")
+	}
+
+	editVault(func(m *wallet.Alfred) error {
+		for _, name := range names {
+			w, err := getOrSelectWallet(m, name)
+			if err != nil {
+				return err
+			}
+
+			if err := edit(m, w); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// newMember returns the member name, unlocking the vault with the private
+// key of --public-key or else with a new password.
+func newMember(cmd *cobra.Command, name string) (*wallet.Member, *wallet.MemberKey, error) {
+	if str := cmd.Flag("public-key").Value.String(); str != "" {
+		pub, err := wallet.ParseMemberPublicKey(str)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		member, err := wallet.NewKeyMember(name, pub)
+		return member, nil, err
+	}
+
+	password, err := readSecret("ALFRED_MEMBER_SECRET", func() (string, error) {
+		fmt.Printf("Password of %s\n", name)
+		return promptNewPassword()
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return wallet.NewPasswordMember(name, []byte(password))
+}
+
+// readMemberKey reads a private key written by alfred vault keygen.
+func readMemberKey(path string) (*wallet.MemberKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet.ParseMemberKey(strings.TrimSpace(string(b)))
+}
+
+func init() {
+	RootCmd.AddCommand(vaultCmd)
+	vaultCmd.AddCommand(vaultListCmd)
+	vaultCmd.AddCommand(vaultInitCmd)
+	vaultCmd.AddCommand(vaultKeygenCmd)
+	vaultCmd.AddCommand(vaultAddCmd)
+	vaultCmd.AddCommand(vaultGrantCmd)
+	vaultCmd.AddCommand(vaultRevokeCmd)
+	vaultCmd.AddCommand(vaultRemoveCmd)
+
+	for _, cmd := range []*cobra.Command{vaultInitCmd, vaultAddCmd} {
+		cmd.Flags().String("public-key", "", "public key of the member, printed by alfred vault keygen, instead of a password")
+	}
+	for _, cmd := range []*cobra.Command{vaultGrantCmd, vaultRevokeCmd} {
+		cmd.Flags().StringSlice("wallet", nil, "name or address of a wallet")
+	}
+	vaultInitCmd.Flags().Bool("no-backup", false, "create the vault even if the db keeps no backups, as dbs with a decoy set")
+	vaultKeygenCmd.Flags().StringP("output", "o", "", "file to write the private key to")
+	vaultRemoveCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+}
@@ -0,0 +1,365 @@
+// Copyright © 2018 Salim Alami Idrissi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/celrenheit/alfred/wallet"
+	"github.com/manifoldco/promptui"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// walletsCmd represents the wallets command
+var walletsCmd = &cobra.Command{
+	Use:     "wallets",
+	Short:   "List and manage wallets",
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		walletsListCmd.Run(cmd, args)
+	},
+}
+
+var walletsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List wallets",
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := openAlfred(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Name", "Address", "Kind", "Network", "Tags"})
+		for _, w := range m.Stellar.Wallets {
+			if !w.Tags.Match(tags) {
+				continue
+			}
+			table.Append([]string{w.Name, w.Keypair.Address(), walletKind(w), walletNetworkName(w), w.Tags.String()})
+		}
+		table.Render()
+	},
+}
+
+var walletsShowCmd = &cobra.Command{
+	Use:     "show [wallet]",
+	Short:   "Show the details of a wallet",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := openAlfred(viper.GetString("db"))
+		if err != nil {
+			fatal(err)
+		}
+
+		w, err := getOrSelectWallet(m, argOrEmpty(args))
+		if err != nil {
+			fatal(err)
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.Append([]string{"Name", w.Name})
+		table.Append([]string{"Address", w.Keypair.Address()})
+		table.Append([]string{"Kind", walletKind(w)})
+		table.Append([]string{"Network", walletNetworkName(w)})
+		if w.Network != nil {
+			table.Append([]string{"Horizon", w.Network.Horizon})
+		}
+		if w.RemoteSigner != "" {
+			table.Append([]string{"Signer", w.RemoteSigner})
+		}
+		if w.Derivation != nil {
+			table.Append([]string{"Derivation", fmt.Sprintf("%s of %s", w.Derivation.Path(), w.Derivation.Root)})
+		}
+		table.Append([]string{"Tags", w.Tags.String()})
+		table.Append([]string{"Notes", w.Notes})
+		table.Render()
+	},
+}
+
+var walletsRenameCmd = &cobra.Command{
+	Use:     "rename <wallet> <new name>",
+	Short:   "Rename a wallet",
+	Args:    cobra.ExactArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		w, err := getOrSelectWallet(m, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := m.RenameWallet(w.Keypair.Address(), args[1]); err != nil {
+			fatal(err)
+		}
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+var walletsRemoveCmd = &cobra.Command{
+	Use:     "remove [wallet]",
+	Short:   "Remove a wallet, a backup of the db is kept",
+	Aliases: []string{"rm"},
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		w, err := getOrSelectWallet(m, argOrEmpty(args))
+		if err != nil {
+			fatal(err)
+		}
+
+		label := fmt.Sprintf("Remove %s", w)
+		if _, ok := w.Full(); ok && w.Derivation == nil {
+			label += ", its seed will be lost unless backed up elsewhere"
+		}
+		if err := confirmRemoval(cmd, label); err != nil {
+			fatal(err)
+		}
+
+		if err := m.RemoveWallet(w.Keypair.Address()); err != nil {
+			fatal(err)
+		}
+
+		if err := writeKeepingBackup(cmd, path, m); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+var walletsTagCmd = &cobra.Command{
+	Use:     "tag <wallet> <tag>...",
+	Short:   "Tag a wallet",
+	Example: "alfred wallets tag savings cold personal",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editWallet(args[0], func(w *wallet.Wallet) { w.Tags.Add(args[1:]...) })
+	},
+}
+
+var walletsUntagCmd = &cobra.Command{
+	Use:     "untag <wallet> <tag>...",
+	Short:   "Remove tags from a wallet",
+	Args:    cobra.MinimumNArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editWallet(args[0], func(w *wallet.Wallet) { w.Tags.Remove(args[1:]...) })
+	},
+}
+
+var walletsNoteCmd = &cobra.Command{
+	Use:     "note <wallet> <notes>",
+	Short:   "Set the notes of a wallet, empty to remove them",
+	Example: `alfred wallets note savings "paper backup in the safe"`,
+	Args:    cobra.ExactArgs(2),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		editWallet(args[0], func(w *wallet.Wallet) { w.Notes = args[1] })
+	},
+}
+
+var walletsPasswdCmd = &cobra.Command{
+	Use:   "passwd <wallet>",
+	Short: "Protect a wallet with a password of its own",
+	Long: `Protect the seed of a wallet with a password of its own, layered on the password of the db. It is read from ALFRED_WALLET_SECRET whenever the wallet signs, or from stdin when it is not a terminal, and asked for otherwise.
+The new password is read from ALFRED_WALLET_NEW_SECRET, or from the next line of stdin when it is not a terminal, and asked for otherwise.
+Changing the password of the db does not need the passwords of the wallets. Use --off to remove the password of a wallet.`,
+	Example: `alfred wallets passwd treasury
+alfred wallets passwd treasury --off`,
+	Args:    cobra.ExactArgs(1),
+	PreRunE: middlewares(checkDB, checkSecret),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.GetString("db")
+		m, err := openAlfred(path)
+		if err != nil {
+			fatal(err)
+		}
+
+		w, err := getOrSelectWallet(m, args[0])
+		if err != nil {
+			fatal(err)
+		}
+
+		if err := unlockWallet(w); err != nil {
+			fatal(err)
+		}
+
+		var password string
+		if off, _ := cmd.Flags().GetBool("off"); !off {
+			if password, err = readSecret("ALFRED_WALLET_NEW_SECRET", promptNewPassword); err != nil {
+				fatal(err)
+			}
+		}
+
+		if err := w.SetPassword([]byte(password)); err != nil {
+			fatal(err)
+		}
+
+		if err := wallet.Write(path, m); err != nil {
+			fatal(err)
+		}
+	},
+}
+
+func editWallet(name string, edit func(*wallet.Wallet)) {
+	path := viper.GetString("db")
+	m, err := openAlfred(path)
+	if err != nil {
+		fatal(err)
+	}
+
+	w, err := getOrSelectWallet(m, name)
+	if err != nil {
+		fatal(err)
+	}
+
+	edit(w)
+
+	if err := wallet.Write(path, m); err != nil {
+		fatal(err)
+	}
+}
+
+func walletKind(w *wallet.Wallet) string {
+	switch {
+	case w.WatchOnly:
+		return "watch-only"
+	case w.RemoteSigner != "":
+		return "remote"
+	case w.Access() != nil && !isFull(w):
+		return "seed, not granted"
+	case w.HasPassword():
+		return "seed, own password"
+	case !isFull(w) && w.SeedError() != nil:
+		return "seed, corrupted"
+	case w.Derivation != nil:
+		return "mnemonic"
+	default:
+		return "seed"
+	}
+}
+
+func isFull(w *wallet.Wallet) bool {
+	_, ok := w.Full()
+	return ok
+}
+
+func walletNetworkName(w *wallet.Wallet) string {
+	if w.Network == nil {
+		return "-"
+	}
+
+	return w.Network.Name
+}
+
+func argOrEmpty(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	return args[0]
+}
+
+// confirmRemoval asks for confirmation unless --yes is set.
+func confirmRemoval(cmd *cobra.Command, label string) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return nil
+	}
+
+	_, err := (&promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}).Run()
+	return err
+}
+
+// writeKeepingBackup writes m to path, keeping a backup of the previous
+// version even if backups are disabled. Directory dbs and dbs with a decoy
+// set keep no backups, writing them is refused unless --no-backup is set.
+func writeKeepingBackup(cmd *cobra.Command, path string, m *wallet.Alfred) error {
+	noBackup, _ := cmd.Flags().GetBool("no-backup")
+
+	file, err := dbFile(path)
+	switch {
+	case err != nil && !noBackup:
+		return fmt.Errorf("%v, version the directory and use --no-backup", err)
+	case err == nil && m.HasDecoy() && !noBackup:
+		return errors.New("dbs with a decoy set keep no backups, use --no-backup")
+	case err != nil || m.HasDecoy():
+		return wallet.Write(path, m)
+	}
+
+	before, err := wallet.ListBackups(file)
+	if err != nil {
+		return err
+	}
+
+	if opts := m.Options(); opts.Backups < 1 {
+		opts.Backups = 1
+	}
+
+	if err := wallet.Write(path, m); err != nil {
+		return err
+	}
+
+	backups, err := wallet.ListBackups(file)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 && (len(before) == 0 || backups[0].Path != before[0].Path) {
+		fmt.Printf("previous version kept in %s\n", backups[0].Path)
+	}
+
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(walletsCmd)
+	walletsCmd.AddCommand(walletsListCmd)
+	walletsCmd.AddCommand(walletsShowCmd)
+	walletsCmd.AddCommand(walletsRenameCmd)
+	walletsCmd.AddCommand(walletsRemoveCmd)
+	walletsCmd.AddCommand(walletsTagCmd)
+	walletsCmd.AddCommand(walletsUntagCmd)
+	walletsCmd.AddCommand(walletsNoteCmd)
+	walletsCmd.AddCommand(walletsPasswdCmd)
+
+	walletsCmd.Flags().StringSlice("tag", nil, "only list wallets having all these tags")
+	walletsListCmd.Flags().StringSlice("tag", nil, "only list wallets having all these tags")
+	walletsRemoveCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+	walletsRemoveCmd.Flags().Bool("no-backup", false, "remove even if the db keeps no backups, as directory dbs and dbs with a decoy set")
+	walletsPasswdCmd.Flags().Bool("off", false, "remove the password of the wallet")
+}
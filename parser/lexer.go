@@ -82,6 +82,21 @@ func (l *lexer) Next() (*token, error) {
 	return &token{kind: tokenIdent, value: value}, nil
 }
 
+// Peek returns the next token without consuming it.
+func (l *lexer) Peek() (*token, error) {
+	pos, err := l.reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := l.Next()
+	if _, serr := l.reader.Seek(pos, io.SeekStart); serr != nil {
+		return nil, serr
+	}
+
+	return tok, err
+}
+
 func (l *lexer) scanIdent() (string, error) {
 	str := ""
 	for {
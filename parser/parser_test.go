@@ -29,6 +29,118 @@ func TestParser(t *testing.T) {
 			Currency: "XLM",
 			To:       "jennifer",
 		}, false},
+		{"send 10 XLM to each of payroll", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			ToGroup:  "payroll",
+		}, false},
+		{"SEND 10 XLM FROM treasury TO EACH OF payroll", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			From:     "treasury",
+			ToGroup:  "payroll",
+		}, false},
+		{"send 10 XLM to GXXX with memo id 12345", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			To:       "GXXX",
+			MemoType: "id",
+			Memo:     "12345",
+		}, false},
+		{`SEND 10 XLM WITH MEMO TEXT "invoice 42" FROM master TO exchange`, &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			From:     "master",
+			To:       "exchange",
+			MemoType: "TEXT",
+			Memo:     "invoice 42",
+		}, false},
+		{"send 100 MOBI to bob using XLM", &SendRequest{
+			Amount:         "100",
+			Currency:       "MOBI",
+			To:             "bob",
+			SourceCurrency: "XLM",
+		}, false},
+		{"SEND 100 MOBI TO bob USING", nil, true},
+		{"send 10 XLM to alice, 25 MOBI to bob and 5 XLM to carol from master", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			From:     "master",
+			To:       "alice",
+			Payments: []Payment{
+				{Amount: "25", Currency: "MOBI", To: "bob"},
+				{Amount: "5", Currency: "XLM", To: "carol"},
+			},
+		}, false},
+		{"SEND 10 XLM, 25 MOBI TO bob", nil, true},
+		{"SEND 10 XLM TO alice AND 25 MOBI bob", nil, true},
+		{"SEND 10 XLM TO alice AND", nil, true},
+		{"SEND 10 XLM TO EACH OF payroll AND 5 XLM TO carol", nil, true},
+		{"SEND 10 XLM TO exchange WITH MEMO id", nil, true},
+		{"SEND 10 XLM TO exchange WITH id 12345", nil, true},
+		{"SEND 10 XLM TO EACH payroll", nil, true},
+		{"SEND 10 XLM TO EACH OF", nil, true},
+		{"send 10 XLM from each to bob", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			From:     "each",
+			To:       "bob",
+		}, false},
+		{"send 10 XLM to each", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			To:       "each",
+		}, false},
+		{"send 10 XLM to of with memo id 1", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			To:       "of",
+			MemoType: "id",
+			Memo:     "1",
+		}, false},
+		{"send 10 XLM from memo to memo with memo text memo", &SendRequest{
+			Amount:   "10",
+			Currency: "XLM",
+			From:     "memo",
+			To:       "memo",
+			MemoType: "text",
+			Memo:     "memo",
+		}, false},
+		{"SEND 10 XLM TO exchange WITH memos id 1", nil, true},
+		{"send all XLM from master to bob", &SendRequest{
+			Amount:     "all",
+			AmountKind: AmountAllKind,
+			Currency:   "XLM",
+			From:       "master",
+			To:         "bob",
+		}, false},
+		{"SEND MAX OF XLM TO bob", &SendRequest{
+			Amount:     "max",
+			AmountKind: AmountAllKind,
+			Currency:   "XLM",
+			To:         "bob",
+		}, false},
+		{"send 50% of MOBI to bob", &SendRequest{
+			Amount:     "50",
+			AmountKind: AmountPercentKind,
+			Currency:   "MOBI",
+			To:         "bob",
+		}, false},
+		{"send 12.5% XLM to bob with memo id 1", &SendRequest{
+			Amount:     "12.5",
+			AmountKind: AmountPercentKind,
+			Currency:   "XLM",
+			To:         "bob",
+			MemoType:   "id",
+			Memo:       "1",
+		}, false},
+		{"SEND 0% OF XLM TO bob", nil, true},
+		{"SEND 150% OF XLM TO bob", nil, true},
+		{"SEND half% OF XLM TO bob", nil, true},
+		{"SEND ALL OF TO bob", nil, true},
+		{"SEND ALL XLM TO EACH OF payroll", nil, true},
+		{"SEND ALL XLM TO alice AND 5 XLM TO bob", nil, true},
+		{"SEND ALL MOBI TO bob USING XLM", nil, true},
 		{"SHARE ACCOUNT master WITH alice, bob, celine", &ShareAccountRequest{
 			Account:            "master",
 			AdditionnalSigners: []string{"alice", "bob", "celine"},
@@ -73,6 +185,21 @@ func TestParser(t *testing.T) {
 				"bar": {SetDataFromString, "hello world"},
 			},
 		}, false},
+		{"set data of=hello", &SetDataRequest{
+			KVs: map[string]DataEntry{
+				"of": {SetDataFromString, "hello"},
+			},
+		}, false},
+		{"set data each = hello", &SetDataRequest{
+			KVs: map[string]DataEntry{
+				"each": {SetDataFromString, "hello"},
+			},
+		}, false},
+		{"set data memo=hello", &SetDataRequest{
+			KVs: map[string]DataEntry{
+				"memo": {SetDataFromString, "hello"},
+			},
+		}, false},
 		{"SET DATA foo", nil, true},
 		{"SET DATA foo = ", nil, true},
 		{`SET DATA foo = "`, nil, true},
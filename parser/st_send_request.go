@@ -1,11 +1,52 @@
 package parser
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 type SendRequest struct {
+	// Amount is the amount to send, or the percentage of the balance when
+	// AmountKind is AmountPercentKind.
+	Amount     string
+	AmountKind SendAmountKind
+	Currency   string
+	From, To   string
+	// ToGroup is set instead of To when sending to each member of a group.
+	ToGroup string
+	// SourceCurrency is set by USING CURRENCY, to pay in Currency while
+	// sending another currency converted through the DEX.
+	SourceCurrency string
+	// MemoType and Memo are set by WITH MEMO TYPE VALUE, replacing the memo
+	// of the recipients.
+	MemoType, Memo string
+	// Payments are the payments following the first one, as in SEND 10 XLM
+	// TO alice, 25 MOBI TO bob AND 5 XLM TO carol, all sent in the same
+	// transaction.
+	Payments []Payment
+}
+
+// SendAmountKind tells how the amount of a SendRequest is given.
+type SendAmountKind int
+
+const (
+	// AmountLiteralKind is a number, as in SEND 10 XLM.
+	AmountLiteralKind SendAmountKind = iota
+	// AmountAllKind is the whole spendable balance, as in SEND ALL XLM or
+	// SEND MAX XLM.
+	AmountAllKind
+	// AmountPercentKind is a percentage of the spendable balance, as in
+	// SEND 50% OF MOBI.
+	AmountPercentKind
+)
+
+// Payment is one of the payments of a SendRequest sending to several
+// recipients.
+type Payment struct {
 	Amount   string
 	Currency string
-	From, To string
+	To       string
 }
 
 func (s *SendRequest) Kind() Kind {
@@ -19,14 +60,30 @@ func (s *SendRequest) parse(l *lexer) error {
 			return err
 		}
 
-		switch tok.kind {
-		case tokenNumber:
+		switch {
+		case tok.kind == tokenNumber:
 			s.Amount = tok.value
-		case tokenIdent:
+		case tok.kind == tokenIdent && i == 0 && isAllAmount(tok.value):
+			s.Amount = strings.ToLower(tok.value)
+			s.AmountKind = AmountAllKind
+			s.Currency, err = parseCurrencyOf(l)
+			i++
+		case tok.kind == tokenIdent && i == 0 && strings.HasSuffix(tok.value, "%"):
+			if s.Amount, err = parsePercent(tok.value); err != nil {
+				return err
+			}
+			s.AmountKind = AmountPercentKind
+			s.Currency, err = parseCurrencyOf(l)
+			i++
+		case tok.kind == tokenIdent:
 			s.Currency = tok.value
 		default:
 			return fmt.Errorf("unexpected token '%v' for '%s', should be AMOUNT CURRENCY", tok.kind, tok.value)
 		}
+
+		if err != nil {
+			return err
+		}
 	}
 
 	var (
@@ -38,15 +95,141 @@ func (s *SendRequest) parse(l *lexer) error {
 		case tokenFrom:
 			s.From, err = parseIdent(l)
 		case tokenTo:
-			s.To, err = parseIdent(l)
+			s.To, s.ToGroup, err = parseDestination(l)
+		case tokenWith:
+			s.MemoType, s.Memo, err = parseMemo(l)
+		case tokenUSING:
+			s.SourceCurrency, err = parseIdent(l)
+		case tokenCOMMA, tokenAND:
+			if s.To == "" && s.ToGroup == "" {
+				return fmt.Errorf("unexpected token '%v' for '%s', the first payment has no destination", tok.kind, tok.value)
+			}
+
+			var p Payment
+			p, err = parsePayment(l)
+			s.Payments = append(s.Payments, p)
 		default:
-			return fmt.Errorf("unexpected token '%v' for '%s', should be only FROM and TO keywords", tok.kind, tok.value)
+			return fmt.Errorf("unexpected token '%v' for '%s', should be only FROM, TO, USING and WITH MEMO keywords", tok.kind, tok.value)
 		}
 
 		if err != nil {
 			return err
 		}
 	}
+	if err != nil {
+		return err
+	}
+
+	if s.ToGroup != "" && len(s.Payments) > 0 {
+		return fmt.Errorf("EACH OF %s cannot be combined with other payments", s.ToGroup)
+	}
+
+	if s.AmountKind != AmountLiteralKind {
+		amount := strings.ToUpper(s.Amount)
+		if s.AmountKind == AmountPercentKind {
+			amount = s.Amount + "%"
+		}
+
+		switch {
+		case len(s.Payments) > 0:
+			return fmt.Errorf("%s cannot be combined with other payments, give the amount of every payment", amount)
+		case s.ToGroup != "":
+			return fmt.Errorf("%s cannot be sent to EACH OF %s, give the amount each one receives", amount, s.ToGroup)
+		case s.SourceCurrency != "":
+			return fmt.Errorf("%s cannot be combined with USING, give the amount to receive", amount)
+		}
+	}
 
 	return nil
 }
+
+func isAllAmount(value string) bool {
+	return strings.EqualFold(value, "all") || strings.EqualFold(value, "max")
+}
+
+// parsePercent returns the number of a percentage such as 50%.
+func parsePercent(value string) (string, error) {
+	number := strings.TrimSuffix(value, "%")
+	pct, err := strconv.ParseFloat(number, 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return "", fmt.Errorf("invalid percentage '%s', should be more than 0%% and at most 100%%", value)
+	}
+
+	return number, nil
+}
+
+// parseCurrencyOf parses the currency following ALL, MAX or a percentage,
+// OF being optional. OF is not a keyword, so that it can still be used as a
+// name elsewhere.
+func parseCurrencyOf(l *lexer) (string, error) {
+	currency, err := parseIdent(l)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.EqualFold(currency, "of") {
+		return parseIdent(l)
+	}
+
+	return currency, nil
+}
+
+// parsePayment parses AMOUNT CURRENCY TO DESTINATION, following a comma or
+// AND.
+func parsePayment(l *lexer) (p Payment, err error) {
+	if p.Amount, err = parseExpect(l, tokenNumber); err != nil {
+		return p, err
+	}
+
+	if p.Currency, err = parseExpect(l, tokenIdent); err != nil {
+		return p, err
+	}
+
+	if _, err = parseExpect(l, tokenTo); err != nil {
+		return p, err
+	}
+
+	p.To, err = parseIdent(l)
+	return p, err
+}
+
+// parseDestination parses either a single destination or EACH OF group.
+// EACH and OF are not keywords, a contact named each is still a destination
+// unless OF follows.
+func parseDestination(l *lexer) (to, group string, err error) {
+	to, err = parseIdent(l)
+	if err != nil || !strings.EqualFold(to, "each") {
+		return to, "", err
+	}
+
+	next, err := l.Peek()
+	if err != nil {
+		return "", "", err
+	}
+	if next.kind != tokenIdent || !strings.EqualFold(next.value, "of") {
+		return to, "", nil
+	}
+
+	l.Next()
+	group, err = parseIdent(l)
+	return "", group, err
+}
+
+// parseMemo parses MEMO TYPE VALUE, once WITH was read. MEMO is not a
+// keyword, so that it can still be used as a name elsewhere.
+func parseMemo(l *lexer) (kind, value string, err error) {
+	memo, err := parseIdent(l)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.EqualFold(memo, "memo") {
+		return "", "", fmt.Errorf("expected MEMO after WITH but got '%s'", memo)
+	}
+
+	if kind, err = parseExpect(l, tokenIdent); err != nil {
+		return "", "", err
+	}
+
+	value, err = parseExpect(l, tokenIdent, tokenNumber, tokenSTRING)
+	return kind, value, err
+}
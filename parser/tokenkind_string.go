@@ -4,13 +4,46 @@ package parser
 
 import "strconv"
 
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[tokenUnknown-0]
+	_ = x[tokenEof-1]
+	_ = x[tokenIdent-2]
+	_ = x[tokenSTRING-3]
+	_ = x[_tokStartKeywords-4]
+	_ = x[tokenSelect-5]
+	_ = x[tokenSend-6]
+	_ = x[tokenSHARE-7]
+	_ = x[tokenACCOUNT-8]
+	_ = x[tokenFrom-9]
+	_ = x[tokenTo-10]
+	_ = x[tokenWith-11]
+	_ = x[tokenWhere-12]
+	_ = x[tokenAND-13]
+	_ = x[tokenSET-14]
+	_ = x[tokenDATA-15]
+	_ = x[tokenBUY-16]
+	_ = x[tokenAT-17]
+	_ = x[tokenFOR-18]
+	_ = x[tokenSELL-19]
+	_ = x[tokenUSING-20]
+	_ = x[_tokEndKeywords-21]
+	_ = x[tokenNumber-22]
+	_ = x[tokenCOMMA-23]
+	_ = x[tokenEQUAL-24]
+	_ = x[tokenQUOTES-25]
+}
+
 const _tokenKind_name = "tokenUnknownEOFIDENTSTRING_tokStartKeywordsSELECTSENDSHAREACCOUNTFROMTOWITHWHEREANDSETDATABUYATFORSELLUSING_tokEndKeywordsNUMBERCOMMAEQUALQUOTES"
 
 var _tokenKind_index = [...]uint8{0, 12, 15, 20, 26, 43, 49, 53, 58, 65, 69, 71, 75, 80, 83, 86, 90, 93, 95, 98, 102, 107, 122, 128, 133, 138, 144}
 
 func (i tokenKind) String() string {
-	if i < 0 || i >= tokenKind(len(_tokenKind_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_tokenKind_index)-1 {
 		return "tokenKind(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _tokenKind_name[_tokenKind_index[i]:_tokenKind_index[i+1]]
+	return _tokenKind_name[_tokenKind_index[idx]:_tokenKind_index[idx+1]]
 }
@@ -0,0 +1,107 @@
+// Package payouts sends the transactions of a payout, saving its progress
+// after every step so that an interrupted payout is resumed without paying
+// anyone twice.
+package payouts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// Statuses of a Batch.
+const (
+	Pending   = "pending"
+	Submitted = "submitted"
+	Paid      = "paid"
+	Failed    = "failed"
+)
+
+// State is the progress of a payout, saved after every transaction so that
+// an interrupted payout can be resumed.
+type State struct {
+	Source string `json:"source"`
+	// Checksum is the sha256 of the payout file, which should not change
+	// until the payout is done.
+	Checksum string `json:"checksum"`
+	// Invalid holds the rows which could not be paid by their line.
+	Invalid map[int]string `json:"invalid,omitempty"`
+	Batches []*Batch       `json:"batches"`
+}
+
+// Batch is a transaction of a payout.
+type Batch struct {
+	Lines []int `json:"lines"`
+	// Memo is the memo the rows of the batch had when the payout was
+	// planned, the memo of its transaction.
+	Memo   string         `json:"memo,omitempty"`
+	Status string         `json:"status"`
+	Failed map[int]string `json:"failed,omitempty"`
+	Error  string         `json:"error,omitempty"`
+	// Sent are the lines paid by the operations of Envelope, in order.
+	Sent     []int  `json:"sent,omitempty"`
+	Envelope string `json:"envelope,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// LoadState reads the state saved to path, and reports whether there was
+// one.
+func LoadState(path string) (*State, bool, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var state State
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, false, err
+	}
+
+	return &state, true, nil
+}
+
+// Save writes the state to path through a temporary file, so that an
+// interruption never leaves it half written.
+func (s *State) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Done reports whether every batch is paid or failed.
+func (s *State) Done() bool {
+	for _, b := range s.Batches {
+		if b.Status != Paid && b.Status != Failed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Count returns the number of rows paid and failed.
+func (s *State) Count() (paid, failed int) {
+	failed = len(s.Invalid)
+	for _, b := range s.Batches {
+		for _, line := range b.Lines {
+			switch _, rowFailed := b.Failed[line]; {
+			case rowFailed || b.Status == Failed:
+				failed++
+			case b.Status == Paid:
+				paid++
+			}
+		}
+	}
+
+	return paid, failed
+}
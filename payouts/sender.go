@@ -0,0 +1,150 @@
+package payouts
+
+// Horizon is the part of horizon a payout needs.
+type Horizon interface {
+	// Applied tells whether the transaction hash was applied successfully.
+	Applied(hash string) (bool, error)
+	// Submit submits a signed transaction envelope. It returns a *Rejected
+	// error when the transaction was rejected, and so not applied; other
+	// errors leave its outcome unknown.
+	Submit(envelope string) error
+}
+
+// Rejected is returned by Horizon.Submit for transactions which were not
+// applied.
+type Rejected struct {
+	// Code is the result code of the transaction, such as tx_bad_seq.
+	Code string
+	// Operations are the result codes of the operations, for tx_failed.
+	Operations []string
+	// Message describes the rejection.
+	Message string
+}
+
+func (r *Rejected) Error() string { return r.Message }
+
+// Transaction is a signed transaction paying the rows Sent, in the order of
+// its operations.
+type Transaction struct {
+	Envelope string
+	Hash     string
+	Sent     []int
+}
+
+// Sender sends the batches of a State.
+type Sender struct {
+	Horizon Horizon
+	// Build signs the transaction paying lines with memo and a fresh
+	// sequence number, adding the rows which cannot be paid to failed. It
+	// returns nil when none is left.
+	Build func(lines []int, memo string, failed map[int]string) (*Transaction, error)
+	// Save is called after every step.
+	Save func() error
+}
+
+// Pay sends b until it is paid or failed. Errors returned leave b in a
+// state from which the payout can be resumed: a transaction whose outcome
+// is unknown is looked up before being submitted again.
+func (s *Sender) Pay(b *Batch) error {
+	for {
+		switch b.Status {
+		case Pending:
+			if err := s.build(b); err != nil {
+				return err
+			}
+		case Submitted:
+			if err := s.submit(b); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+
+		if err := s.Save(); err != nil {
+			return err
+		}
+	}
+}
+
+// build signs the transaction paying the rows of b which did not fail.
+func (s *Sender) build(b *Batch) error {
+	if b.Failed == nil {
+		b.Failed = make(map[int]string)
+	}
+
+	var lines []int
+	for _, line := range b.Lines {
+		if _, failed := b.Failed[line]; !failed {
+			lines = append(lines, line)
+		}
+	}
+
+	tx, err := s.Build(lines, b.Memo, b.Failed)
+	if err != nil {
+		return err
+	}
+
+	if tx == nil || len(tx.Sent) == 0 {
+		b.Status = Failed
+		b.Error = "no payment left to send"
+		return nil
+	}
+
+	b.Envelope, b.Hash, b.Sent = tx.Envelope, tx.Hash, tx.Sent
+	b.Status = Submitted
+	return nil
+}
+
+// submit submits the envelope of b, unless it was already applied. Rows
+// failing on their own are excluded and the batch is built again.
+func (s *Sender) submit(b *Batch) error {
+	applied, err := s.Horizon.Applied(b.Hash)
+	if err != nil {
+		return err
+	}
+
+	if !applied {
+		err = s.Horizon.Submit(b.Envelope)
+	}
+
+	rejected, ok := err.(*Rejected)
+	switch {
+	case err == nil:
+	case !ok:
+		// the transaction may still be applied, it is looked up on resume
+		return err
+	case rejected.Code == "tx_bad_seq":
+		// the sequence may have been used by this very envelope, submitted
+		// before an interruption and applied since it was looked up
+		if applied, err = s.Horizon.Applied(b.Hash); err != nil {
+			return err
+		}
+		if !applied {
+			// built again with the current sequence
+			b.Status = Pending
+			b.Envelope, b.Hash, b.Sent = "", "", nil
+			return nil
+		}
+	case rejected.Code == "tx_failed" && len(rejected.Operations) == len(b.Sent):
+		if b.Failed == nil {
+			b.Failed = make(map[int]string)
+		}
+		for i, code := range rejected.Operations {
+			if code != "op_success" {
+				b.Failed[b.Sent[i]] = code
+			}
+		}
+		b.Status = Pending
+		b.Envelope, b.Hash, b.Sent = "", "", nil
+		return nil
+	default:
+		b.Status = Failed
+		b.Error = rejected.Message
+		b.Envelope, b.Hash, b.Sent = "", "", nil
+		return nil
+	}
+
+	b.Status = Paid
+	b.Envelope, b.Sent = "", nil
+	return nil
+}
@@ -0,0 +1,225 @@
+package payouts
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// horizon applies the envelopes submitted, unless they are rejected.
+type horizon struct {
+	applied map[string]bool
+	// reject holds the rejections of the next submissions.
+	reject []error
+	// interrupt applies the envelopes of the next submissions, but loses
+	// their result.
+	interrupt int
+	// bump applies another transaction before the next submission.
+	bump      bool
+	submitted int
+}
+
+func (h *horizon) Applied(hash string) (bool, error) { return h.applied[hash], nil }
+
+func (h *horizon) Submit(envelope string) error {
+	h.submitted++
+	if h.bump {
+		h.bump = false
+		return &Rejected{Code: "tx_bad_seq", Message: "bad sequence"}
+	}
+	if h.applied[envelope] {
+		// its sequence is used
+		return &Rejected{Code: "tx_bad_seq", Message: "bad sequence"}
+	}
+	if len(h.reject) > 0 {
+		err := h.reject[0]
+		h.reject = h.reject[1:]
+		return err
+	}
+
+	h.applied[envelope] = true
+	if h.interrupt > 0 {
+		h.interrupt--
+		return errors.New("timeout")
+	}
+
+	return nil
+}
+
+func newSender(h *horizon) (*Sender, *int) {
+	builds := 0
+	return &Sender{
+		Horizon: h,
+		Build: func(lines []int, memo string, failed map[int]string) (*Transaction, error) {
+			builds++
+			if len(lines) == 0 {
+				return nil, nil
+			}
+			// the envelope is its own hash
+			hash := fmt.Sprintf("tx%d", builds)
+			return &Transaction{Envelope: hash, Hash: hash, Sent: lines}, nil
+		},
+		Save: func() error { return nil },
+	}, &builds
+}
+
+func TestPay(t *testing.T) {
+	var tests = []struct {
+		name       string
+		batch      Batch
+		horizon    horizon
+		wantStatus string
+		wantFailed map[int]string
+		wantBuilds int
+		wantErr    bool
+	}{
+		{
+			name:       "paid",
+			batch:      Batch{Lines: []int{1, 2}, Status: Pending},
+			wantStatus: Paid,
+			wantBuilds: 1,
+		},
+		{
+			name:       "resumed, applied before the interruption",
+			batch:      Batch{Lines: []int{1, 2}, Status: Submitted, Sent: []int{1, 2}, Envelope: "tx0", Hash: "tx0"},
+			horizon:    horizon{applied: map[string]bool{"tx0": true}},
+			wantStatus: Paid,
+		},
+		{
+			name:       "resumed, not applied yet",
+			batch:      Batch{Lines: []int{1, 2}, Status: Submitted, Sent: []int{1, 2}, Envelope: "tx0", Hash: "tx0"},
+			wantStatus: Paid,
+		},
+		{
+			name:       "sequence used by another transaction",
+			batch:      Batch{Lines: []int{1, 2}, Status: Pending},
+			horizon:    horizon{bump: true},
+			wantStatus: Paid,
+			wantBuilds: 2,
+		},
+		{
+			name:  "some operations failed",
+			batch: Batch{Lines: []int{1, 2, 3}, Status: Pending},
+			horizon: horizon{reject: []error{
+				&Rejected{Code: "tx_failed", Operations: []string{"op_success", "op_no_destination", "op_success"}},
+			}},
+			wantStatus: Paid,
+			wantFailed: map[int]string{2: "op_no_destination"},
+			wantBuilds: 2,
+		},
+		{
+			name:  "every operation failed",
+			batch: Batch{Lines: []int{1}, Status: Submitted, Sent: []int{1}, Envelope: "tx0", Hash: "tx0"},
+			horizon: horizon{reject: []error{
+				&Rejected{Code: "tx_failed", Operations: []string{"op_underfunded"}},
+			}},
+			wantStatus: Failed,
+			wantFailed: map[int]string{1: "op_underfunded"},
+			wantBuilds: 1,
+		},
+		{
+			name:       "rejected",
+			batch:      Batch{Lines: []int{1}, Status: Pending},
+			horizon:    horizon{reject: []error{&Rejected{Code: "tx_insufficient_balance", Message: "insufficient balance"}}},
+			wantStatus: Failed,
+			wantBuilds: 1,
+		},
+		{
+			name:       "outcome unknown",
+			batch:      Batch{Lines: []int{1}, Status: Pending},
+			horizon:    horizon{reject: []error{errors.New("timeout")}},
+			wantStatus: Submitted,
+			wantBuilds: 1,
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		h := test.horizon
+		if h.applied == nil {
+			h.applied = make(map[string]bool)
+		}
+		s, builds := newSender(&h)
+		b := test.batch
+
+		err := s.Pay(&b)
+		if test.wantErr {
+			require.Error(t, err, test.name)
+		} else {
+			require.NoError(t, err, test.name)
+		}
+		require.Equal(t, test.wantStatus, b.Status, test.name)
+		require.Equal(t, test.wantBuilds, *builds, test.name)
+		if test.wantFailed != nil {
+			require.Equal(t, test.wantFailed, b.Failed, test.name)
+		} else {
+			require.Empty(t, b.Failed, test.name)
+		}
+		if b.Status == Paid {
+			require.True(t, h.applied[b.Hash], test.name)
+		}
+	}
+}
+
+// TestPayResumed covers an envelope applied while its result was lost: the
+// payout is resumed before horizon lists it, and submitting it again finds
+// its sequence used.
+func TestPayResumed(t *testing.T) {
+	h := &horizon{applied: make(map[string]bool), interrupt: 1}
+	s, builds := newSender(h)
+
+	b := &Batch{Lines: []int{1, 2}, Status: Pending}
+	require.Error(t, s.Pay(b))
+	require.Equal(t, Submitted, b.Status)
+
+	// applied, but not listed yet when first looked up
+	lookups := 0
+	s.Horizon = lookup{h, func(hash string) bool {
+		lookups++
+		return lookups > 1 && h.applied[hash]
+	}}
+
+	require.NoError(t, s.Pay(b))
+	require.Equal(t, Paid, b.Status)
+	require.Equal(t, 1, *builds, "paid twice")
+	require.Equal(t, 2, h.submitted)
+}
+
+// lookup overrides the transactions horizon lists.
+type lookup struct {
+	*horizon
+	applied func(hash string) bool
+}
+
+func (l lookup) Applied(hash string) (bool, error) { return l.applied(hash), nil }
+
+// TestPayMemo covers rows whose memo changed before the batch was built:
+// they fail instead of being paid with the memo of the batch.
+func TestPayMemo(t *testing.T) {
+	h := &horizon{applied: make(map[string]bool)}
+	s, _ := newSender(h)
+
+	memos := map[int]string{1: "id 42", 2: "id 43", 3: "id 42"}
+	var paid []int
+	build := s.Build
+	s.Build = func(lines []int, memo string, failed map[int]string) (*Transaction, error) {
+		var same []int
+		for _, line := range lines {
+			if memos[line] != memo {
+				failed[line] = "memo changed"
+				continue
+			}
+			same = append(same, line)
+		}
+		paid = same
+		return build(same, memo, failed)
+	}
+
+	b := &Batch{Lines: []int{1, 2, 3}, Memo: "id 42", Status: Pending}
+	require.NoError(t, s.Pay(b))
+	require.Equal(t, Paid, b.Status)
+	require.Equal(t, []int{1, 3}, paid)
+	require.Equal(t, map[int]string{2: "memo changed"}, b.Failed)
+}
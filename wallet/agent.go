@@ -0,0 +1,225 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Agent keeps a db unlocked and encrypts, decrypts and signs for it over a
+// Unix socket, until it is locked or stays idle for Timeout. Like ssh-agent,
+// it never hands out the key of the db, so nothing can be decrypted once it
+// is locked.
+type Agent struct {
+	// Path is the absolute location of the db, as returned by Store.String.
+	Path    string
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	m      *Alfred
+	signer *SignerServer
+	timer  *time.Timer
+	l      net.Listener
+}
+
+// NewAgent returns an agent for the unlocked db m stored at path.
+func NewAgent(path string, m *Alfred, timeout time.Duration) (*Agent, error) {
+	switch {
+	case !m.IsUnlocked():
+		return nil, errors.New("db should be unlocked")
+	case m.Vault() != nil:
+		return nil, errors.New("team vaults cannot be kept unlocked by an agent")
+	case m.HasDecoy():
+		return nil, errors.New("dbs with a decoy set cannot be kept unlocked by an agent")
+	}
+
+	store, err := NewStore(path, &m.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []*Wallet
+	for _, w := range m.Stellar.Wallets {
+		if _, ok := w.Full(); ok {
+			wallets = append(wallets, w)
+		}
+	}
+
+	return &Agent{
+		Path:    store.String(),
+		Timeout: timeout,
+		m:       m,
+		signer:  &SignerServer{Wallets: wallets},
+	}, nil
+}
+
+// Serve serves requests on l until the agent is locked.
+func (a *Agent) Serve(l net.Listener) error {
+	a.mu.Lock()
+	a.l = l
+	if a.Timeout > 0 {
+		a.timer = time.AfterFunc(a.Timeout, a.Lock)
+	}
+	a.mu.Unlock()
+
+	err := http.Serve(l, a)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.m == nil {
+		// locked
+		return nil
+	}
+	return err
+}
+
+// Lock forgets the key and the seeds and stops serving.
+func (a *Agent) Lock() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.m == nil {
+		return
+	}
+
+	a.m.Lock()
+	a.m = nil
+	a.signer.Wallets = nil
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	if a.l != nil {
+		a.l.Close()
+	}
+}
+
+type agentStatus struct {
+	DB string `json:"db"`
+}
+
+// agentCipherRequest asks to encrypt or decrypt Data with the key derived
+// with KDF, authenticating AD along with it.
+type agentCipherRequest struct {
+	KDF  KDF    `json:"kdf"`
+	Data []byte `json:"data"`
+	AD   []byte `json:"ad,omitempty"`
+}
+
+type agentCipherResponse struct {
+	Data []byte `json:"data"`
+}
+
+// ServeHTTP handles requests one at a time, they are few and short.
+func (a *Agent) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.m == nil {
+		writeSignerError(rw, http.StatusServiceUnavailable, errors.New("agent is locked"))
+		return
+	}
+	if a.timer != nil {
+		a.timer.Reset(a.Timeout)
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/status":
+		json.NewEncoder(rw).Encode(agentStatus{DB: a.Path})
+	case r.Method == http.MethodPost && (r.URL.Path == "/encrypt" || r.URL.Path == "/decrypt"):
+		var req agentCipherRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeSignerError(rw, http.StatusBadRequest, err)
+			return
+		}
+
+		if req.KDF != a.m.KDF() {
+			// the password was changed since the agent was started
+			writeSignerError(rw, http.StatusNotFound, errors.New("agent has no key for these parameters"))
+			return
+		}
+
+		var resp agentCipherResponse
+		var err error
+		if r.URL.Path == "/encrypt" {
+			resp.Data, err = a.m.secret.encrypt(req.Data, req.AD)
+		} else {
+			resp.Data, err = a.m.secret.decrypt(req.Data, req.AD)
+		}
+		if err != nil {
+			writeSignerError(rw, http.StatusBadRequest, err)
+			return
+		}
+		json.NewEncoder(rw).Encode(resp)
+	case r.Method == http.MethodPost && r.URL.Path == "/lock":
+		json.NewEncoder(rw).Encode(struct{}{})
+		go a.Lock()
+	default:
+		a.signer.ServeHTTP(rw, r)
+	}
+}
+
+// AgentClient talks to an agent.
+type AgentClient struct {
+	URL string
+	// DB is the absolute location of the db unlocked by the agent.
+	DB string
+
+	client *http.Client
+	base   string
+}
+
+// DialAgent connects to the agent listening at url.
+func DialAgent(url string) (*AgentClient, error) {
+	client, base := signerClient(url, nil)
+	client.Timeout = 5 * time.Second
+
+	var status agentStatus
+	if err := signerCall(client, "", http.MethodGet, base+"/status", nil, &status); err != nil {
+		return nil, err
+	}
+
+	return &AgentClient{
+		URL:    url,
+		DB:     status.DB,
+		client: client,
+		base:   base,
+	}, nil
+}
+
+// agentKey encrypts and decrypts through the agent holding the key derived
+// with kdf.
+type agentKey struct {
+	client *AgentClient
+	kdf    KDF
+}
+
+func (k *agentKey) encrypt(plaintext, ad []byte) ([]byte, error) {
+	return k.call("/encrypt", plaintext, ad)
+}
+
+func (k *agentKey) decrypt(ciphertext, ad []byte) ([]byte, error) {
+	return k.call("/decrypt", ciphertext, ad)
+}
+
+func (k *agentKey) call(op string, data, ad []byte) ([]byte, error) {
+	var resp agentCipherResponse
+	err := signerCall(k.client.client, "", http.MethodPost, k.client.base+op, agentCipherRequest{
+		KDF:  k.kdf,
+		Data: data,
+		AD:   ad,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %v", err)
+	}
+
+	return resp.Data, nil
+}
+
+// Lock locks the agent immediately.
+func (c *AgentClient) Lock() error {
+	return signerCall(c.client, "", http.MethodPost, c.base+"/lock", nil, &struct{}{})
+}
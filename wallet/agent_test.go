@@ -0,0 +1,97 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	m, err := Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("main", kp)))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+	agent, err := NewAgent(path, m, time.Minute)
+	require.NoError(t, err)
+
+	socket := "unix://" + filepath.Join(dir, "agent.sock")
+	l, err := ListenSigner(socket, nil)
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- agent.Serve(l) }()
+
+	c, err := DialAgent(socket)
+	require.NoError(t, err)
+	require.Equal(t, path, c.DB)
+
+	m, err = OpenAgent(path, c, nil)
+	require.NoError(t, err)
+	w := m.WalletByName("main")
+	_, ok := w.Full()
+	require.True(t, ok)
+
+	// written through the agent, which never gave the key
+	w.Name = "renamed"
+	require.NoError(t, Write(path, m))
+	m, err = Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+	require.NotNil(t, m.WalletByName("renamed"))
+
+	remotes, err := ListRemoteWallets(socket, nil)
+	require.NoError(t, err)
+	require.Len(t, remotes, 1)
+
+	// seeds are decrypted when needed, the agent has to be unlocked
+	opened, err := OpenAgent(path, c, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, c.Lock())
+	require.NoError(t, <-done)
+	_, ok = opened.WalletByName("renamed").Full()
+	require.False(t, ok)
+
+	_, err = DialAgent(socket)
+	require.Error(t, err)
+}
+
+func TestAgentTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	m, err := Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+
+	agent, err := NewAgent(path, m, 50*time.Millisecond)
+	require.NoError(t, err)
+
+	l, err := ListenSigner("unix://"+filepath.Join(dir, "agent.sock"), nil)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- agent.Serve(l) }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not lock")
+	}
+	require.False(t, m.IsUnlocked())
+}
@@ -1,73 +1,316 @@
 package wallet
 
 import (
-	"crypto/sha256"
 	"encoding/base64"
 	"errors"
-	"io/ioutil"
-	"os"
-
-	"gopkg.in/yaml.v2"
+	"fmt"
 
 	"github.com/stellar/go/keypair"
 )
 
 type Alfred struct {
-	Stellar WalletsManager `yaml:"stellar,omitempty"`
-	secret  []byte
+	Stellar  WalletsManager `yaml:"stellar,omitempty"`
+	kdf      KDF
+	opts     Options
+	password []byte
+	// agent holds the key instead of the password, when opened through
+	// an agent.
+	agent    *AgentClient
+	secret   dbKey
+	checksum []byte
+	sealed   bool
+	vault    *Vault
+	// padding is the beginning of the padding of the file, up to its outer
+	// layer, outer the content of that layer and paddingKey its key (see
+	// decoy.go). decoy is the visible part of the file when the padding
+	// holds the wallets.
+	padding    []byte
+	paddingKey []byte
+	outer      []byte
+	decoy      *alfredyaml
+	// skipped are the entries which could not be decrypted, left out of
+	// the db and written back as they are.
+	skipped []skippedEntry
+	// invalid are the problems of the file, when loaded with DiagnoseSchema.
+	invalid []*SchemaError
+}
+
+type skippedEntry struct {
+	// subject describes the entry, such as "contact bob".
+	subject string
+	// name is the file of the entry, in a directory store.
+	name string
+	// root is the entry of a root, in a file store.
+	root *rootyaml
+}
+
+// Skipped describes the contacts and roots which were left out because they
+// could not be decrypted, such as "contact bob". They are kept as they are
+// in the db.
+func (a *Alfred) Skipped() []string {
+	var subjects []string
+	for _, e := range a.skipped {
+		subjects = append(subjects, e.subject)
+	}
+
+	return subjects
 }
 
-func (a *Alfred) Unlock(secret []byte) error {
-	if secret == nil {
+// Unlock derives the encryption key from the password using the key
+// derivation parameters of the file, combined with the key file of its
+// options if the file requires it. A fresh salt is generated if the file does
+// not have any yet, the file then requiring a key file if one is set.
+func (a *Alfred) Unlock(password []byte) error {
+	if password == nil {
 		return nil
 	}
 
-	h := sha256.New()
-	h.Write([]byte(secret))
-	a.secret = h.Sum(nil)
+	if a.kdf.Name == "" {
+		kdf, err := NewKDF()
+		if err != nil {
+			return err
+		}
+		kdf.KeyFile = a.opts.KeyFile != nil
+		a.kdf = kdf
+	}
+
+	secret, err := a.kdf.deriveKeyFile(password, a.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	a.password = password
+	a.secret = secretKey(secret)
+	return nil
+}
+
+func (a *Alfred) unlock() error {
+	if a.agent == nil {
+		return a.Unlock(a.password)
+	}
+
+	a.secret = &agentKey{client: a.agent, kdf: a.kdf}
 	return nil
 }
 
+// Lock forgets the encryption key and the seeds, as far as the runtime
+// allows it.
+func (a *Alfred) Lock() {
+	wipe(a.password)
+	wipeKey(a.secret)
+	wipe(a.paddingKey)
+	wipe(a.outer)
+	a.password, a.secret, a.agent = nil, nil, nil
+	a.paddingKey, a.outer = nil, nil
+
+	for _, w := range a.Stellar.Wallets {
+		if kp, ok := w.Keypair.(*keypair.Full); ok {
+			w.Keypair = keypair.MustParse(kp.Address())
+		}
+		w.seed = nil
+		if w.password != nil {
+			wipe(w.password.key)
+			w.password.key = nil
+		}
+	}
+	for _, r := range a.Stellar.Roots {
+		wipe(r.Seed)
+		r.Seed = nil
+	}
+	if a.vault != nil {
+		a.vault.lock()
+	}
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 func (a *Alfred) IsUnlocked() bool { return a.secret != nil }
 
+// KDF returns the key derivation parameters of the file.
+func (a *Alfred) KDF() KDF { return a.kdf }
+
+// upgradable reports whether the key derivation parameters of the file are
+// weaker than DefaultKDF and can be upgraded.
+func (a *Alfred) upgradable() bool {
+	return a.IsUnlocked() && a.password != nil && a.vault == nil && a.decoy == nil && a.kdf.Weaker(DefaultKDF)
+}
+
+// UpgradeBlocked returns an error naming the wallet which keeps the file on
+// key derivation parameters weaker than DefaultKDF: its seed could not be
+// decrypted, and would be lost under a new key.
+func (a *Alfred) UpgradeBlocked() error {
+	if !a.upgradable() {
+		return nil
+	}
+
+	if err := a.checkSeeds(); err != nil {
+		return fmt.Errorf("%v, it keeps the password on a weak KDF until it is restored or removed", err)
+	}
+
+	return nil
+}
+
+// upgradeKDF re-derives the key with fresh default parameters when the
+// current ones are weaker, so that old files are migrated on the next write.
+// It does nothing while UpgradeBlocked returns an error.
+func (a *Alfred) upgradeKDF() error {
+	if !a.upgradable() || a.UpgradeBlocked() != nil {
+		return nil
+	}
+
+	kdf, err := NewKDF()
+	if err != nil {
+		return err
+	}
+	kdf.KeyFile = a.kdf.KeyFile
+
+	secret, err := kdf.deriveKeyFile(a.password, a.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	a.kdf = kdf
+	a.secret = secretKey(secret)
+	return nil
+}
+
 type alfredyaml struct {
-	Stellar struct {
-		Wallets  []walletyaml       `yaml:"wallets,omitempty"`
-		Contacts map[string]Contact `yaml:"contacts,omitempty"`
-	} `yaml:"stellar,omitempty"`
+	Version int         `yaml:"version,omitempty"`
+	KDF     *KDF        `yaml:"kdf,omitempty"`
+	Stellar stellaryaml `yaml:"stellar,omitempty"`
+	Sealed  string      `yaml:"sealed,omitempty"`
+	Public  *publicyaml `yaml:"public,omitempty"`
+	Vault   *vaultyaml  `yaml:"vault,omitempty"`
+	Padding string      `yaml:"padding,omitempty"`
+	// Check is encrypted with the key of the db, to tell a wrong password
+	// from corrupted seeds.
+	Check string `yaml:"check,omitempty"`
+}
+
+type stellaryaml struct {
+	Wallets  []walletyaml       `yaml:"wallets,omitempty"`
+	Contacts map[string]Contact `yaml:"contacts,omitempty"`
+	Roots    []rootyaml         `yaml:"roots,omitempty"`
+}
+
+type rootyaml struct {
+	ID   string `yaml:"id"`
+	Seed string `yaml:"seed,omitempty"`
 }
 
 type walletyaml struct {
-	Name    string `yaml:"name,omitempty"`
-	Address string `yaml:"address,omitempty"`
-	Seed    string `yaml:"seed,omitempty"`
+	Name         string      `yaml:"name,omitempty"`
+	Address      string      `yaml:"address,omitempty"`
+	Seed         string      `yaml:"seed,omitempty"`
+	WatchOnly    bool        `yaml:"watch_only,omitempty"`
+	RemoteSigner string      `yaml:"remote_signer,omitempty"`
+	Derivation   *Derivation `yaml:"derivation,omitempty"`
+	Network      *Network    `yaml:"network,omitempty"`
+	Tags         Tags        `yaml:"tags,omitempty"`
+	Notes        string      `yaml:"notes,omitempty"`
+	// Password is set when the seed is also encrypted with a password of
+	// its own.
+	Password *KDF `yaml:"password,omitempty"`
+	// Access holds the key of the seed wrapped for the members of a vault.
+	Access map[string]string `yaml:"access,omitempty"`
 }
 
 func (a Alfred) MarshalYAML() (interface{}, error) {
+	return a.yaml()
+}
+
+func (a *Alfred) yaml() (alfredyaml, error) {
 	if a.secret == nil {
-		return nil, errors.New("no secret set")
+		return alfredyaml{}, errors.New("no secret set")
 	}
 
-	j := alfredyaml{}
+	j := alfredyaml{
+		Version: FormatVersion,
+	}
+	if a.vault != nil {
+		if len(a.Stellar.Roots) > 0 {
+			return alfredyaml{}, errors.New("mnemonic roots cannot be kept in a team vault, they give access to every wallet derived from them")
+		}
+		j.Vault = a.vault.yaml()
+	} else {
+		kdf := a.kdf
+		j.KDF = &kdf
+
+		check, err := a.secret.encrypt(nil, a.checkAD())
+		if err != nil {
+			return alfredyaml{}, err
+		}
+		j.Check = base64.RawStdEncoding.EncodeToString(check)
+	}
 	j.Stellar.Contacts = a.Stellar.Contacts
 	for _, w := range a.Stellar.Wallets {
-		kp, ok := w.Keypair.(*keypair.Full)
-		if !ok || a.secret == nil {
-			return nil, errors.New("you should unlock alfred for writing")
+		wj := w.yaml()
+		if a.vault != nil && w.hasSeed() {
+			if err := a.vault.sealWallet(w, &wj); err != nil {
+				return alfredyaml{}, err
+			}
+		} else if w.hasSeed() {
+			seed, err := w.sealSeed(a.secret)
+			if err != nil {
+				return alfredyaml{}, err
+			}
+			wj.Seed = seed
+			wj.Password = w.passwordKDF()
 		}
-		seed := getSeed(kp.Seed())
-		encrypted, err := encrypt(a.secret, seed)
+
+		j.Stellar.Wallets = append(j.Stellar.Wallets, wj)
+	}
+
+	for _, r := range a.Stellar.Roots {
+		if r.Seed == nil {
+			return alfredyaml{}, errors.New("you should unlock alfred for writing")
+		}
+
+		encrypted, err := a.secret.encrypt(r.Seed, nil)
 		if err != nil {
-			return nil, err
+			return alfredyaml{}, err
 		}
 
-		encoded := base64.RawStdEncoding.EncodeToString(encrypted)
-		j.Stellar.Wallets = append(j.Stellar.Wallets, walletyaml{
-			Name:    w.Name,
-			Address: kp.Address(),
-			Seed:    encoded,
+		j.Stellar.Roots = append(j.Stellar.Roots, rootyaml{
+			ID:   r.ID,
+			Seed: base64.RawStdEncoding.EncodeToString(encrypted),
 		})
 	}
+	for _, e := range a.skipped {
+		if e.root != nil && a.RootByID(e.root.ID) == nil {
+			j.Stellar.Roots = append(j.Stellar.Roots, *e.root)
+		}
+	}
+
+	if a.decoy != nil {
+		return a.hiddenYAML(j.Stellar)
+	}
+
+	if a.sealed {
+		if err := j.seal(a.secret); err != nil {
+			return alfredyaml{}, err
+		}
+	}
+
+	if a.paddingKey == nil {
+		if err := a.newPadding(); err != nil {
+			return alfredyaml{}, err
+		}
+	}
+	if key, ok := a.secret.(secretKey); ok {
+		// for the real password, see hiddenYAML
+		copy(a.outer[:keySize], key)
+	}
+
+	padding, err := a.sealPadding(a.secret)
+	if err != nil {
+		return alfredyaml{}, err
+	}
+	j.Padding = base64.RawStdEncoding.EncodeToString(padding)
 
 	return j, nil
 }
@@ -79,97 +322,362 @@ func (a *Alfred) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	if err := checkVersion(aj.Version); err != nil {
+		return err
+	}
+
+	if err := a.openHidden(&aj); err != nil {
+		return err
+	}
+
+	switch {
+	case a.decoy != nil:
+		// unlocked by the padding, aj now holds the real wallets
+	case aj.Vault != nil:
+		v, err := aj.Vault.vault()
+		if err != nil {
+			return err
+		}
+
+		key, err := v.unlock(a.password, a.opts.Member, a.opts.MemberKey)
+		if err != nil {
+			return err
+		}
+		if key != nil {
+			a.secret = secretKey(key)
+		}
+		a.vault = v
+	default:
+		a.kdf = legacyKDF()
+		if aj.KDF != nil {
+			a.kdf = *aj.KDF
+		}
+
+		if err := a.unlock(); err != nil {
+			return err
+		}
+	}
+
+	if aj.Sealed != "" {
+		a.sealed = true
+		if err := a.keepInvalid(aj.unseal(a.secret)); err != nil {
+			return err
+		}
+	}
+
+	if err := a.checkKey(&aj); err != nil {
+		return err
+	}
+
+	if err := a.openVisiblePadding(&aj); err != nil {
+		return err
+	}
+
 	for _, j := range aj.Stellar.Wallets {
-		w := &Wallet{}
-		w.Name = j.Name
-		if a.secret == nil {
-			var err error
-			w.Keypair, err = keypair.Parse(j.Address)
-			if err != nil {
-				return err
-			}
-		} else {
-			decoded, err := base64.RawStdEncoding.DecodeString(j.Seed)
-			if err != nil {
-				return err
-			}
+		w, err := j.wallet()
+		if err != nil {
+			return err
+		}
 
-			seed, err := decrypt(a.secret, decoded)
-			if err != nil {
+		if a.vault != nil && w.hasSeed() {
+			if err := a.vault.openWallet(w, j); err != nil {
 				return err
 			}
+		} else if a.secret != nil && w.hasSeed() {
+			// decrypted when needed, see Wallet.Full
+			w.seed = &lockedSeed{encoded: j.Seed, key: a.secret}
+		}
+
+		a.Stellar.Wallets = append(a.Stellar.Wallets, w)
+	}
 
-			var seed32 [32]byte
-			copy(seed32[:], seed)
-			kp, err := keypair.FromRawSeed(seed32)
+	for _, j := range aj.Stellar.Roots {
+		r := &Root{ID: j.ID}
+		if a.secret != nil {
+			seed, err := a.openRoot(j)
 			if err != nil {
-				return err
+				// the key was checked, only this root is corrupted
+				j := j
+				a.skipped = append(a.skipped, skippedEntry{subject: "root " + j.ID, root: &j})
+				continue
 			}
+			r.Seed = seed
+		}
+
+		a.Stellar.Roots = append(a.Stellar.Roots, r)
+	}
+
+	a.Stellar.Contacts = aj.Stellar.Contacts
+	return nil
+}
+
+// openRoot returns the seed of the root j, or an error if it could not be
+// decrypted or does not match the ID of the root.
+func (a *Alfred) openRoot(j rootyaml) ([]byte, error) {
+	decoded, err := base64.RawStdEncoding.DecodeString(j.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := a.secret.decrypt(decoded, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if got, err := NewRoot(seed); err != nil || got.ID != j.ID {
+		wipe(seed)
+		return nil, errors.New("root mismatch")
+	}
 
-			if kp.Address() != j.Address {
-				return errors.New("address mismatch, password may be incorrect")
+	return seed, nil
+}
+
+// checkVersion returns an error for files written by a newer version of
+// alfred.
+func checkVersion(version int) error {
+	if version > FormatVersion {
+		return fmt.Errorf("unsupported file version %d, please upgrade alfred", version)
+	}
+
+	return nil
+}
+
+// checkKey returns an error if the key of the db does not decrypt the check
+// of aj or, for files written before it, any of the seeds.
+func (a *Alfred) checkKey(aj *alfredyaml) error {
+	if a.secret == nil || a.vault != nil || a.decoy != nil {
+		return nil
+	}
+
+	if aj.Check != "" {
+		check, err := base64.RawStdEncoding.DecodeString(aj.Check)
+		if err != nil {
+			return fmt.Errorf("db could not be unlocked, %s", a.kdf.passwordHint())
+		}
+
+		if _, err := a.secret.decrypt(check, sealedCheck); err == nil {
+			if aj.Sealed == "" {
+				return errors.New("db was sealed but its content is not, file may have been tampered with")
 			}
+			return nil
+		}
 
-			w.Name = j.Name
-			w.Keypair = kp
+		// written unsealed, or sealed before the check recorded it
+		if _, err := a.secret.decrypt(check, nil); err != nil {
+			return fmt.Errorf("db could not be unlocked, %s", a.kdf.passwordHint())
 		}
+		return nil
+	}
 
-		a.Stellar.Wallets = append(a.Stellar.Wallets, w)
+	var encoded []string
+	for _, j := range aj.Stellar.Wallets {
+		if j.Seed != "" {
+			encoded = append(encoded, j.Seed)
+		}
 	}
-	a.Stellar.Contacts = aj.Stellar.Contacts
+	for _, j := range aj.Stellar.Roots {
+		encoded = append(encoded, j.Seed)
+	}
+
+	for _, e := range encoded {
+		decoded, err := base64.RawStdEncoding.DecodeString(e)
+		if err != nil {
+			continue
+		}
+		if seed, err := a.secret.decrypt(decoded, nil); err == nil {
+			wipe(seed)
+			return nil
+		}
+	}
+
+	if len(encoded) > 0 {
+		return fmt.Errorf("none of the wallets could be decrypted, %s", a.kdf.passwordHint())
+	}
+
 	return nil
 }
 
+// yaml describes w, without its seed.
+func (w *Wallet) yaml() walletyaml {
+	j := walletyaml{
+		Name:    w.Name,
+		Address: w.Keypair.Address(),
+		Network: w.networkYAML(),
+		Tags:    w.Tags,
+		Notes:   w.Notes,
+	}
+	if w.hasSeed() {
+		j.Derivation = w.Derivation
+	} else {
+		j.WatchOnly = w.WatchOnly
+		j.RemoteSigner = w.RemoteSigner
+	}
+
+	return j
+}
+
+// wallet returns the wallet described by j, with only its address.
+func (j walletyaml) wallet() (*Wallet, error) {
+	kp, err := keypair.Parse(j.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wallet{
+		Name:         j.Name,
+		Keypair:      kp,
+		WatchOnly:    j.WatchOnly,
+		RemoteSigner: j.RemoteSigner,
+		Derivation:   j.Derivation,
+		Tags:         j.Tags,
+		Notes:        j.Notes,
+	}
+	if j.Password != nil {
+		w.password = &walletPassword{kdf: *j.Password}
+	}
+	if j.Network != nil {
+		n, err := NewNetwork(j.Network.Name, j.Network.Passphrase, j.Network.Horizon)
+		if err != nil {
+			return nil, fmt.Errorf("wallet %s: %v", j.Name, err)
+		}
+		w.Network = &n
+	}
+
+	return w, nil
+}
+
 type WalletsManager struct {
 	Wallets  []*Wallet          `yaml:"wallets,omitempty"`
 	Contacts map[string]Contact `yaml:"contacts,omitempty"`
+	Roots    []*Root            `yaml:"roots,omitempty"`
 }
 
 type Contact struct {
 	Address string `yaml:"address,omitempty"`
 	Memo    *Memo  `yaml:"memo,omitempty"`
+	Tags    Tags   `yaml:"tags,omitempty"`
+	Notes   string `yaml:"notes,omitempty"`
 }
 
-func OpenSecretString(path string, secretStr string) (*Alfred, error) {
+func OpenSecretString(path string, secretStr string, opts *Options) (*Alfred, error) {
 	var secret []byte
 	if len(secretStr) > 0 {
 		secret = []byte(secretStr)
 	}
 
-	return Open(path, secret)
+	return Open(path, secret, opts)
 }
 
-func Open(path string, secret []byte) (*Alfred, error) {
-	b, err := ioutil.ReadFile(path)
-	if os.IsNotExist(err) {
+// Open opens the db at path, a file path or a url selecting its store (see
+// NewStore), with opts or DefaultOptions if nil. The seeds are decrypted if
+// secret is set.
+func Open(path string, secret []byte, opts *Options) (*Alfred, error) {
+	store, err := NewStore(path, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	} else if err != nil {
+	a := Alfred{password: secret, opts: *opts.orDefault()}
+	exists, err := store.Load(&a)
+	if err != nil {
 		return nil, err
 	}
 
-	var a Alfred
-	a.Unlock(secret)
-	if len(b) > 0 {
-		err = yaml.Unmarshal(b, &a)
-		if err != nil {
+	if !exists {
+		if err := a.Unlock(secret); err != nil {
 			return nil, err
 		}
 	}
 	return &a, nil
 }
 
+// OpenAgent opens path through agent, which encrypts and decrypts with the
+// key of the db instead of the password.
+func OpenAgent(path string, agent *AgentClient, opts *Options) (*Alfred, error) {
+	store, err := NewStore(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	a := Alfred{agent: agent, opts: *opts.orDefault()}
+	exists, err := store.Load(&a)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("%s is empty, a password is needed to create it", path)
+	}
+
+	return &a, nil
+}
+
+// Write encrypts and writes m to the db at path. Dbs using weaker key
+// derivation parameters than DefaultKDF are migrated to it. ErrModified is
+// returned if the db changed since m was opened. The options m was opened
+// with apply.
 func Write(path string, m *Alfred) error {
-	ciphertext, err := yaml.Marshal(m)
+	store, err := NewStore(path, &m.opts)
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(path, ciphertext, 0600)
+	if err := m.upgradeKDF(); err != nil {
+		return err
+	}
+
+	return store.Save(m)
+}
+
+// ChangePassword re-encrypts every seed under password, using a fresh salt.
+// It fails if any of the wallets could not be decrypted with the current one.
+// The new key is combined with the key file of the options of m if one is
+// set, which adds, replaces or removes the key file. In a team vault, only the password of the current
+// member is changed.
+func (m *Alfred) ChangePassword(password []byte) error {
+	if !m.IsUnlocked() {
+		return errors.New("you should unlock alfred to change its password")
+	}
+
+	if len(password) == 0 {
+		return errors.New("password should not be empty")
+	}
+
+	if m.vault != nil {
+		return m.vault.changePassword(password)
+	}
+
+	if err := m.checkSeeds(); err != nil {
+		return fmt.Errorf("%v, remove the wallet to change the password", err)
+	}
+
+	if m.decoy != nil {
+		return m.changeHiddenPassword(password)
+	}
+
+	kdf := m.kdf
+	m.kdf = KDF{}
+	if err := m.Unlock(password); err != nil {
+		m.kdf = kdf
+		return err
+	}
+
+	return nil
+}
+
+// Options returns the options a was opened with. Changing them affects the
+// following writes and password changes.
+func (a *Alfred) Options() *Options {
+	return &a.opts
 }
 
 func (m *Alfred) AddWallet(w *Wallet) error {
-	if m.WalletByAddress(w.Keypair.Address()) != nil {
-		return errors.New("wallet already exists")
+	if other := m.WalletByAddress(w.Keypair.Address()); other != nil {
+		return fmt.Errorf("wallet %s already exists as '%s'", w.Keypair.Address(), other.Name)
+	}
+
+	if other := m.WalletByName(w.Name); other != nil {
+		return fmt.Errorf("a wallet named '%s' already exists (%s)", w.Name, other.Keypair.Address())
 	}
 
 	m.Stellar.Wallets = append(m.Stellar.Wallets, w)
@@ -181,11 +689,11 @@ func (m *Alfred) AddContact(name, addr string, memo *Memo) error {
 		m.Stellar.Contacts = map[string]Contact{}
 	}
 
-	if _, ok := m.Stellar.Contacts[name]; ok {
-		return errors.New("contact already exists")
+	if err := m.checkContactName(name); err != nil {
+		return err
 	}
 
-	if _, err := keypair.Parse(addr); err != nil {
+	if err := checkContactAddress(addr); err != nil {
 		return err
 	}
 
@@ -197,6 +705,105 @@ func (m *Alfred) AddContact(name, addr string, memo *Memo) error {
 	return nil
 }
 
+// EditContact replaces the address and memo of the contact name.
+func (m *Alfred) EditContact(name, addr string, memo *Memo) error {
+	if _, ok := m.Stellar.Contacts[name]; !ok {
+		return fmt.Errorf("contact '%s' not found", name)
+	}
+
+	if err := checkContactAddress(addr); err != nil {
+		return err
+	}
+
+	c := m.Stellar.Contacts[name]
+	c.Address = addr
+	c.Memo = memo
+	m.Stellar.Contacts[name] = c
+
+	return nil
+}
+
+func (m *Alfred) RenameContact(name, newName string) error {
+	contact, ok := m.Stellar.Contacts[name]
+	if !ok {
+		return fmt.Errorf("contact '%s' not found", name)
+	}
+
+	if err := m.checkContactName(newName); err != nil {
+		return err
+	}
+
+	delete(m.Stellar.Contacts, name)
+	m.Stellar.Contacts[newName] = contact
+	return nil
+}
+
+func (m *Alfred) RemoveContact(name string) error {
+	if _, ok := m.Stellar.Contacts[name]; !ok {
+		return fmt.Errorf("contact '%s' not found", name)
+	}
+
+	delete(m.Stellar.Contacts, name)
+	return nil
+}
+
+func (m *Alfred) checkContactName(name string) error {
+	if name == "" {
+		return errors.New("contact name should not be empty")
+	}
+
+	if _, ok := m.Stellar.Contacts[name]; ok {
+		return errors.New("contact already exists")
+	}
+
+	return nil
+}
+
+func checkContactAddress(addr string) error {
+	kp, err := keypair.Parse(addr)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := kp.(*keypair.Full); ok {
+		return errors.New("contact address should be an address, not a seed")
+	}
+
+	return nil
+}
+
+// RenameWallet renames the wallet at address.
+func (m *Alfred) RenameWallet(address, newName string) error {
+	w := m.WalletByAddress(address)
+	if w == nil {
+		return fmt.Errorf("wallet %s not found", address)
+	}
+
+	if newName == "" {
+		return errors.New("wallet name should not be empty")
+	}
+
+	if other := m.WalletByName(newName); other != nil && other != w {
+		return fmt.Errorf("a wallet named '%s' already exists (%s)", newName, other.Keypair.Address())
+	}
+
+	w.Name = newName
+	return nil
+}
+
+// RemoveWallet removes the wallet at address, its seed is lost unless it
+// was backed up elsewhere.
+func (m *Alfred) RemoveWallet(address string) error {
+	for i, w := range m.Stellar.Wallets {
+		if w.Keypair.Address() == address {
+			m.Stellar.Wallets = append(m.Stellar.Wallets[:i], m.Stellar.Wallets[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("wallet %s not found", address)
+}
+
 func (m *Alfred) WalletByAddress(address string) *Wallet {
 	for _, w := range m.Stellar.Wallets {
 		if w.Keypair.Address() == address {
@@ -207,6 +814,18 @@ func (m *Alfred) WalletByAddress(address string) *Wallet {
 	return nil
 }
 
+// KnownNetwork returns the network called name which a wallet of m is bound
+// to.
+func (m *Alfred) KnownNetwork(name string) (Network, bool) {
+	for _, w := range m.Stellar.Wallets {
+		if w.Network != nil && w.Network.Name == name {
+			return *w.Network, true
+		}
+	}
+
+	return Network{}, false
+}
+
 func (m *Alfred) WalletByName(name string) *Wallet {
 	for _, w := range m.Stellar.Wallets {
 		if w.Name == name {
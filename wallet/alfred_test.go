@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/stellar/go/keypair"
@@ -21,7 +22,7 @@ func TestManager(t *testing.T) {
 
 	secret := "hello"
 
-	m, err := Open(path, nil)
+	m, err := Open(path, nil, nil)
 	require.NoError(t, err)
 
 	kp, err := keypair.Random()
@@ -40,7 +41,7 @@ func TestManager(t *testing.T) {
 
 	//
 
-	m, err = Open(path, nil)
+	m, err = Open(path, nil, nil)
 	require.NoError(t, err)
 
 	require.Equal(t, 1, len(m.Stellar.Wallets))
@@ -50,14 +51,244 @@ func TestManager(t *testing.T) {
 
 	//
 
-	m, err = Open(path, []byte(secret))
+	m, err = Open(path, []byte(secret), nil)
 	require.NoError(t, err)
-	gotFullKP, ok := m.Stellar.Wallets[0].Keypair.(*keypair.Full)
+	gotFullKP, ok := m.Stellar.Wallets[0].Full()
 	require.True(t, ok)
 	require.Equal(t, kp.Address(), gotFullKP.Address())
 	require.Equal(t, kp.Seed(), gotFullKP.Seed())
 }
 
+func TestLegacyMigration(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	secret := []byte("hello")
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+
+	// file written before the kdf was stored in the header
+	key, err := legacyKDF().DeriveKey(secret)
+	require.NoError(t, err)
+	encrypted, err := encrypt(key, getSeed(kp.Seed()))
+	require.NoError(t, err)
+
+	var legacy alfredyaml
+	legacy.Stellar.Wallets = []walletyaml{{
+		Name:    "legacy",
+		Address: kp.Address(),
+		Seed:    base64.RawStdEncoding.EncodeToString(encrypted),
+	}}
+	b, err := yaml.Marshal(legacy)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, KDFSha256, m.KDF().Name)
+	require.Equal(t, kp.Seed(), seedOf(t, m.Stellar.Wallets[0]))
+
+	require.NoError(t, m.UpgradeBlocked())
+
+	// a corrupted seed keeps the weak kdf, and is reported
+	other, err := keypair.Random()
+	require.NoError(t, err)
+	corrupted := append([]byte{}, encrypted...)
+	corrupted[len(corrupted)-1] ^= 1
+	blocked := legacy
+	blocked.Stellar.Wallets = append(blocked.Stellar.Wallets, walletyaml{
+		Name:    "broken",
+		Address: other.Address(),
+		Seed:    base64.RawStdEncoding.EncodeToString(corrupted),
+	})
+	blockedPath := path + ".blocked"
+	defer os.Remove(blockedPath)
+	bb, err := yaml.Marshal(blocked)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(blockedPath, bb, 0600))
+	mb, err := Open(blockedPath, secret, nil)
+	require.NoError(t, err)
+	err = mb.UpgradeBlocked()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "wallet broken")
+	require.NoError(t, Write(blockedPath, mb))
+	mb, err = Open(blockedPath, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, KDFSha256, mb.KDF().Name)
+
+	require.NoError(t, Write(path, m))
+
+	b, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var got alfredyaml
+	require.NoError(t, yaml.Unmarshal(b, &got))
+	require.Equal(t, FormatVersion, got.Version)
+	require.Equal(t, KDFScrypt, got.KDF.Name)
+	require.NotEmpty(t, got.KDF.Salt)
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, kp.Seed(), seedOf(t, m.Stellar.Wallets[0]))
+
+	// raising the cost keeps older files readable and upgrades them on write
+	defaultKDF := DefaultKDF
+	defer func() { DefaultKDF = defaultKDF }()
+	DefaultKDF.N *= 2
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.True(t, m.KDF().Weaker(DefaultKDF))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, DefaultKDF.N, m.KDF().N)
+	require.Equal(t, kp.Seed(), seedOf(t, m.Stellar.Wallets[0]))
+
+	_, err = Open(path, []byte("wrong password"), nil)
+	require.Error(t, err)
+}
+
+func TestChangePassword(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	oldSecret, newSecret := []byte("old password"), []byte("new password")
+
+	m, err := Open(path, oldSecret, nil)
+	require.NoError(t, err)
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("", kp)))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, nil, nil)
+	require.NoError(t, err)
+	require.Error(t, m.ChangePassword(newSecret)) // locked
+
+	m, err = Open(path, oldSecret, nil)
+	require.NoError(t, err)
+	salt := m.KDF().Salt
+	require.NoError(t, m.ChangePassword(newSecret))
+	require.NotEqual(t, salt, m.KDF().Salt)
+	require.NoError(t, Write(path, m))
+
+	_, err = Open(path, oldSecret, nil)
+	require.Error(t, err)
+
+	m, err = Open(path, newSecret, nil)
+	require.NoError(t, err)
+	require.Equal(t, kp.Seed(), seedOf(t, m.Stellar.Wallets[0]))
+}
+
+func TestWatchOnly(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("hot", kp)))
+
+	cold, err := keypair.Random()
+	require.NoError(t, err)
+	w, err := NewWatchOnly("cold", cold.Seed()) // seed is dropped
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(w))
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), cold.Seed())
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 2)
+
+	_, ok := m.WalletByName("hot").Full()
+	require.True(t, ok)
+
+	w = m.WalletByName("cold")
+	require.True(t, w.WatchOnly)
+	_, ok = w.Full()
+	require.False(t, ok)
+	require.Equal(t, cold.Address(), w.Keypair.Address())
+
+	require.NoError(t, m.ChangePassword([]byte("new secret")))
+	require.NoError(t, Write(path, m))
+}
+
+func TestNetworkBinding(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+
+	custom, err := NewNetwork("private", "Private Network ; 2018", "http://localhost:8000")
+	require.NoError(t, err)
+
+	_, err = NewNetwork("private", "", "")
+	require.Error(t, err)
+	_, err = NewNetwork(PublicNetworkName, "Private Network ; 2018", "")
+	require.Error(t, err)
+
+	for name, n := range map[string]*Network{
+		"legacy":  nil,
+		"public":  &PublicNetwork,
+		"testnet": &TestNetwork,
+		"private": &custom,
+	} {
+		kp, err := keypair.Random()
+		require.NoError(t, err)
+
+		w := New(name, kp)
+		w.Network = n
+		require.NoError(t, m.AddWallet(w))
+	}
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), PublicNetwork.Passphrase)
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+
+	require.Nil(t, m.WalletByName("legacy").Network)
+	require.NoError(t, m.WalletByName("legacy").CheckNetwork(TestNetwork))
+
+	require.Equal(t, PublicNetwork, *m.WalletByName("public").Network)
+	require.Equal(t, TestNetwork, *m.WalletByName("testnet").Network)
+	require.Equal(t, custom, *m.WalletByName("private").Network)
+
+	require.NoError(t, m.WalletByName("public").CheckNetwork(PublicNetwork))
+	require.Error(t, m.WalletByName("public").CheckNetwork(TestNetwork))
+	require.Error(t, m.WalletByName("private").CheckNetwork(PublicNetwork))
+
+	// custom networks are known by name once a wallet is bound to them
+	known, ok := m.KnownNetwork("private")
+	require.True(t, ok)
+	require.Equal(t, custom, known)
+	_, ok = m.KnownNetwork("other")
+	require.False(t, ok)
+}
+
 func TestOpenssl(t *testing.T) {
 	secret := []byte("secret")
 	h := sha256.New()
@@ -90,6 +321,38 @@ func TestMemo(t *testing.T) {
 	require.NoError(t, err)
 
 	require.Equal(t, want, got)
+
+	var id Memo
+	require.NoError(t, yaml.Unmarshal([]byte("{type: id, value: 18446744073709551615}"), &id))
+	require.Equal(t, Memo{Type: MEMO_ID, Value: MemoValue{IntValue: 1<<64 - 1}}, id)
+
+	for _, in := range []string{
+		"{type: text, value: 12}",
+		"{type: id, value: abc}",
+		"{type: id, value: -1}",
+		"{type: hash, value: 12}",
+		"{type: return}",
+		"{type: other, value: abc}",
+	} {
+		require.Error(t, yaml.Unmarshal([]byte(in), &Memo{}), in)
+	}
+}
+
+func TestMemoFromString(t *testing.T) {
+	memo, err := MemoFromString(MEMO_ID, "18446744073709551615")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1<<64-1), memo.Value.IntValue)
+
+	memo, err = MemoFromString(MEMO_TEXT, "twenty-eight bytes long text")
+	require.NoError(t, err)
+	require.Equal(t, "twenty-eight bytes long text", memo.Value.StringValue)
+
+	_, err = MemoFromString(MEMO_ID, "-1")
+	require.Error(t, err)
+	_, err = MemoFromString(MEMO_TEXT, "twenty-nine bytes long text!!")
+	require.Error(t, err)
+	_, err = MemoFromString(MEMO_HASH, "not base64")
+	require.Error(t, err)
 }
 
 func BenchmarkRandom(b *testing.B) {
@@ -101,3 +364,147 @@ func BenchmarkRandom(b *testing.B) {
 		}
 	})
 }
+
+func TestManage(t *testing.T) {
+	m := &Alfred{}
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("main", kp)))
+
+	other, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("other", other)))
+
+	require.Error(t, m.RenameWallet(kp.Address(), "other"))
+	require.Error(t, m.RenameWallet(kp.Address(), ""))
+	require.NoError(t, m.RenameWallet(kp.Address(), "savings"))
+	require.Equal(t, kp.Address(), m.WalletByName("savings").Keypair.Address())
+
+	require.NoError(t, m.RemoveWallet(kp.Address()))
+	require.Nil(t, m.WalletByAddress(kp.Address()))
+	require.Error(t, m.RemoveWallet(kp.Address()))
+	require.Len(t, m.Stellar.Wallets, 1)
+
+	require.Error(t, m.AddContact("", kp.Address(), nil))
+	require.Error(t, m.AddContact("bob", kp.Seed(), nil))
+	require.NoError(t, m.AddContact("bob", kp.Address(), nil))
+	require.Error(t, m.AddContact("bob", kp.Address(), nil))
+	require.NoError(t, m.AddContact("alice", other.Address(), nil))
+
+	memo, err := MemoFromString(MEMO_ID, "42")
+	require.NoError(t, err)
+	require.Error(t, m.EditContact("bob", "GXXX", memo))
+	require.Error(t, m.EditContact("carol", other.Address(), memo))
+	require.NoError(t, m.EditContact("bob", other.Address(), memo))
+	require.Equal(t, Contact{Address: other.Address(), Memo: memo}, m.Stellar.Contacts["bob"])
+
+	require.Error(t, m.RenameContact("bob", "alice"))
+	require.NoError(t, m.RenameContact("bob", "robert"))
+	_, ok := m.Stellar.Contacts["bob"]
+	require.False(t, ok)
+
+	require.NoError(t, m.RemoveContact("robert"))
+	require.Error(t, m.RemoveContact("robert"))
+	require.Len(t, m.Stellar.Contacts, 1)
+}
+
+func TestTags(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, nil, nil)
+	require.NoError(t, err)
+	m.Unlock([]byte("hello"))
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	w := New("main", kp)
+	w.Tags.Add("treasury", "Treasury", " ", "hot")
+	w.Notes = "daily operations"
+	require.Equal(t, Tags{"treasury", "hot"}, w.Tags)
+	require.True(t, w.Tags.Match([]string{"HOT", "treasury"}))
+	require.False(t, w.Tags.Match([]string{"hot", "cold"}))
+	require.NoError(t, m.AddWallet(w))
+
+	bob, err := keypair.Random()
+	require.NoError(t, err)
+	alice, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddContact("bob", bob.Address(), nil))
+	require.NoError(t, m.AddContact("alice", alice.Address(), nil))
+
+	require.Error(t, m.TagContact("carol", "payroll"))
+	require.NoError(t, m.TagContact("bob", "payroll"))
+	require.NoError(t, m.TagContact("alice", "Payroll", "board"))
+	require.NoError(t, m.SetContactNotes("alice", "paid monthly"))
+	require.Equal(t, []string{"alice", "bob"}, m.ContactGroup("payroll"))
+	require.Empty(t, m.ContactGroup("unknown"))
+
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("hello"), nil)
+	require.NoError(t, err)
+	require.Equal(t, Tags{"treasury", "hot"}, m.Stellar.Wallets[0].Tags)
+	require.Equal(t, "daily operations", m.Stellar.Wallets[0].Notes)
+	require.Equal(t, "paid monthly", m.Stellar.Contacts["alice"].Notes)
+	require.Equal(t, []string{"alice", "bob"}, m.ContactGroup("payroll"))
+
+	require.NoError(t, m.UntagContact("alice", "PAYROLL"))
+	require.Equal(t, []string{"bob"}, m.ContactGroup("payroll"))
+	require.Equal(t, Tags{"board"}, m.Stellar.Contacts["alice"].Tags)
+}
+
+func TestKeyFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	opts := &Options{KeyFile: []byte("key file contents")}
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, opts)
+	require.NoError(t, err)
+	require.True(t, m.KDF().KeyFile)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("main", kp)))
+	require.NoError(t, Write(path, m))
+
+	_, err = Open(path, secret, nil)
+	require.Equal(t, ErrKeyFileRequired, err)
+
+	// addresses are still readable
+	m, err = Open(path, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 1)
+
+	_, err = Open(path, secret, &Options{KeyFile: []byte("another key file")})
+	require.EqualError(t, err, "db could not be unlocked, password or key file may be incorrect")
+
+	m, err = Open(path, secret, opts)
+	require.NoError(t, err)
+	_, ok := m.WalletByName("main").Full()
+	require.True(t, ok)
+
+	// removing the key file
+	m.Options().KeyFile = nil
+	require.NoError(t, m.ChangePassword(secret))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.False(t, m.KDF().KeyFile)
+	_, ok = m.WalletByName("main").Full()
+	require.True(t, ok)
+}
+
+func seedOf(t *testing.T, w *Wallet) string {
+	kp, ok := w.Full()
+	require.True(t, ok)
+	return kp.Seed()
+}
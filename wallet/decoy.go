@@ -0,0 +1,432 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Every file db carries a padding section of paddingSize random-looking
+// bytes. Once a decoy set is set up, the padding holds the real wallets
+// instead, encrypted under a key derived from the real password, while the
+// rest of the file holds the decoy set, unlocked by the decoy password. Both
+// look the same, so the file gives no sign that a second set exists:
+//
+//	salt (32) | outer key for the real password (60) | outer key for the file key (60) | outer layer
+//
+// The outer layer is encrypted with a random outer key, which both the key
+// of the file and the real password unlock, and holds the key of the file
+// followed by the inner layer: random bytes, or the real wallets:
+//
+//	nonce (12) | encrypted length (4) | wallets | zeros | tag (16)
+//
+// Every write re-encrypts the outer layer, whichever password opened the
+// file, so the padding changes on every write. Writes with the real password
+// also renew the encryption of the visible part with the key of the file,
+// like any write does.
+const paddingSize = 16 << 10
+
+const (
+	// wrapSize is the size of a key encrypted with another one.
+	wrapSize    = 12 + keySize + 16
+	hiddenWrap  = saltSize
+	visibleWrap = hiddenWrap + wrapSize
+	outerOffset = visibleWrap + wrapSize
+	outerSize   = paddingSize - outerOffset - 12 - 16
+	innerSize   = outerSize - keySize
+)
+
+// hiddenKDF derives the key of the padding from the salt at its beginning.
+// Its parameters are not stored anywhere and should never change.
+var hiddenKDF = KDF{
+	Name: KDFScrypt,
+	N:    1 << 15,
+	R:    8,
+	P:    1,
+}
+
+// maxHidden is the size left in the padding for the real wallets.
+const maxHidden = innerSize - 12 - 4 - 16
+
+// paddingAD is the additional data of the outer key encrypted with the key
+// of the file.
+var paddingAD = []byte("padding")
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// newPadding sets up a padding holding no wallets.
+func (a *Alfred) newPadding() (err error) {
+	if a.padding, err = randomBytes(outerOffset); err != nil {
+		return err
+	}
+	if a.paddingKey, err = randomKey(); err != nil {
+		return err
+	}
+	a.outer, err = randomBytes(outerSize)
+	return err
+}
+
+func hiddenKey(padding, password []byte, requiresKeyFile bool, keyFile []byte) ([]byte, error) {
+	kdf := hiddenKDF
+	kdf.Salt = base64.RawStdEncoding.EncodeToString(padding[:saltSize])
+	kdf.KeyFile = requiresKeyFile
+
+	return kdf.deriveKeyFile(password, keyFile)
+}
+
+// sealPadding returns the padding with the outer layer encrypted again, and
+// the outer key encrypted with visible, the key of the visible part.
+func (a *Alfred) sealPadding(visible dbKey) ([]byte, error) {
+	wrapped, err := visible.encrypt(a.paddingKey, paddingAD)
+	if err != nil {
+		return nil, err
+	}
+
+	outer, err := encrypt(a.paddingKey, a.outer)
+	if err != nil {
+		return nil, err
+	}
+
+	padding := append([]byte{}, a.padding[:visibleWrap]...)
+	padding = append(padding, wrapped...)
+	return append(padding, outer...), nil
+}
+
+// openOuter decrypts the outer layer of padding with key.
+func (a *Alfred) openOuter(padding, key []byte) bool {
+	outer, err := decrypt(key, padding[outerOffset:])
+	if err != nil || len(outer) != outerSize {
+		return false
+	}
+
+	a.padding = padding[:outerOffset]
+	a.paddingKey = key
+	a.outer = outer
+	return true
+}
+
+// openVisiblePadding opens the outer layer of the padding with the key of
+// the file. A padding which could not be opened, written before the outer
+// layer or corrupted, is replaced on the next write.
+func (a *Alfred) openVisiblePadding(aj *alfredyaml) error {
+	if a.secret == nil || a.decoy != nil || a.paddingKey != nil {
+		return nil
+	}
+
+	if padding, err := base64.RawStdEncoding.DecodeString(aj.Padding); err == nil && len(padding) == paddingSize {
+		if key, err := a.secret.decrypt(padding[visibleWrap:outerOffset], paddingAD); err == nil && a.openOuter(padding, key) {
+			return nil
+		}
+	}
+
+	return a.newPadding()
+}
+
+// sealHidden encrypts the real wallets s with the key derived from the real
+// password into the inner layer.
+func (a *Alfred) sealHidden(s stellaryaml) error {
+	body, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if len(body) > maxHidden {
+		return fmt.Errorf("wallets take %d bytes, more than the %d bytes which can be kept behind a decoy set", len(body), maxHidden)
+	}
+
+	plaintext := make([]byte, 4+maxHidden)
+	binary.BigEndian.PutUint32(plaintext, uint32(len(body)))
+	copy(plaintext[4:], body)
+	defer wipe(plaintext)
+
+	inner, err := a.secret.encrypt(plaintext, nil)
+	if err != nil {
+		return err
+	}
+
+	copy(a.outer[keySize:], inner)
+	return nil
+}
+
+// openHidden returns the real wallets kept in the inner layer, or false if
+// it could not be opened with secret.
+func openHidden(secret, outer []byte) (*stellaryaml, bool) {
+	plaintext, err := decrypt(secret, outer[keySize:])
+	if err != nil || len(plaintext) < 4 {
+		return nil, false
+	}
+	defer wipe(plaintext)
+
+	size := binary.BigEndian.Uint32(plaintext)
+	if size > uint32(len(plaintext)-4) {
+		return nil, false
+	}
+
+	var s stellaryaml
+	if err := yaml.Unmarshal(plaintext[4:4+size], &s); err != nil {
+		return nil, false
+	}
+
+	return &s, true
+}
+
+// openHidden tries the password on the padding of aj. When it opens, the
+// visible part of the file is kept aside to be written back, and aj is
+// replaced by the real wallets.
+func (a *Alfred) openHidden(aj *alfredyaml) error {
+	if aj.Padding == "" {
+		// written before the padding, it is added on the next write
+		return nil
+	}
+
+	padding, err := base64.RawStdEncoding.DecodeString(aj.Padding)
+	if err != nil || len(padding) != paddingSize {
+		return errors.New("invalid padding")
+	}
+
+	if a.password == nil || aj.Vault != nil {
+		return nil
+	}
+
+	kdf := legacyKDF()
+	if aj.KDF != nil {
+		kdf = *aj.KDF
+	}
+
+	secret, err := hiddenKey(padding, a.password, kdf.KeyFile, a.opts.KeyFile)
+	if err == ErrKeyFileRequired {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	key, err := decrypt(secret, padding[hiddenWrap:visibleWrap])
+	if err != nil || !a.openOuter(padding, key) {
+		wipe(secret)
+		a.padding, a.paddingKey, a.outer = nil, nil, nil
+		return nil
+	}
+
+	s, ok := openHidden(secret, a.outer)
+	if !ok {
+		wipe(secret)
+		a.padding, a.paddingKey, a.outer = nil, nil, nil
+		return nil
+	}
+
+	visible := *aj
+	a.decoy = &visible
+	a.kdf = kdf
+	a.secret = secretKey(secret)
+	a.sealed = aj.Sealed != ""
+	*aj = alfredyaml{Version: aj.Version, Stellar: *s}
+	return nil
+}
+
+// HasDecoy reports whether the db was opened with the real password of a
+// db protected by a decoy set.
+func (a *Alfred) HasDecoy() bool { return a.decoy != nil }
+
+// InitDecoy moves the wallets, contacts and roots of the db out of sight and
+// sets up a decoy set, unlocked by password, holding copies of wallets. The
+// real wallets stay available with the current password.
+func (m *Alfred) InitDecoy(password []byte, wallets []*Wallet) error {
+	switch {
+	case !m.IsUnlocked() || m.password == nil:
+		return errors.New("you should unlock alfred with its password to set up a decoy")
+	case m.vault != nil:
+		return errors.New("team vaults cannot have a decoy set")
+	case m.decoy != nil:
+		return errors.New("db already has a decoy set")
+	case len(password) == 0:
+		return errors.New("decoy password should not be empty")
+	case string(password) == string(m.password):
+		return errors.New("decoy password should differ from the password")
+	}
+
+	if err := m.checkSeeds(); err != nil {
+		return err
+	}
+
+	kdf, err := NewKDF()
+	if err != nil {
+		return err
+	}
+	kdf.KeyFile = m.kdf.KeyFile
+
+	decoy := Alfred{kdf: kdf, sealed: m.sealed}
+	key, err := kdf.deriveKeyFile(password, m.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+	decoy.secret = secretKey(key)
+	defer wipe(key)
+
+	for _, w := range wallets {
+		c := *w
+		if err := decoy.AddWallet(&c); err != nil {
+			return err
+		}
+	}
+
+	if err := decoy.newPadding(); err != nil {
+		return err
+	}
+
+	visible, err := decoy.yaml()
+	if err != nil {
+		return err
+	}
+
+	secret, err := hiddenKey(decoy.padding, m.password, m.kdf.KeyFile, m.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := encrypt(secret, decoy.paddingKey)
+	if err != nil {
+		return err
+	}
+	copy(decoy.padding[hiddenWrap:visibleWrap], wrapped)
+
+	m.secret = secretKey(secret)
+	m.padding, m.paddingKey, m.outer = decoy.padding, decoy.paddingKey, decoy.outer
+	m.kdf = kdf
+	m.decoy = &visible
+	return nil
+}
+
+// RemoveDecoy drops the decoy set and makes the real wallets visible again.
+func (m *Alfred) RemoveDecoy() error {
+	if m.decoy == nil {
+		return errors.New("db was not opened behind a decoy set")
+	}
+
+	kdf, err := NewKDF()
+	if err != nil {
+		return err
+	}
+	kdf.KeyFile = m.kdf.KeyFile
+
+	secret, err := kdf.deriveKeyFile(m.password, m.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	if err := m.newPadding(); err != nil {
+		return err
+	}
+
+	m.secret = secretKey(secret)
+	m.kdf = kdf
+	m.decoy = nil
+	return nil
+}
+
+// changeHiddenPassword changes the real password of a db with a decoy set,
+// leaving the decoy password and the visible part of the file untouched.
+func (m *Alfred) changeHiddenPassword(password []byte) error {
+	secret, err := hiddenKey(m.padding, password, m.kdf.KeyFile, m.opts.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := encrypt(secret, m.paddingKey)
+	if err != nil {
+		return err
+	}
+	copy(m.padding[hiddenWrap:visibleWrap], wrapped)
+
+	m.password = password
+	m.secret = secretKey(secret)
+	return nil
+}
+
+// hiddenYAML returns the visible decoy set, with the real wallets of s
+// encrypted in its padding.
+func (a *Alfred) hiddenYAML(s stellaryaml) (alfredyaml, error) {
+	if err := a.sealHidden(s); err != nil {
+		return alfredyaml{}, err
+	}
+
+	key := secretKey(a.outer[:keySize])
+	visible := *a.decoy
+	if err := visible.renew(key); err != nil {
+		return alfredyaml{}, err
+	}
+
+	padding, err := a.sealPadding(key)
+	if err != nil {
+		return alfredyaml{}, err
+	}
+
+	visible.Padding = base64.RawStdEncoding.EncodeToString(padding)
+	return visible, nil
+}
+
+// renew encrypts again with key what every write encrypts again: the check,
+// the sealed content and the roots.
+func (j *alfredyaml) renew(key dbKey) error {
+	ad := []byte(nil)
+	if j.Sealed != "" {
+		ad = sealedCheck
+	}
+
+	reencrypt := func(encoded string, ad []byte) (string, error) {
+		decoded, err := base64.RawStdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", err
+		}
+
+		plaintext, err := key.decrypt(decoded, ad)
+		if err != nil {
+			return "", errors.New("decoy set could not be decrypted")
+		}
+		defer wipe(plaintext)
+
+		encrypted, err := key.encrypt(plaintext, ad)
+		if err != nil {
+			return "", err
+		}
+
+		return base64.RawStdEncoding.EncodeToString(encrypted), nil
+	}
+
+	var err error
+	if j.Check, err = reencrypt(j.Check, ad); err != nil {
+		return err
+	}
+
+	if j.Sealed != "" {
+		sealedAD, err := j.additionalData()
+		if err != nil {
+			return err
+		}
+
+		j.Sealed, err = reencrypt(j.Sealed, sealedAD)
+		return err
+	}
+
+	roots := make([]rootyaml, len(j.Stellar.Roots))
+	for i, r := range j.Stellar.Roots {
+		roots[i] = r
+		if roots[i].Seed, err = reencrypt(r.Seed, nil); err != nil {
+			return err
+		}
+	}
+	j.Stellar.Roots = roots
+
+	return nil
+}
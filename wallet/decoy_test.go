@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestDecoy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	open := func(secret string) *Alfred {
+		m, err := Open(path, []byte(secret), nil)
+		require.NoError(t, err)
+		return m
+	}
+	read := func() alfredyaml {
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+
+		var j alfredyaml
+		require.NoError(t, yaml.Unmarshal(b, &j))
+		return j
+	}
+	// changed writes m and returns what changed in the file
+	changed := func(m *Alfred) []string {
+		before := read()
+		require.NoError(t, Write(path, m))
+		after := read()
+
+		var fields []string
+		if before.Check != after.Check {
+			fields = append(fields, "check")
+		}
+		if before.Padding != after.Padding {
+			fields = append(fields, "padding")
+		}
+		before.Check, before.Padding = after.Check, after.Padding
+		if !reflect.DeepEqual(before, after) {
+			fields = append(fields, "content")
+		}
+		return fields
+	}
+
+	m := open("secret")
+	treasury, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("treasury", treasury)))
+	pocket, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("pocket", pocket)))
+	require.NoError(t, m.AddContact("landlord", treasury.Address(), nil))
+	require.NoError(t, Write(path, m))
+
+	// every file has a padding of the same size, renewed on every write
+	plain := read().Padding
+	require.Equal(t, []string{"check", "padding"}, changed(open("secret")))
+
+	m = open("secret")
+	require.Error(t, m.InitDecoy([]byte("secret"), nil))
+	require.NoError(t, m.InitDecoy([]byte("duress"), []*Wallet{m.WalletByName("pocket")}))
+	require.True(t, m.HasDecoy())
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), treasury.Address())
+	require.NotContains(t, string(b), "landlord")
+	require.Len(t, read().Padding, len(plain))
+
+	// written with the real password, the file changes like any other
+	backups, err := ListBackups(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"check", "padding"}, changed(open("secret")))
+	after, err := ListBackups(path)
+	require.NoError(t, err)
+	require.Equal(t, backups, after, "no backup is kept behind a decoy set")
+
+	// the decoy password only shows the decoy set, and keeps the rest
+	m = open("duress")
+	require.False(t, m.HasDecoy())
+	require.Len(t, m.Stellar.Wallets, 1)
+	require.Empty(t, m.Stellar.Contacts)
+	_, ok := m.WalletByName("pocket").Full()
+	require.True(t, ok)
+	require.Equal(t, []string{"check", "padding"}, changed(open("duress")))
+	m = open("duress")
+	other, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("savings", other)))
+	require.NoError(t, m.ChangePassword([]byte("new duress")))
+	require.NoError(t, Write(path, m))
+
+	m = open("secret")
+	require.True(t, m.HasDecoy())
+	require.Len(t, m.Stellar.Wallets, 2)
+	kp, ok := m.WalletByName("treasury").Full()
+	require.True(t, ok)
+	require.Equal(t, treasury.Seed(), kp.Seed())
+	require.Equal(t, treasury.Address(), m.Stellar.Contacts["landlord"].Address)
+	require.NoError(t, m.RemoveWallet(pocket.Address()))
+	require.NoError(t, m.ChangePassword([]byte("new secret")))
+	require.NoError(t, Write(path, m))
+
+	_, err = Open(path, []byte("secret"), nil)
+	require.Error(t, err)
+
+	m = open("new duress")
+	require.Len(t, m.Stellar.Wallets, 2)
+	require.NotNil(t, m.WalletByName("savings"))
+
+	// without a password, only the decoy set is listed
+	m, err = Open(path, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 2)
+	require.Nil(t, m.WalletByAddress(treasury.Address()))
+
+	m = open("new secret")
+	require.Len(t, m.Stellar.Wallets, 1)
+	require.NoError(t, m.RemoveDecoy())
+	require.NoError(t, Write(path, m))
+
+	b, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), treasury.Address())
+	m = open("new secret")
+	require.False(t, m.HasDecoy())
+	_, err = Open(path, []byte("new duress"), nil)
+	require.Error(t, err)
+}
+
+func TestDecoySealed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	m, err := Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("pocket", kp)))
+	m.Seal(true)
+	require.NoError(t, m.InitDecoy([]byte("duress"), []*Wallet{m.WalletByName("pocket")}))
+	require.NoError(t, Write(path, m))
+
+	sealed := func() string {
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+
+		var j alfredyaml
+		require.NoError(t, yaml.Unmarshal(b, &j))
+		require.NotEmpty(t, j.Sealed)
+		return j.Sealed
+	}
+
+	// the sealed decoy set is encrypted again, as on any write
+	before := sealed()
+	m, err = Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+	require.True(t, m.HasDecoy())
+	require.NoError(t, Write(path, m))
+	require.NotEqual(t, before, sealed())
+
+	m, err = Open(path, []byte("duress"), nil)
+	require.NoError(t, err)
+	require.False(t, m.HasDecoy())
+	require.True(t, m.IsSealed())
+	require.NotNil(t, m.WalletByName("pocket"))
+}
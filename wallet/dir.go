@@ -0,0 +1,711 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/keypair"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DirStore stores the db in a directory, one file per wallet, contact and
+// root, so that a change only touches the file of its entry and merges of a
+// directory shared through git stay local to it:
+//
+//	alfred.yaml             version and key derivation parameters
+//	wallets/<address>.yaml
+//	contacts/<name>.yaml
+//	roots/<id>.yaml
+//
+// Each entry is encrypted and authenticated as a whole, bound to its file
+// name and to the header so that entries cannot be swapped. A corrupted
+// file only affects its own entry. Without the password, only the addresses
+// of the wallets are available, from the file names.
+//
+// Changing the key rewrites every entry, which cannot be done atomically:
+// the new entries are first written next to the current ones as pending
+// .<name>.next files, together with the new header as alfred.yaml.next.
+// Moving the new header in place commits the change, the pending entries
+// being moved in place afterwards. Until then, pending entries are ignored
+// if the change was not committed and replace the current ones otherwise,
+// so that a crash leaves the db under either the old or the new key.
+type DirStore struct {
+	Path string
+}
+
+const (
+	dirHeader   = "alfred.yaml"
+	walletsDir  = "wallets"
+	contactsDir = "contacts"
+	rootsDir    = "roots"
+	entryExt    = ".yaml"
+	pendingExt  = ".next"
+)
+
+// keyChangeHook, if set, is called before and after a key change is
+// committed, tests use it to interrupt the change.
+var keyChangeHook func(committed bool) error
+
+type dirheader struct {
+	Version int `yaml:"version"`
+	KDF     KDF `yaml:"kdf"`
+}
+
+type entryyaml struct {
+	Sealed string `yaml:"sealed"`
+}
+
+func (s *DirStore) Load(a *Alfred) (bool, error) {
+	unlock, err := lockFile(filepath.Join(s.Path, dirHeader), false)
+	if os.IsNotExist(err) {
+		a.checksum = dirChecksum(nil, nil)
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	header, entries, err := readDir(s.Path)
+	if err != nil {
+		return false, err
+	}
+
+	a.checksum = dirChecksum(header, entries)
+	if header == nil {
+		if len(entries) > 0 {
+			return false, fmt.Errorf("%s is missing from %s", dirHeader, s.Path)
+		}
+		return false, nil
+	}
+
+	var h dirheader
+	if err := yaml.Unmarshal(header, &h); err != nil {
+		return false, fmt.Errorf("%s: %v", dirHeader, err)
+	}
+
+	switch {
+	case h.Version > FormatVersion:
+		return false, fmt.Errorf("unsupported version %d, please upgrade alfred", h.Version)
+	case h.KDF.Name == "":
+		return false, fmt.Errorf("%s: missing key derivation parameters", dirHeader)
+	}
+
+	a.kdf = h.KDF
+	a.sealed = true
+	if err := a.unlock(); err != nil {
+		return false, err
+	}
+
+	opened, corrupted := 0, 0
+	for _, name := range sortedEntries(entries) {
+		ok, err := a.loadEntry(header, name, entries[name])
+		switch {
+		case err != nil:
+			return false, fmt.Errorf("%s: %v", name, err)
+		case ok:
+			opened++
+		default:
+			corrupted++
+		}
+	}
+
+	if corrupted > 0 && opened == 0 {
+		return false, fmt.Errorf("none of the entries could be authenticated, %s", a.kdf.passwordHint())
+	}
+
+	sort.SliceStable(a.Stellar.Wallets, func(i, j int) bool {
+		return a.Stellar.Wallets[i].Name < a.Stellar.Wallets[j].Name
+	})
+
+	return true, nil
+}
+
+// errCorruptedEntry disables the wallets whose entry could not be
+// authenticated, their file is left as it is. Contacts and roots are
+// skipped, see Alfred.Skipped.
+var errCorruptedEntry = errors.New("entry could not be authenticated, it may be corrupted or tampered with")
+
+// loadEntry reports whether the entry could be authenticated. Wallets whose
+// entry could not are only disabled, their address being in the file name,
+// other entries are skipped.
+func (a *Alfred) loadEntry(header []byte, name string, data []byte) (bool, error) {
+	dir, id := path.Dir(name), unescapeEntry(path.Base(name))
+
+	if a.secret == nil {
+		if dir == walletsDir {
+			kp, err := keypair.Parse(id)
+			if err != nil {
+				return false, err
+			}
+			a.Stellar.Wallets = append(a.Stellar.Wallets, &Wallet{Name: id, Keypair: kp})
+		}
+		return true, nil
+	}
+
+	body, err := openEntry(a.secret, header, name, data)
+	if err != nil && dir == walletsDir {
+		kp, perr := keypair.Parse(id)
+		if perr != nil {
+			return false, perr
+		}
+
+		a.Stellar.Wallets = append(a.Stellar.Wallets, &Wallet{
+			Name:    id,
+			Keypair: kp,
+			seed:    &lockedSeed{err: errCorruptedEntry},
+		})
+		return false, nil
+	} else if err != nil {
+		subject := "contact " + id
+		if dir == rootsDir {
+			subject = "root " + id
+		}
+		a.skipped = append(a.skipped, skippedEntry{subject: subject, name: name})
+		return false, nil
+	}
+
+	switch dir {
+	case walletsDir:
+		var j walletyaml
+		if err := yaml.Unmarshal(body, &j); err != nil {
+			return false, err
+		}
+
+		if j.Address != id {
+			return false, errors.New("address does not match the file name")
+		}
+
+		w, err := j.wallet()
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case w.hasSeed() && w.password != nil:
+			// encrypted with the password of the wallet, see lockedSeed
+			inner, err := base64.RawStdEncoding.DecodeString(j.Seed)
+			if err != nil {
+				return false, err
+			}
+
+			sealed, err := a.secret.encrypt(inner, nil)
+			if err != nil {
+				return false, err
+			}
+			w.seed = &lockedSeed{
+				encoded: base64.RawStdEncoding.EncodeToString(sealed),
+				key:     a.secret,
+			}
+		case w.hasSeed():
+			kp, err := keypair.Parse(j.Seed)
+			if err != nil {
+				return false, err
+			}
+
+			if _, ok := kp.(*keypair.Full); !ok || kp.Address() != j.Address {
+				return false, errors.New("seed does not match the address")
+			}
+			w.Keypair = kp
+		}
+
+		a.Stellar.Wallets = append(a.Stellar.Wallets, w)
+	case contactsDir:
+		var c Contact
+		if err := yaml.Unmarshal(body, &c); err != nil {
+			return false, err
+		}
+
+		if a.Stellar.Contacts == nil {
+			a.Stellar.Contacts = map[string]Contact{}
+		}
+		a.Stellar.Contacts[id] = c
+	case rootsDir:
+		var j rootyaml
+		if err := yaml.Unmarshal(body, &j); err != nil {
+			return false, err
+		}
+
+		seed, err := base64.RawStdEncoding.DecodeString(j.Seed)
+		if err != nil {
+			return false, err
+		}
+
+		if r, err := NewRoot(seed); err != nil || r.ID != id {
+			return false, errors.New("seed does not match the root")
+		}
+
+		a.Stellar.Roots = append(a.Stellar.Roots, &Root{ID: id, Seed: seed})
+	}
+
+	return true, nil
+}
+
+// Save only rewrites the entries which changed, and removes the ones which
+// are gone. Changing the password rewrites all of them, see changeKey.
+func (s *DirStore) Save(a *Alfred) error {
+	switch {
+	case a.secret == nil:
+		return errors.New("no secret set")
+	case a.vault != nil:
+		// entries are encrypted with the key of the db, which every
+		// member of the vault has
+		return errors.New("team vaults can only be stored in a file")
+	case a.decoy != nil:
+		return errors.New("dbs with a decoy set can only be stored in a file")
+	}
+
+	entries, err := a.dirEntries()
+	if err != nil {
+		return err
+	}
+
+	header, err := yaml.Marshal(dirheader{
+		Version: FormatVersion,
+		KDF:     a.kdf,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range []string{walletsDir, contactsDir, rootsDir} {
+		if err := os.MkdirAll(filepath.Join(s.Path, dir), 0700); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := lockFile(filepath.Join(s.Path, dirHeader), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	currentHeader, current, err := readDir(s.Path)
+	if err != nil {
+		return err
+	}
+
+	if a.checksum != nil && !bytes.Equal(dirChecksum(currentHeader, current), a.checksum) {
+		return ErrModified
+	}
+
+	// a previous key change may have been interrupted
+	if err := finishKeyChange(s.Path); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(currentHeader, header) {
+		if err := s.changeKey(a, header, entries, current); err != nil {
+			return err
+		}
+
+		header, current, err = readDir(s.Path)
+		if err != nil {
+			return err
+		}
+
+		a.checksum = dirChecksum(header, current)
+		return nil
+	}
+
+	// temporary files by file name
+	written := map[string]string{}
+	defer func() {
+		for _, tmp := range written {
+			os.Remove(tmp)
+		}
+	}()
+
+	for _, name := range sortedEntries(entries) {
+		plaintext := entries[name]
+		if plaintext == nil {
+			// corrupted entry, left as it is
+			continue
+		}
+		if data, ok := current[name]; ok {
+			if body, err := openEntry(a.secret, header, name, data); err == nil && bytes.Equal(body, plaintext) {
+				continue
+			}
+		}
+
+		data, err := sealEntry(a.secret, header, name, plaintext)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(s.Path, filepath.FromSlash(name))
+		if written[path], err = writeTemp(path, data, 0600); err != nil {
+			return err
+		}
+	}
+
+	for path, tmp := range written {
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+		delete(written, path)
+	}
+	for _, dir := range []string{walletsDir, contactsDir, rootsDir} {
+		syncDir(filepath.Join(s.Path, dir))
+	}
+
+	for name := range current {
+		if _, ok := entries[name]; !ok {
+			if err := os.Remove(filepath.Join(s.Path, filepath.FromSlash(name))); err != nil {
+				return err
+			}
+		}
+	}
+
+	header, current, err = readDir(s.Path)
+	if err != nil {
+		return err
+	}
+
+	a.checksum = dirChecksum(header, current)
+	return nil
+}
+
+// changeKey rewrites every entry of the db for the new header. The new
+// entries and header are written as pending files, the header is then moved
+// in place to commit the change, and the entries after it. Removed entries
+// are left as empty pending files until then.
+func (s *DirStore) changeKey(a *Alfred, header []byte, entries, current map[string][]byte) error {
+	next := filepath.Join(s.Path, dirHeader+pendingExt)
+	if err := writeFileAtomic(next, header, 0600); err != nil {
+		return err
+	}
+
+	for _, name := range sortedEntries(entries) {
+		plaintext := entries[name]
+		if plaintext == nil {
+			// corrupted entry, left as it is
+			continue
+		}
+
+		data, err := sealEntry(a.secret, header, name, plaintext)
+		if err != nil {
+			return err
+		}
+
+		if err := writeFileAtomic(pendingPath(s.Path, name), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	for name := range current {
+		if _, ok := entries[name]; !ok {
+			if err := writeFileAtomic(pendingPath(s.Path, name), nil, 0600); err != nil {
+				return err
+			}
+		}
+	}
+
+	if keyChangeHook != nil {
+		if err := keyChangeHook(false); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(next, filepath.Join(s.Path, dirHeader)); err != nil {
+		return err
+	}
+	syncDir(s.Path)
+
+	if keyChangeHook != nil {
+		if err := keyChangeHook(true); err != nil {
+			return err
+		}
+	}
+
+	return finishKeyChange(s.Path)
+}
+
+// finishKeyChange moves the pending entries of a committed key change in
+// place, or removes them if the change was not committed.
+func finishKeyChange(dir string) error {
+	next := filepath.Join(dir, dirHeader+pendingExt)
+	_, err := os.Stat(next)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	committed := err != nil
+
+	for _, sub := range []string{walletsDir, contactsDir, rootsDir} {
+		infos, err := ioutil.ReadDir(filepath.Join(dir, sub))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			name, ok := pendingEntry(info)
+			if !ok {
+				continue
+			}
+
+			pending := filepath.Join(dir, sub, info.Name())
+			target := filepath.Join(dir, sub, name)
+			switch {
+			case !committed:
+				err = os.Remove(pending)
+			case info.Size() == 0:
+				if err = os.Remove(target); err == nil || os.IsNotExist(err) {
+					err = os.Remove(pending)
+				}
+			default:
+				err = os.Rename(pending, target)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		syncDir(filepath.Join(dir, sub))
+	}
+
+	if !committed {
+		if err := os.Remove(next); err != nil {
+			return err
+		}
+		syncDir(dir)
+	}
+
+	return nil
+}
+
+// pendingPath returns the file entry name is written to during a key change.
+func pendingPath(dir, name string) string {
+	return filepath.Join(dir, filepath.FromSlash(path.Dir(name)), "."+path.Base(name)+pendingExt)
+}
+
+// pendingEntry returns the file name of the entry info is pending for, if
+// it is a pending entry.
+func pendingEntry(info os.FileInfo) (string, bool) {
+	name := info.Name()
+	if info.IsDir() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, entryExt+pendingExt) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(name[1:], pendingExt), true
+}
+
+func (s *DirStore) String() string {
+	return dirScheme + absPath(s.Path)
+}
+
+// dirEntries returns the plaintext of every entry, by file name. Corrupted
+// entries have no plaintext.
+func (a *Alfred) dirEntries() (map[string][]byte, error) {
+	entries := map[string][]byte{}
+	folded := map[string]string{}
+	add := func(dir, id string, v interface{}) error {
+		name := path.Join(dir, escapeEntry(id))
+		// the directory may be on a case insensitive file system
+		if other, ok := folded[strings.ToLower(name)]; ok {
+			return fmt.Errorf("%s and %s would be stored in the same file", other, name)
+		}
+		folded[strings.ToLower(name)] = name
+
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		entries[name] = b
+		return nil
+	}
+
+	for _, w := range a.Stellar.Wallets {
+		if w.seed != nil && w.seed.err == errCorruptedEntry {
+			name := path.Join(walletsDir, escapeEntry(w.Keypair.Address()))
+			folded[strings.ToLower(name)] = name
+			entries[name] = nil
+			continue
+		}
+
+		j := w.yaml()
+		switch {
+		case w.hasSeed() && w.password != nil:
+			inner, err := w.innerSeed()
+			if err != nil {
+				return nil, err
+			}
+			j.Seed = base64.RawStdEncoding.EncodeToString(inner)
+			j.Password = w.passwordKDF()
+		case w.hasSeed():
+			kp, ok := w.Full()
+			if !ok {
+				return nil, w.lockedError()
+			}
+			j.Seed = kp.Seed()
+		}
+
+		if err := add(walletsDir, j.Address, j); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, c := range a.Stellar.Contacts {
+		if err := add(contactsDir, name, c); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range a.Stellar.Roots {
+		if r.Seed == nil {
+			return nil, errors.New("you should unlock alfred for writing")
+		}
+
+		j := rootyaml{
+			ID:   r.ID,
+			Seed: base64.RawStdEncoding.EncodeToString(r.Seed),
+		}
+		if err := add(rootsDir, r.ID, j); err != nil {
+			return nil, err
+		}
+	}
+
+	// left as they are, unless an entry of the same name replaces them
+	for _, e := range a.skipped {
+		if _, ok := folded[strings.ToLower(e.name)]; e.name != "" && !ok {
+			entries[e.name] = nil
+		}
+	}
+
+	return entries, nil
+}
+
+// readDir returns the header and the entries of dir, by file name. Other
+// files, such as lock or temporary files, are ignored. The pending entries
+// of a committed key change replace the current ones, see DirStore.
+func readDir(dir string) ([]byte, map[string][]byte, error) {
+	header, err := readUnlocked(filepath.Join(dir, dirHeader))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(dir, dirHeader+pendingExt))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	committed := err != nil
+
+	entries := map[string][]byte{}
+	pending := map[string][]byte{}
+	for _, sub := range []string{walletsDir, contactsDir, rootsDir} {
+		infos, err := ioutil.ReadDir(filepath.Join(dir, sub))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		for _, info := range infos {
+			name := info.Name()
+			if pname, ok := pendingEntry(info); ok && committed {
+				b, err := ioutil.ReadFile(filepath.Join(dir, sub, name))
+				if err != nil {
+					return nil, nil, err
+				}
+				pending[path.Join(sub, pname)] = b
+				continue
+			}
+			if info.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, entryExt) {
+				continue
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, sub, name))
+			if err != nil {
+				return nil, nil, err
+			}
+			entries[path.Join(sub, name)] = b
+		}
+	}
+
+	for name, b := range pending {
+		if len(b) == 0 {
+			delete(entries, name)
+		} else {
+			entries[name] = b
+		}
+	}
+
+	return header, entries, nil
+}
+
+func sortedEntries(entries map[string][]byte) []string {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func dirChecksum(header []byte, entries map[string][]byte) []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s", len(header), header)
+	for _, name := range sortedEntries(entries) {
+		fmt.Fprintf(h, "%s:%d:%s", name, len(entries[name]), entries[name])
+	}
+
+	return h.Sum(nil)
+}
+
+func entryAD(header []byte, name string) []byte {
+	return append(append(append([]byte{}, header...), 0), name...)
+}
+
+func sealEntry(key dbKey, header []byte, name string, plaintext []byte) ([]byte, error) {
+	encrypted, err := key.encrypt(plaintext, entryAD(header, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(entryyaml{Sealed: base64.RawStdEncoding.EncodeToString(encrypted)})
+}
+
+func openEntry(key dbKey, header []byte, name string, data []byte) ([]byte, error) {
+	var j entryyaml
+	if err := yaml.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.RawStdEncoding.DecodeString(j.Sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.decrypt(decoded, entryAD(header, name))
+}
+
+// escapeEntry turns a contact name into a file name, escaping a leading dot
+// so that the file is not hidden.
+func escapeEntry(id string) string {
+	name := url.PathEscape(id)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+
+	return name + entryExt
+}
+
+func unescapeEntry(name string) string {
+	id := strings.TrimSuffix(name, entryExt)
+	if unescaped, err := url.PathUnescape(id); err == nil {
+		return unescaped
+	}
+
+	return id
+}
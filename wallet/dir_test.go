@@ -0,0 +1,244 @@
+package wallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStore(t *testing.T) {
+	for uri, expected := range map[string]Store{
+		"alfred.yaml":        &FileStore{Path: "alfred.yaml", Backups: DefaultBackups},
+		"file:///alfred.yml": &FileStore{Path: "/alfred.yml", Backups: DefaultBackups},
+		"dir://vault":        &DirStore{Path: "vault"},
+	} {
+		store, err := NewStore(uri, nil)
+		require.NoError(t, err)
+		require.Equal(t, expected, store)
+	}
+
+	_, err := NewStore("s3://bucket/alfred.yaml", nil)
+	require.Error(t, err)
+}
+
+func TestDirStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	uri := "dir://" + dir
+	secret := []byte("secret")
+
+	m, err := Open(uri, secret, nil)
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("main", kp)))
+
+	cold, err := keypair.Random()
+	require.NoError(t, err)
+	w, err := NewWatchOnly("cold", cold.Address())
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(w))
+
+	memo, err := MemoFromString(MEMO_ID, "42")
+	require.NoError(t, err)
+	require.NoError(t, m.AddContact("bob/exchange", cold.Address(), memo))
+	require.NoError(t, m.AddContact(".alice", kp.Address(), nil))
+	require.NoError(t, Write(uri, m))
+
+	contact := filepath.Join(dir, contactsDir, "%2Ealice.yaml")
+	b, err := ioutil.ReadFile(contact)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), kp.Address())
+
+	m, err = Open(uri, secret, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 2)
+	require.Equal(t, "cold", m.Stellar.Wallets[0].Name)
+	require.True(t, m.Stellar.Wallets[0].WatchOnly)
+	full, ok := m.WalletByName("main").Full()
+	require.True(t, ok)
+	require.Equal(t, kp.Seed(), full.Seed())
+	require.Equal(t, Contact{Address: cold.Address(), Memo: memo}, m.Stellar.Contacts["bob/exchange"])
+	require.Equal(t, kp.Address(), m.Stellar.Contacts[".alice"].Address)
+
+	// only the changed entry is rewritten
+	wallet := filepath.Join(dir, walletsDir, kp.Address()+".yaml")
+	before, err := ioutil.ReadFile(wallet)
+	require.NoError(t, err)
+	require.NoError(t, m.RemoveContact(".alice"))
+	require.NoError(t, m.RenameWallet(cold.Address(), "frozen"))
+	require.NoError(t, Write(uri, m))
+
+	after, err := ioutil.ReadFile(wallet)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+	_, err = os.Stat(contact)
+	require.True(t, os.IsNotExist(err))
+
+	// without the password, only the addresses are known
+	m, err = Open(uri, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 2)
+	require.Empty(t, m.Stellar.Contacts)
+	require.NotNil(t, m.WalletByAddress(kp.Address()))
+
+	_, err = Open(uri, []byte("wrong"), nil)
+	require.Error(t, err)
+
+	// concurrent writes are detected
+	m1, err := Open(uri, secret, nil)
+	require.NoError(t, err)
+	m2, err := Open(uri, secret, nil)
+	require.NoError(t, err)
+	require.NoError(t, m1.RenameWallet(kp.Address(), "hot"))
+	require.NoError(t, Write(uri, m1))
+	require.NoError(t, m2.RenameWallet(kp.Address(), "other"))
+	require.Equal(t, ErrModified, Write(uri, m2))
+
+	// entries cannot be swapped, the wallet is only disabled and its file
+	// left as it is
+	swapped := filepath.Join(dir, walletsDir, cold.Address()+".yaml")
+	require.NoError(t, os.Rename(wallet, swapped))
+	before, err = ioutil.ReadFile(swapped)
+	require.NoError(t, err)
+	m, err = Open(uri, secret, nil)
+	require.NoError(t, err)
+	w = m.WalletByAddress(cold.Address())
+	_, ok = w.Full()
+	require.False(t, ok)
+	require.Equal(t, errCorruptedEntry, w.SeedError())
+
+	require.NoError(t, m.AddContact("carol", kp.Address(), nil))
+	require.NoError(t, Write(uri, m))
+	after, err = ioutil.ReadFile(swapped)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	// so are contacts, which are skipped
+	carol := filepath.Join(dir, contactsDir, "carol.yaml")
+	bob := filepath.Join(dir, contactsDir, "bob%2Fexchange.yaml")
+	require.NoError(t, os.Rename(carol, bob))
+	before, err = ioutil.ReadFile(bob)
+	require.NoError(t, err)
+	_, err = Open(uri, secret, nil)
+	require.Error(t, err, "no entry could be authenticated")
+	require.NoError(t, ioutil.WriteFile(carol, before, 0600))
+
+	m, err = Open(uri, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"contact bob/exchange"}, m.Skipped())
+	require.Len(t, m.Stellar.Contacts, 1)
+	require.NotEmpty(t, m.Diagnose())
+
+	require.NoError(t, m.AddContact("dave", kp.Address(), nil))
+	require.NoError(t, Write(uri, m))
+	after, err = ioutil.ReadFile(bob)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	// the password changes once every entry is written
+	require.NoError(t, m.RemoveWallet(cold.Address()))
+	require.NoError(t, m.ChangePassword([]byte("new secret")))
+	require.NoError(t, Write(uri, m))
+	m, err = Open(uri, []byte("new secret"), nil)
+	require.NoError(t, err)
+	require.Equal(t, kp.Address(), m.Stellar.Contacts["dave"].Address)
+	require.Equal(t, []string{"contact bob/exchange"}, m.Skipped())
+	infos, err := ioutil.ReadDir(filepath.Join(dir, contactsDir))
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+
+	_, err = Open(uri, []byte("wrong"), nil)
+	require.Error(t, err)
+}
+
+func TestDirStoreInterruptedKeyChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	uri := "dir://" + dir
+	m, err := Open(uri, []byte("secret"), nil)
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("main", kp)))
+	cold, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddContact("bob", cold.Address(), nil))
+	require.NoError(t, m.AddContact("carol", kp.Address(), nil))
+	require.NoError(t, Write(uri, m))
+
+	crash := errors.New("crash")
+	defer func() { keyChangeHook = nil }()
+	check := func(password string) {
+		m, err := Open(uri, []byte(password), nil)
+		require.NoError(t, err)
+		full, ok := m.WalletByName("main").Full()
+		require.True(t, ok)
+		require.Equal(t, kp.Seed(), full.Seed())
+		require.Equal(t, cold.Address(), m.Stellar.Contacts["bob"].Address)
+		require.Empty(t, m.Skipped())
+	}
+
+	// interrupted before the new header is in place, the old password
+	// still opens every entry
+	keyChangeHook = func(committed bool) error {
+		if !committed {
+			return crash
+		}
+		return nil
+	}
+	require.NoError(t, m.RemoveContact("carol"))
+	require.NoError(t, m.ChangePassword([]byte("new secret")))
+	require.Equal(t, crash, Write(uri, m))
+	check("secret")
+	m, err = Open(uri, []byte("secret"), nil)
+	require.NoError(t, err)
+	require.Contains(t, m.Stellar.Contacts, "carol")
+	_, err = Open(uri, []byte("new secret"), nil)
+	require.Error(t, err)
+
+	// interrupted once it is, the new password opens every entry
+	keyChangeHook = func(committed bool) error {
+		if committed {
+			return crash
+		}
+		return nil
+	}
+	require.NoError(t, m.RemoveContact("carol"))
+	require.NoError(t, m.ChangePassword([]byte("new secret")))
+	require.Equal(t, crash, Write(uri, m))
+	check("new secret")
+	_, err = Open(uri, []byte("secret"), nil)
+	require.Error(t, err)
+
+	// the next write completes it
+	keyChangeHook = nil
+	m, err = Open(uri, []byte("new secret"), nil)
+	require.NoError(t, err)
+	require.NotContains(t, m.Stellar.Contacts, "carol")
+	require.NoError(t, m.AddContact("dave", kp.Address(), nil))
+	require.NoError(t, Write(uri, m))
+	check("new secret")
+
+	for _, sub := range []string{"", walletsDir, contactsDir} {
+		infos, err := ioutil.ReadDir(filepath.Join(dir, sub))
+		require.NoError(t, err)
+		for _, info := range infos {
+			require.False(t, strings.HasSuffix(info.Name(), pendingExt), info.Name())
+		}
+	}
+	_, err = os.Stat(filepath.Join(dir, contactsDir, "carol.yaml"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,262 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/stellar/go/clients/horizon"
+)
+
+// Severity tells how much a Finding needs attention.
+type Severity int
+
+const (
+	// Info is worth knowing but needs nothing to be done.
+	Info Severity = iota
+	// Warning should be looked at.
+	Warning
+	// Failure prevents using a part of the db.
+	Failure
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Finding is something Diagnose found about a part of the db.
+type Finding struct {
+	Severity Severity
+	// Subject is the part of the db concerned, such as "wallet main" or
+	// "contact bob".
+	Subject string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Subject, f.Message)
+}
+
+func finding(severity Severity, subject, format string, args ...interface{}) Finding {
+	return Finding{Severity: severity, Subject: subject, Message: fmt.Sprintf(format, args...)}
+}
+
+// Diagnose checks the db without contacting any network: the file matches
+// its schema when loaded with DiagnoseSchema, seeds decrypt and match their
+// addresses, contacts have valid addresses and memos, names are
+// not ambiguous and the KDF is not weaker than the default one.
+func (m *Alfred) Diagnose() []Finding {
+	var findings []Finding
+
+	for _, e := range m.invalid {
+		findings = append(findings, e.Findings()...)
+	}
+
+	if m.vault != nil {
+		for _, member := range m.vault.Members {
+			if member.KDF != nil && member.KDF.Weaker(DefaultKDF) {
+				findings = append(findings, finding(Warning, "member "+member.Name, "password is derived with a KDF weaker than the default one, it should be changed"))
+			}
+		}
+	} else if m.password != nil && m.kdf.Weaker(DefaultKDF) {
+		upgrade := "it is upgraded on the next write"
+		if m.decoy != nil {
+			upgrade = "it is not upgraded behind a decoy set"
+		} else if err := m.UpgradeBlocked(); err != nil {
+			upgrade = err.Error()
+		}
+		findings = append(findings, finding(Warning, "db", "password is derived with a KDF weaker than the default one, %s", upgrade))
+	}
+
+	for _, subject := range m.Skipped() {
+		findings = append(findings, finding(Failure, subject, "could not be decrypted, it may be corrupted or tampered with, it is left out and kept as it is"))
+	}
+
+	roots := make(map[string]bool)
+	for _, r := range m.Stellar.Roots {
+		roots[r.ID] = true
+	}
+
+	names := make(map[string]int)
+	addresses := make(map[string]int)
+	for _, w := range m.Stellar.Wallets {
+		names[w.Name]++
+		addresses[w.Keypair.Address()]++
+		findings = append(findings, m.diagnoseWallet(w, roots)...)
+	}
+
+	for _, w := range m.Stellar.Wallets {
+		subject := "wallet " + w.Name
+		if n := names[w.Name]; n > 1 {
+			findings = append(findings, finding(Warning, subject, "name is shared by %d wallets", n))
+			names[w.Name] = 0
+		}
+		if n := addresses[w.Keypair.Address()]; n > 1 {
+			findings = append(findings, finding(Warning, subject, "address %s is listed %d times", w.Keypair.Address(), n))
+			addresses[w.Keypair.Address()] = 0
+		}
+	}
+
+	contacts := make([]string, 0, len(m.Stellar.Contacts))
+	for name := range m.Stellar.Contacts {
+		contacts = append(contacts, name)
+	}
+	sort.Strings(contacts)
+
+	for _, name := range contacts {
+		c := m.Stellar.Contacts[name]
+		subject := "contact " + name
+		if err := checkContactAddress(c.Address); err != nil {
+			findings = append(findings, finding(Failure, subject, "invalid address: %v", err))
+		}
+		if c.Memo != nil {
+			if err := c.Memo.Validate(); err != nil {
+				findings = append(findings, finding(Failure, subject, "invalid memo: %v", err))
+			}
+		}
+		if w := m.WalletByName(name); w != nil {
+			findings = append(findings, finding(Warning, subject, "name is also the name of wallet %s", w))
+		}
+	}
+
+	return findings
+}
+
+func (m *Alfred) diagnoseWallet(w *Wallet, roots map[string]bool) []Finding {
+	var findings []Finding
+	subject := "wallet " + w.Name
+
+	if w.Derivation != nil && len(roots) > 0 && !roots[w.Derivation.Root] {
+		findings = append(findings, finding(Warning, subject, "derived from root %s, which is not in the db", w.Derivation.Root))
+	}
+
+	switch {
+	case !w.hasSeed():
+		return findings
+	case !m.IsUnlocked():
+		return append(findings, finding(Info, subject, "seed not checked, the db is locked"))
+	case w.vault != nil && w.vault.key == nil:
+		return append(findings, finding(Info, subject, "seed not checked, the wallet was not granted to you"))
+	}
+
+	if _, ok := w.Full(); ok {
+		return findings
+	}
+
+	if err := w.SeedError(); err == ErrWalletPassword {
+		findings = append(findings, finding(Info, subject, "seed not checked, the wallet has a password of its own"))
+	} else if err != nil {
+		findings = append(findings, finding(Failure, subject, "%v", err))
+	} else {
+		findings = append(findings, finding(Failure, subject, "seed is not available"))
+	}
+
+	return findings
+}
+
+// DiagnoseAccount checks the account of w on n: it should exist and its
+// signers should be able to send payments.
+func (w *Wallet) DiagnoseAccount(n Network) ([]Finding, error) {
+	client := n.Client()
+	account, found, err := getAccount(client, w.Keypair.Address())
+	if err != nil {
+		return nil, err
+	}
+
+	subject := "wallet " + w.Name
+	if found {
+		return w.diagnoseSigners(subject, account), nil
+	}
+
+	into, merged, err := mergedInto(client, w.Keypair.Address())
+	if err != nil {
+		return nil, err
+	}
+	if merged {
+		return []Finding{finding(Warning, subject, "account was merged into %s on %s", into, n)}, nil
+	}
+
+	return []Finding{finding(Warning, subject, "account is not funded on %s", n)}, nil
+}
+
+// diagnoseSigners reports accounts whose signers cannot reach the
+// thresholds, and wallets whose seed alone cannot send payments.
+func (w *Wallet) diagnoseSigners(subject string, account horizon.Account) []Finding {
+	var total, master int32
+	for _, s := range account.Signers {
+		total += s.Weight
+		if s.Key == w.Keypair.Address() || s.PublicKey == w.Keypair.Address() {
+			master = s.Weight
+		}
+	}
+
+	medium := threshold(account.Thresholds.MedThreshold)
+	high := threshold(account.Thresholds.HighThreshold)
+	switch {
+	case total < medium:
+		return []Finding{finding(Failure, subject, "account is locked, its signers weigh %d and payments need %d", total, medium)}
+	case total < high:
+		return []Finding{finding(Warning, subject, "its signers weigh %d and changing them needs %d, they can no longer be changed", total, high)}
+	case w.hasSeed() && master < medium:
+		return []Finding{finding(Info, subject, "seed weighs %d and payments need %d, other signers are needed", master, medium)}
+	}
+
+	return nil
+}
+
+// threshold returns the weight needed to reach t, signers weighing 0 never
+// count.
+func threshold(t byte) int32 {
+	if t == 0 {
+		return 1
+	}
+
+	return int32(t)
+}
+
+// mergedInto looks for the merge of address in its last operation.
+func mergedInto(client *horizon.Client, address string) (string, bool, error) {
+	u := fmt.Sprintf("%s/accounts/%s/operations?order=desc&limit=1", client.URL, url.PathEscape(address))
+	resp, err := client.HTTP.Get(u)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", false, nil
+	case resp.StatusCode != http.StatusOK:
+		return "", false, fmt.Errorf("horizon returned %s", resp.Status)
+	}
+
+	var page struct {
+		Embedded struct {
+			Records []struct {
+				Type    string `json:"type"`
+				Account string `json:"account"`
+				Into    string `json:"into"`
+			} `json:"records"`
+		} `json:"_embedded"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return "", false, err
+	}
+
+	for _, r := range page.Embedded.Records {
+		if r.Type == "account_merge" && r.Account == address {
+			return r.Into, true, nil
+		}
+	}
+
+	return "", false, nil
+}
@@ -0,0 +1,139 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestDiagnose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	for _, name := range []string{"main", "savings", "cold"} {
+		kp, err := keypair.Random()
+		require.NoError(t, err)
+		require.NoError(t, m.AddWallet(New(name, kp)))
+	}
+	require.NoError(t, m.WalletByName("cold").SetPassword([]byte("cold")))
+	contact, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddContact("bob", contact.Address(), nil))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, []Finding{
+		{Info, "wallet cold", "seed not checked, the wallet has a password of its own"},
+	}, m.Diagnose())
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var j alfredyaml
+	require.NoError(t, yaml.Unmarshal(b, &j))
+	seed := []byte(j.Stellar.Wallets[0].Seed)
+	seed[10] ^= 1
+	j.Stellar.Wallets[0].Seed = string(seed)
+	j.Stellar.Wallets[1].Name = "main"
+	j.Stellar.Contacts["alice"] = Contact{
+		Address: "GALICE",
+		Memo:    &Memo{Type: MEMO_TEXT, Value: MemoValue{StringValue: strings.Repeat("a", 29)}},
+	}
+	j.Stellar.Contacts["main"] = Contact{Address: contact.Address()}
+	b, err = yaml.Marshal(j)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+
+	// the file is rejected, unless loaded to be diagnosed
+	_, err = Open(path, secret, nil)
+	require.IsType(t, &SchemaError{}, err)
+	problems := err.(*SchemaError).Findings()
+
+	m, err = Open(path, secret, &Options{DiagnoseSchema: true})
+	require.NoError(t, err)
+	findings := m.Diagnose()
+	require.Len(t, problems, 3)
+	require.Equal(t, problems, findings[:3])
+	require.Contains(t, findings[0].Message, "wallet name 'main' is already used")
+	require.Contains(t, findings[1].Message, "invalid contact address")
+	require.Contains(t, findings[2].Message, "text memo")
+
+	findings = findings[3:]
+	require.Len(t, findings, 6)
+	require.Equal(t, Failure, findings[0].Severity)
+	require.Equal(t, "wallet main", findings[0].Subject)
+	require.Equal(t, Info, findings[1].Severity)
+	require.Equal(t, "name is shared by 2 wallets", findings[2].Message)
+	require.Equal(t, "contact alice", findings[3].Subject)
+	require.Contains(t, findings[3].Message, "invalid address")
+	require.Contains(t, findings[4].Message, "invalid memo")
+	require.Equal(t, "contact main", findings[5].Subject)
+
+	// a stronger default is reported, the corrupted seed blocking the upgrade
+	defaultKDF := DefaultKDF
+	defer func() { DefaultKDF = defaultKDF }()
+	DefaultKDF.N *= 2
+	var blocked []string
+	for _, f := range m.Diagnose() {
+		if f.Subject == "db" && f.Severity == Warning {
+			blocked = append(blocked, f.Message)
+		}
+	}
+	require.Len(t, blocked, 1)
+	require.Contains(t, blocked[0], "weaker than the default one, wallet main: seed could not be")
+	require.Contains(t, blocked[0], "it keeps the password on a weak KDF until it is restored or removed")
+
+	// until the next write otherwise
+	require.NoError(t, m.RemoveWallet(m.Stellar.Wallets[0].Keypair.Address()))
+	require.Contains(t, m.Diagnose(), Finding{Warning, "db", "password is derived with a KDF weaker than the default one, it is upgraded on the next write"})
+}
+
+func TestDiagnoseSigners(t *testing.T) {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	w := New("main", kp)
+
+	account := func(master int32, low, med, high byte, others ...int32) horizon.Account {
+		a := horizon.Account{Thresholds: horizon.AccountThresholds{LowThreshold: low, MedThreshold: med, HighThreshold: high}}
+		a.Signers = append(a.Signers, horizon.Signer{Key: kp.Address(), Weight: master})
+		for _, weight := range others {
+			other, err := keypair.Random()
+			require.NoError(t, err)
+			a.Signers = append(a.Signers, horizon.Signer{Key: other.Address(), Weight: weight})
+		}
+		return a
+	}
+
+	require.Empty(t, w.diagnoseSigners("wallet main", account(1, 0, 0, 0)))
+	require.Empty(t, w.diagnoseSigners("wallet main", account(2, 1, 2, 2, 1)))
+
+	findings := w.diagnoseSigners("wallet main", account(0, 0, 0, 0))
+	require.Len(t, findings, 1)
+	require.Equal(t, Failure, findings[0].Severity)
+
+	findings = w.diagnoseSigners("wallet main", account(1, 1, 2, 3, 1))
+	require.Len(t, findings, 1)
+	require.Equal(t, Warning, findings[0].Severity)
+
+	findings = w.diagnoseSigners("wallet main", account(1, 1, 2, 2, 1))
+	require.Len(t, findings, 1)
+	require.Equal(t, Info, findings[0].Severity)
+
+	// nothing is signed with the seed of watch-only wallets
+	watch, err := NewWatchOnly("watch", kp.Address())
+	require.NoError(t, err)
+	require.Empty(t, watch.diagnoseSigners("wallet watch", account(1, 1, 2, 2, 1)))
+}
@@ -0,0 +1,282 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ErrModified is returned by Write when the db changed on disk since it was opened.
+var ErrModified = errors.New("wallet file was modified by another process since it was opened, please retry")
+
+const (
+	backupExt        = ".bak"
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+// Backup is a previous version of the wallet file.
+type Backup struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// FileStore stores the db in a single YAML file, the default store. The
+// previous versions of the file are kept as backups.
+type FileStore struct {
+	Path string
+	// Backups is the number of previous versions of the file kept next to
+	// it. Zero disables backups.
+	Backups int
+}
+
+func (s *FileStore) Load(a *Alfred) (bool, error) {
+	b, err := readFile(s.Path)
+	if err != nil {
+		return false, err
+	}
+
+	a.checksum = checksum(b)
+	if len(b) == 0 {
+		return false, nil
+	}
+
+	invalid := validateSchema(b)
+	if err := a.keepInvalid(invalid); err != nil {
+		return true, err
+	}
+
+	if err := yaml.Unmarshal(b, a); err != nil {
+		if _, ok := invalid.(*SchemaError); ok {
+			// tells more than the decoding error
+			return true, invalid
+		}
+		return true, err
+	}
+
+	return true, nil
+}
+
+// Save writes a to a temporary file which is then renamed over the file, so
+// it is either left untouched or fully replaced. An exclusive lock is held
+// on the file meanwhile, and the previous version is kept as a backup.
+func (s *FileStore) Save(a *Alfred) error {
+	ciphertext, err := yaml.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	backups := s.Backups
+	if a.decoy != nil {
+		// backups behind a decoy set would show when the real wallets change
+		backups = 0
+	}
+
+	if err := replaceFile(s.Path, ciphertext, a.checksum, backups); err != nil {
+		return err
+	}
+
+	a.checksum = checksum(ciphertext)
+	return nil
+}
+
+func (s *FileStore) String() string {
+	return absPath(s.Path)
+}
+
+func checksum(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+// readFile reads path while holding a shared lock on it. A missing file is
+// not an error and returns no data.
+func readFile(path string) ([]byte, error) {
+	unlock, err := lockFile(path, false)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return readUnlocked(path)
+}
+
+func readUnlocked(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return b, err
+}
+
+// replaceFile atomically replaces the content of path with data while holding
+// an exclusive lock on it. The current content is checked against expected,
+// when set, and kept as one of the last backups.
+func replaceFile(path string, data, expected []byte, backups int) error {
+	unlock, err := lockFile(path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := readUnlocked(path)
+	if err != nil {
+		return err
+	}
+
+	if expected != nil && !bytes.Equal(checksum(current), expected) {
+		return ErrModified
+	}
+
+	if err := backupFile(path, current, backups); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0600)
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := writeTemp(path, data, perm)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// writeTemp writes data to a hidden temporary file next to path, flushed to
+// disk, and returns its name.
+func writeTemp(path string, data []byte, perm os.FileMode) (name string, err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = f.Chmod(perm); err != nil {
+		return "", err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		return "", err
+	}
+
+	if err = f.Sync(); err != nil {
+		return "", err
+	}
+
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// syncDir flushes the rename to disk. It is not supported on every platform,
+// in which case the rename is left to the OS.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+func backupFile(path string, data []byte, keep int) error {
+	if keep <= 0 || len(data) == 0 {
+		return nil
+	}
+
+	name := path + "." + time.Now().UTC().Format(backupTimeFormat) + backupExt
+	if err := writeFileAtomic(name, data, 0600); err != nil {
+		return err
+	}
+
+	backups, err := ListBackups(path)
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListBackups returns the backups of path, most recent first.
+func ListBackups(path string) ([]Backup, error) {
+	dir, base := filepath.Dir(path), filepath.Base(path)+"."
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, base) || !strings.HasSuffix(name, backupExt) {
+			continue
+		}
+
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, base), backupExt))
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{
+			Path: filepath.Join(dir, name),
+			Time: t,
+			Size: info.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+
+	return backups, nil
+}
+
+// RestoreBackup replaces path with the content of backup. The current
+// content is itself kept as a backup, unless opts disable backups.
+func RestoreBackup(path string, backup Backup, opts *Options) error {
+	data, err := ioutil.ReadFile(backup.Path)
+	if err != nil {
+		return err
+	}
+
+	if err := validateSchema(data); err != nil {
+		return err
+	}
+
+	var a Alfred
+	if err := yaml.Unmarshal(data, &a); err != nil {
+		return err
+	}
+
+	return replaceFile(path, data, nil, opts.orDefault().Backups)
+}
@@ -0,0 +1,79 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	opts := &Options{Backups: 2}
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	var addresses []string
+	for i := 0; i < 4; i++ {
+		m, err := Open(path, secret, opts)
+		require.NoError(t, err)
+
+		kp, err := keypair.Random()
+		require.NoError(t, err)
+		require.NoError(t, m.AddWallet(New("", kp)))
+		require.NoError(t, Write(path, m))
+
+		addresses = append(addresses, kp.Address())
+	}
+
+	list, err := ListBackups(path)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.True(t, list[0].Time.After(list[1].Time))
+
+	// oldest kept backup is the version with two wallets
+	require.NoError(t, RestoreBackup(path, list[1], opts))
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 2)
+	require.Equal(t, addresses[1], m.Stellar.Wallets[1].Keypair.Address())
+
+	list, err = ListBackups(path)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+}
+
+func TestWriteConflict(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	m1, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	m2, err := Open(path, secret, nil)
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m1.AddWallet(New("", kp)))
+	require.NoError(t, Write(path, m1))
+
+	kp, err = keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m2.AddWallet(New("", kp)))
+	require.Equal(t, ErrModified, Write(path, m2))
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 1)
+}
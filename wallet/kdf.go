@@ -0,0 +1,126 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// FormatVersion is the version of the file format written by Write.
+// Files without a version were written before the key derivation
+// parameters were stored in the header.
+const FormatVersion = 3
+
+const (
+	// KDFSha256 is the unsalted sha256 derivation used by files without a version.
+	// It is only kept to be able to open those files.
+	KDFSha256 = "sha256"
+	// KDFScrypt derives the key using scrypt with the stored salt and costs.
+	KDFScrypt = "scrypt"
+
+	saltSize = 32
+	keySize  = 32
+)
+
+// ErrKeyFileRequired is returned when unlocking a db requiring a key file
+// without one.
+var ErrKeyFileRequired = errors.New("a key file is required to unlock the db (--keyfile)")
+
+// DefaultKDF holds the cost parameters used when creating a file or migrating
+// an existing one. Raising them only affects files written afterwards, files
+// keep the parameters they were written with in their header.
+var DefaultKDF = KDF{
+	Name: KDFScrypt,
+	N:    1 << 15,
+	R:    8,
+	P:    1,
+}
+
+// KDF describes how the encryption key is derived from the password.
+type KDF struct {
+	Name string `yaml:"name" json:"name"`
+	Salt string `yaml:"salt,omitempty" json:"salt,omitempty"`
+	N    int    `yaml:"n,omitempty" json:"n,omitempty"`
+	R    int    `yaml:"r,omitempty" json:"r,omitempty"`
+	P    int    `yaml:"p,omitempty" json:"p,omitempty"`
+	// KeyFile is set when the password is combined with a key file.
+	KeyFile bool `yaml:"keyfile,omitempty" json:"keyfile,omitempty"`
+}
+
+// NewKDF returns the default parameters with a fresh random salt.
+func NewKDF() (KDF, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return KDF{}, err
+	}
+
+	kdf := DefaultKDF
+	kdf.Salt = base64.RawStdEncoding.EncodeToString(salt)
+	return kdf, nil
+}
+
+func legacyKDF() KDF {
+	return KDF{Name: KDFSha256}
+}
+
+// DeriveKey derives the key used to encrypt the seeds from password.
+func (k KDF) DeriveKey(password []byte) ([]byte, error) {
+	switch k.Name {
+	case KDFSha256:
+		h := sha256.New()
+		h.Write(password)
+		return h.Sum(nil), nil
+	case KDFScrypt:
+		salt, err := base64.RawStdEncoding.DecodeString(k.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kdf salt: %v", err)
+		}
+		if len(salt) == 0 {
+			return nil, fmt.Errorf("missing kdf salt")
+		}
+
+		return scrypt.Key(password, salt, k.N, k.R, k.P, keySize)
+	default:
+		return nil, fmt.Errorf("unknown kdf '%s'", k.Name)
+	}
+}
+
+// deriveKeyFile derives the key from password combined with the sha256 of
+// the contents of keyfile, if k requires one.
+func (k KDF) deriveKeyFile(password, keyfile []byte) ([]byte, error) {
+	if !k.KeyFile {
+		return k.DeriveKey(password)
+	}
+
+	if len(keyfile) == 0 {
+		return nil, ErrKeyFileRequired
+	}
+
+	digest := sha256.Sum256(keyfile)
+	combined := append(append([]byte{}, password...), digest[:]...)
+	defer wipe(combined)
+
+	return k.DeriveKey(combined)
+}
+
+func (k *KDF) passwordHint() string {
+	if k != nil && k.KeyFile {
+		return "password or key file may be incorrect"
+	}
+
+	return "password may be incorrect"
+}
+
+// Weaker reports whether k is cheaper to brute-force than other.
+func (k KDF) Weaker(other KDF) bool {
+	if k.Name != other.Name {
+		return k.Name != KDFScrypt
+	}
+
+	return k.N < other.N || k.R < other.R || k.P < other.P
+}
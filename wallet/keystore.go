@@ -0,0 +1,162 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/stellar/go/keypair"
+)
+
+// KeystoreVersion is the version of the keystores written by NewKeystore.
+const KeystoreVersion = 1
+
+// Keystore is a password protected JSON export of wallets, independent of
+// the db they come from.
+type Keystore struct {
+	Version int              `json:"version"`
+	KDF     KDF              `json:"kdf"`
+	Wallets []KeystoreWallet `json:"wallets"`
+}
+
+// KeystoreWallet is a wallet of a keystore. The seed is encrypted with the
+// address as additional data, so that entries cannot be swapped.
+type KeystoreWallet struct {
+	Name         string   `json:"name"`
+	Address      string   `json:"address"`
+	Seed         string   `json:"seed,omitempty"`
+	WatchOnly    bool     `json:"watch_only,omitempty"`
+	RemoteSigner string   `json:"remote_signer,omitempty"`
+	Network      *Network `json:"network,omitempty"`
+	Tags         Tags     `json:"tags,omitempty"`
+	Notes        string   `json:"notes,omitempty"`
+}
+
+// NewKeystore exports wallets, encrypting their seeds under password.
+func NewKeystore(wallets []*Wallet, password []byte) (*Keystore, error) {
+	if len(password) == 0 {
+		return nil, errors.New("password should not be empty")
+	}
+
+	kdf, err := NewKDF()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kdf.DeriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &Keystore{
+		Version: KeystoreVersion,
+		KDF:     kdf,
+	}
+	for _, w := range wallets {
+		kw := KeystoreWallet{
+			Name:         w.Name,
+			Address:      w.Keypair.Address(),
+			WatchOnly:    w.WatchOnly,
+			RemoteSigner: w.RemoteSigner,
+			Network:      w.Network,
+			Tags:         w.Tags,
+			Notes:        w.Notes,
+		}
+
+		if w.hasSeed() {
+			kp, ok := w.Full()
+			if !ok {
+				return nil, w.lockedError()
+			}
+
+			encrypted, err := encryptWithAD(key, getSeed(kp.Seed()), []byte(kw.Address))
+			if err != nil {
+				return nil, err
+			}
+			kw.Seed = base64.RawStdEncoding.EncodeToString(encrypted)
+		}
+
+		ks.Wallets = append(ks.Wallets, kw)
+	}
+
+	return ks, nil
+}
+
+// ParseKeystore decodes a keystore written by NewKeystore.
+func ParseKeystore(b []byte) (*Keystore, error) {
+	var ks Keystore
+	if err := json.Unmarshal(b, &ks); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+
+	switch {
+	case ks.Version == 0:
+		return nil, errors.New("invalid keystore: missing version")
+	case ks.Version > KeystoreVersion:
+		return nil, fmt.Errorf("unsupported keystore version %d, please upgrade alfred", ks.Version)
+	}
+
+	return &ks, nil
+}
+
+// Decrypt returns the wallets of the keystore, failing if any of the seeds
+// cannot be decrypted with password.
+func (ks *Keystore) Decrypt(password []byte) ([]*Wallet, error) {
+	key, err := ks.KDF.DeriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []*Wallet
+	for _, kw := range ks.Wallets {
+		w, err := NewWatchOnly(kw.Name, kw.Address)
+		if err != nil {
+			return nil, fmt.Errorf("wallet '%s': %v", kw.Name, err)
+		}
+		w.WatchOnly = kw.WatchOnly
+		if kw.RemoteSigner != "" {
+			if err := CheckSignerURL(kw.RemoteSigner); err != nil {
+				return nil, fmt.Errorf("wallet '%s': %v", kw.Name, err)
+			}
+		}
+		w.RemoteSigner = kw.RemoteSigner
+		w.Tags = kw.Tags
+		w.Notes = kw.Notes
+		if kw.Network != nil {
+			n, err := NewNetwork(kw.Network.Name, kw.Network.Passphrase, kw.Network.Horizon)
+			if err != nil {
+				return nil, fmt.Errorf("wallet '%s': %v", kw.Name, err)
+			}
+			w.Network = &n
+		}
+
+		if w.hasSeed() {
+			decoded, err := base64.RawStdEncoding.DecodeString(kw.Seed)
+			if err != nil {
+				return nil, fmt.Errorf("wallet '%s': %v", kw.Name, err)
+			}
+
+			seed, err := decryptWithAD(key, decoded, []byte(kw.Address))
+			if err != nil {
+				return nil, errors.New("could not decrypt keystore, password may be incorrect")
+			}
+
+			var raw [32]byte
+			copy(raw[:], seed)
+			kp, err := keypair.FromRawSeed(raw)
+			if err != nil {
+				return nil, err
+			}
+
+			if kp.Address() != kw.Address {
+				return nil, fmt.Errorf("wallet '%s': address mismatch", kw.Name)
+			}
+			w.Keypair = kp
+		}
+
+		wallets = append(wallets, w)
+	}
+
+	return wallets, nil
+}
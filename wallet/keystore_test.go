@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystore(t *testing.T) {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	hot := New("hot", kp)
+	hot.Network = &TestNetwork
+
+	cold, err := keypair.Random()
+	require.NoError(t, err)
+	watch, err := NewWatchOnly("cold", cold.Address())
+	require.NoError(t, err)
+
+	ks, err := NewKeystore([]*Wallet{hot, watch}, []byte("keystore password"))
+	require.NoError(t, err)
+
+	b, err := json.Marshal(ks)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), kp.Seed())
+
+	ks, err = ParseKeystore(b)
+	require.NoError(t, err)
+
+	_, err = ks.Decrypt([]byte("wrong password"))
+	require.Error(t, err)
+
+	wallets, err := ks.Decrypt([]byte("keystore password"))
+	require.NoError(t, err)
+	require.Len(t, wallets, 2)
+
+	full, ok := wallets[0].Full()
+	require.True(t, ok)
+	require.Equal(t, kp.Seed(), full.Seed())
+	require.Equal(t, TestNetwork, *wallets[0].Network)
+
+	require.True(t, wallets[1].WatchOnly)
+	require.Equal(t, cold.Address(), wallets[1].Keypair.Address())
+
+	// seeds are bound to their address
+	ks.Wallets[0].Address = cold.Address()
+	_, err = ks.Decrypt([]byte("keystore password"))
+	require.Error(t, err)
+
+	m := &Alfred{}
+	require.NoError(t, m.AddWallet(wallets[0]))
+	require.Error(t, m.AddWallet(wallets[0]))
+	other, err := NewWatchOnly("hot", cold.Address())
+	require.NoError(t, err)
+	require.Error(t, m.AddWallet(other))
+}
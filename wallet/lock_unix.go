@@ -0,0 +1,33 @@
+//go:build !windows
+// +build !windows
+
+package wallet
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an advisory lock on path. The lock is held on a separate
+// file because path itself is replaced on every write.
+func lockFile(path string, exclusive bool) (func() error, error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer f.Close()
+		return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}, nil
+}
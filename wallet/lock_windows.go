@@ -0,0 +1,73 @@
+//go:build windows
+// +build windows
+
+package wallet
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const lockTimeout = 10 * time.Second
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockFile takes a lock on path with LockFileEx, released by the system if
+// the process dies. The lock is held on a separate file because path itself
+// is replaced on every write.
+func lockFile(path string, exclusive bool) (func() error, error) {
+	name := path + ".lock"
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := uintptr(lockfileFailImmediately)
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		var ol syscall.Overlapped
+		r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+		if r != 0 {
+			break
+		}
+
+		if err != errorLockViolation {
+			f.Close()
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%s is locked by another process", path)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return func() error {
+		defer f.Close()
+
+		var ol syscall.Overlapped
+		if r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&ol))); r == 0 {
+			return err
+		}
+		return nil
+	}, nil
+}
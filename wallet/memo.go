@@ -3,6 +3,7 @@ package wallet
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/stellar/go/build"
 	"github.com/stellar/go/xdr"
@@ -18,6 +19,9 @@ const (
 	MEMO_RETURN                     // return
 )
 
+// maxMemoText is the maximum length of a text memo, in bytes.
+const maxMemoText = 28
+
 type Memo struct {
 	Type  MemoKind  `yaml:"type,omitempty"`
 	Value MemoValue `yaml:"value,omitempty"`
@@ -38,25 +42,54 @@ func MemoFromString(kind MemoKind, str string) (*Memo, error) {
 	case MEMO_TEXT:
 		memo.Value.StringValue = str
 	case MEMO_ID:
-		id, err := strconv.Atoi(str)
+		id, err := strconv.ParseUint(str, 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("id memo should be a positive integer, not '%s'", str)
 		}
-		memo.Value.IntValue = uint64(id)
+		memo.Value.IntValue = id
 	case MEMO_RETURN, MEMO_HASH:
 		var hash xdr.Hash
 		err := xdr.SafeUnmarshalBase64(str, &hash)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s memo should be 32 bytes encoded in base64, not '%s'", kind, str)
 		}
 		memo.Value.HashValue = hash
 	default:
 		return nil, fmt.Errorf("unknown kind '%v'", kind)
 	}
 
+	if err := memo.Validate(); err != nil {
+		return nil, err
+	}
+
 	return memo, nil
 }
 
+// ParseMemoKind returns the kind named str, as printed by MemoKind.String.
+func ParseMemoKind(str string) (MemoKind, error) {
+	for _, kind := range []MemoKind{MEMO_TEXT, MEMO_ID, MEMO_HASH, MEMO_RETURN} {
+		if strings.EqualFold(kind.String(), str) {
+			return kind, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown memo type '%s', should be text, id, hash or return", str)
+}
+
+func (m Memo) String() string {
+	switch m.Type {
+	case MEMO_TEXT:
+		return fmt.Sprintf("%s %q", m.Type, m.Value.StringValue)
+	case MEMO_ID:
+		return fmt.Sprintf("%s %d", m.Type, m.Value.IntValue)
+	case MEMO_RETURN, MEMO_HASH:
+		str, _ := xdr.MarshalBase64(m.Value.HashValue)
+		return fmt.Sprintf("%s %s", m.Type, str)
+	default:
+		return m.Type.String()
+	}
+}
+
 func (m Memo) ToTransactionMutator() build.TransactionMutator {
 	switch m.Type {
 	case MEMO_TEXT:
@@ -104,32 +137,71 @@ func (m *Memo) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	str, _ := in["type"].(string)
+	kind, err := ParseMemoKind(str)
+	if err != nil {
+		return err
+	}
+
 	v := in["value"]
-	switch kind := in["type"]; kind {
-	case MEMO_TEXT.String():
-		m.Type = MEMO_TEXT
-		m.Value.StringValue = v.(string)
-	case MEMO_ID.String():
-		m.Type = MEMO_ID
-		m.Value.IntValue = uint64(v.(int))
-	case MEMO_RETURN.String():
-		m.Type = MEMO_RETURN
-		var hash xdr.Hash
-		err := xdr.SafeUnmarshalBase64(v.(string), &hash)
+	switch kind {
+	case MEMO_TEXT:
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("text memo should be a string, not '%v'", v)
+		}
+		m.Value.StringValue = str
+	case MEMO_ID:
+		id, err := memoID(v)
 		if err != nil {
 			return err
 		}
-		m.Value.HashValue = hash
-	case MEMO_HASH.String():
-		m.Type = MEMO_HASH
+		m.Value.IntValue = id
+	case MEMO_RETURN, MEMO_HASH:
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s memo should be a base64 string, not '%v'", kind, v)
+		}
 		var hash xdr.Hash
-		err := xdr.SafeUnmarshalBase64(v.(string), &hash)
+		err := xdr.SafeUnmarshalBase64(str, &hash)
 		if err != nil {
 			return err
 		}
 		m.Value.HashValue = hash
+	}
+
+	m.Type = kind
+	return nil
+}
+
+// memoID returns the id memo decoded by yaml as v.
+func memoID(v interface{}) (uint64, error) {
+	switch id := v.(type) {
+	case int:
+		if id >= 0 {
+			return uint64(id), nil
+		}
+	case int64:
+		if id >= 0 {
+			return uint64(id), nil
+		}
+	case uint64:
+		return id, nil
+	}
+
+	return 0, fmt.Errorf("id memo should be a positive integer, not '%v'", v)
+}
+
+// Validate returns an error if m could not be attached to a transaction.
+func (m Memo) Validate() error {
+	switch m.Type {
+	case MEMO_TEXT:
+		if len(m.Value.StringValue) > maxMemoText {
+			return fmt.Errorf("text memo is %d bytes long, it should not exceed %d bytes", len(m.Value.StringValue), maxMemoText)
+		}
+	case MEMO_ID, MEMO_HASH, MEMO_RETURN:
 	default:
-		return fmt.Errorf("unknown memo type: '%v'", kind)
+		return fmt.Errorf("unknown memo type: '%v'", m.Type)
 	}
 
 	return nil
@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/stellar/go/keypair"
+	bip39 "github.com/tyler-smith/go-bip39"
+)
+
+// StellarAccountPath is the SEP-0005 derivation path, %d being the account index.
+const StellarAccountPath = "m/44'/148'/%d'"
+
+const (
+	hardenedOffset = 0x80000000
+	purpose        = 44
+	coinType       = 148
+)
+
+// Root is a SEP-0005 seed from which wallets are derived. It is identified
+// by the address of its first account.
+type Root struct {
+	ID string
+	// Seed is nil until alfred is unlocked.
+	Seed []byte
+}
+
+// Derivation records how a wallet was derived from a Root.
+type Derivation struct {
+	Root  string `yaml:"root"`
+	Index uint32 `yaml:"index"`
+}
+
+func (d Derivation) Path() string {
+	return fmt.Sprintf(StellarAccountPath, d.Index)
+}
+
+// NewMnemonic generates a BIP-39 mnemonic of 12 or 24 words.
+func NewMnemonic(words int) (string, error) {
+	var bits int
+	switch words {
+	case 12:
+		bits = 128
+	case 24:
+		bits = 256
+	default:
+		return "", fmt.Errorf("mnemonic should have 12 or 24 words, not %d", words)
+	}
+
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+// SeedFromMnemonic validates mnemonic and returns the BIP-39 seed derived
+// from it and the optional passphrase.
+func SeedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	return bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+}
+
+// DeriveKeypair derives the keypair of the account at index following
+// SEP-0005, that is SLIP-0010 ed25519 derivation along m/44'/148'/index'.
+func DeriveKeypair(seed []byte, index uint32) (*keypair.Full, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("seed is locked")
+	}
+
+	key, chainCode := slip10Master(seed)
+	for _, i := range []uint32{purpose, coinType, index} {
+		key, chainCode = slip10Child(key, chainCode, i)
+	}
+
+	var raw [32]byte
+	copy(raw[:], key)
+	return keypair.FromRawSeed(raw)
+}
+
+func slip10Master(seed []byte) (key, chainCode []byte) {
+	h := hmac.New(sha512.New, []byte("ed25519 seed"))
+	h.Write(seed)
+	sum := h.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// slip10Child derives the hardened child i, ed25519 only supports those.
+func slip10Child(key, chainCode []byte, i uint32) ([]byte, []byte) {
+	data := make([]byte, 1+32+4)
+	copy(data[1:], key)
+	binary.BigEndian.PutUint32(data[33:], i+hardenedOffset)
+
+	h := hmac.New(sha512.New, chainCode)
+	h.Write(data)
+	sum := h.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// NewRoot returns the root for a BIP-39 seed.
+func NewRoot(seed []byte) (*Root, error) {
+	kp, err := DeriveKeypair(seed, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Root{
+		ID:   kp.Address(),
+		Seed: seed,
+	}, nil
+}
+
+// AddRoot stores root so that more wallets can be derived from it later.
+// The already stored root is returned if it was added before.
+func (m *Alfred) AddRoot(root *Root) *Root {
+	if r := m.RootByID(root.ID); r != nil {
+		if r.Seed == nil {
+			r.Seed = root.Seed
+		}
+		return r
+	}
+
+	m.Stellar.Roots = append(m.Stellar.Roots, root)
+	return root
+}
+
+func (m *Alfred) RootByID(id string) *Root {
+	for _, r := range m.Stellar.Roots {
+		if r.ID == id {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// DeriveWallet derives the wallet at index from root.
+func (m *Alfred) DeriveWallet(root *Root, name string, index uint32) (*Wallet, error) {
+	kp, err := DeriveKeypair(root.Seed, index)
+	if err != nil {
+		return nil, err
+	}
+
+	w := New(name, kp)
+	w.Derivation = &Derivation{
+		Root:  root.ID,
+		Index: index,
+	}
+	return w, nil
+}
+
+// NextIndex returns the index following the last wallet derived from root.
+func (m *Alfred) NextIndex(root *Root) uint32 {
+	var next uint32
+	for _, w := range m.Stellar.Wallets {
+		if w.Derivation != nil && w.Derivation.Root == root.ID && w.Derivation.Index >= next {
+			next = w.Derivation.Index + 1
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,82 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveKeypair(t *testing.T) {
+	// SEP-0005 test vector 1
+	seed, err := SeedFromMnemonic("illness spike retreat truth genius clock brain pass fit cave bargain toe", "")
+	require.NoError(t, err)
+
+	var tests = []struct {
+		index   uint32
+		address string
+		seed    string
+	}{
+		{0, "GDRXE2BQUC3AZNPVFSCEZ76NJ3WWL25FYFK6RGZGIEKWE4SOOHSUJUJ6", "SBGWSG6BTNCKCOB3DIFBGCVMUPQFYPA2G4O34RMTB343OYPXU5DJDVMN"},
+		{1, "GBAW5XGWORWVFE2XTJYDTLDHXTY2Q2MO73HYCGB3XMFMQ562Q2W2GJQX", "SCEPFFWGAG5P2VX5DHIYK3XEMZYLTYWIPWYEKXFHSK25RVMIUNJ7CTIS"},
+	}
+
+	for _, test := range tests {
+		kp, err := DeriveKeypair(seed, test.index)
+		require.NoError(t, err)
+		require.Equal(t, test.address, kp.Address())
+		require.Equal(t, test.seed, kp.Seed())
+	}
+
+	_, err = SeedFromMnemonic("illness spike retreat truth genius clock brain pass fit cave bargain bargain", "")
+	require.Error(t, err)
+}
+
+func TestMnemonicWallets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	mnemonic, err := NewMnemonic(24)
+	require.NoError(t, err)
+	require.Len(t, strings.Fields(mnemonic), 24)
+
+	seed, err := SeedFromMnemonic(mnemonic, "passphrase")
+	require.NoError(t, err)
+	root, err := NewRoot(seed)
+	require.NoError(t, err)
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	root = m.AddRoot(root)
+	w, err := m.DeriveWallet(root, "first", m.NextIndex(root))
+	require.NoError(t, err)
+	require.Equal(t, root.ID, w.Keypair.Address())
+	require.NoError(t, m.AddWallet(w))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	root = m.RootByID(root.ID)
+	require.NotNil(t, root)
+	require.Equal(t, uint32(1), m.NextIndex(root))
+
+	w, err = m.DeriveWallet(root, "second", m.NextIndex(root))
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(w))
+
+	want, err := DeriveKeypair(seed, 1)
+	require.NoError(t, err)
+	require.Equal(t, want.Address(), w.Keypair.Address())
+	require.Equal(t, "m/44'/148'/1'", w.Derivation.Path())
+
+	m, err = Open(path, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, m.RootByID(root.ID).Seed)
+}
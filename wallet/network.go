@@ -0,0 +1,111 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/clients/horizon"
+	"github.com/stellar/go/network"
+)
+
+const (
+	// PublicNetworkName is the name of the SDF public network.
+	PublicNetworkName = "public"
+	// TestNetworkName is the name of the SDF test network.
+	TestNetworkName = "testnet"
+)
+
+var (
+	PublicNetwork = Network{
+		Name:       PublicNetworkName,
+		Passphrase: network.PublicNetworkPassphrase,
+		Horizon:    horizon.DefaultPublicNetClient.URL,
+	}
+	TestNetwork = Network{
+		Name:       TestNetworkName,
+		Passphrase: network.TestNetworkPassphrase,
+		Horizon:    horizon.DefaultTestNetClient.URL,
+	}
+)
+
+// Network is the stellar network a wallet belongs to. Transactions are signed
+// for its passphrase and submitted to its horizon server.
+type Network struct {
+	Name       string `yaml:"name" json:"name"`
+	Passphrase string `yaml:"passphrase,omitempty" json:"passphrase,omitempty"`
+	Horizon    string `yaml:"horizon,omitempty" json:"horizon,omitempty"`
+}
+
+// NewNetwork returns the network called name. The passphrase can only be
+// omitted for the public and test networks, an empty horizon URL uses
+// their default server.
+func NewNetwork(name, passphrase, horizonURL string) (Network, error) {
+	var n Network
+	switch name {
+	case PublicNetworkName:
+		n = PublicNetwork
+	case TestNetworkName:
+		n = TestNetwork
+	case "":
+		return Network{}, errors.New("network name should be set")
+	default:
+		n = Network{Name: name}
+	}
+
+	if passphrase != "" {
+		if n.Passphrase != "" && n.Passphrase != passphrase {
+			return Network{}, fmt.Errorf("passphrase of the %s network cannot be changed", name)
+		}
+		n.Passphrase = passphrase
+	}
+	if horizonURL != "" {
+		n.Horizon = horizonURL
+	}
+
+	switch {
+	case n.Passphrase == "":
+		return Network{}, fmt.Errorf("network '%s' needs a passphrase", name)
+	case n.Horizon == "":
+		return Network{}, fmt.Errorf("network '%s' needs a horizon url", name)
+	}
+
+	return n, nil
+}
+
+func (n Network) String() string {
+	return n.Name
+}
+
+// Equal reports whether transactions signed for n are valid on other.
+func (n Network) Equal(other Network) bool {
+	return n.Passphrase == other.Passphrase
+}
+
+// Client returns a horizon client for the network.
+func (n Network) Client() *horizon.Client {
+	return &horizon.Client{
+		URL:  n.Horizon,
+		HTTP: http.DefaultClient,
+	}
+}
+
+// Mutator returns the transaction mutator signing for the network.
+func (n Network) Mutator() build.Network {
+	return build.Network{Passphrase: n.Passphrase}
+}
+
+// toYAML omits the passphrase and horizon url of the public and test
+// networks when they are the default ones.
+func (n Network) toYAML() *Network {
+	switch {
+	case n.Name == PublicNetworkName && n.Horizon == PublicNetwork.Horizon,
+		n.Name == TestNetworkName && n.Horizon == TestNetwork.Horizon:
+		return &Network{Name: n.Name}
+	case n.Name == PublicNetworkName, n.Name == TestNetworkName:
+		return &Network{Name: n.Name, Horizon: n.Horizon}
+	}
+
+	return &n
+}
@@ -0,0 +1,47 @@
+package wallet
+
+import "crypto/x509"
+
+// DefaultBackups is the number of previous versions of a file db kept when
+// no options are given.
+const DefaultBackups = 10
+
+// Options are the settings a db is opened with. The Alfred opened keeps a
+// copy of them, used again when it is written.
+type Options struct {
+	// KeyFile holds the contents of the key file combined with the password
+	// of the dbs requiring one. Dbs created while it is set require it.
+	KeyFile []byte
+	// Backups is the number of previous versions of the file kept next to
+	// it by the FileStore. Zero disables backups.
+	Backups int
+	// Member is the member team vaults are opened as, and MemberKey its
+	// private key, for members unlocking the vault with a key instead of a
+	// password.
+	Member    string
+	MemberKey *MemberKey
+	// DiagnoseSchema loads file dbs which do not match their schema, as far
+	// as they can be decoded, their problems being reported by
+	// Alfred.Diagnose instead.
+	DiagnoseSchema bool
+	// SignerTokens are sent as bearer tokens to remote signers, keyed by the
+	// url of the signer each of them is sent to.
+	SignerTokens map[string]string
+	// SignerRoots, if set, replaces the system roots to verify the
+	// certificate of https signers.
+	SignerRoots *x509.CertPool
+}
+
+// DefaultOptions returns the options used when none are given: DefaultBackups
+// backups are kept and nothing else is set.
+func DefaultOptions() *Options {
+	return &Options{Backups: DefaultBackups}
+}
+
+func (o *Options) orDefault() *Options {
+	if o == nil {
+		return DefaultOptions()
+	}
+
+	return o
+}
@@ -0,0 +1,420 @@
+package wallet
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/xdr"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// SchemaError lists every problem found in a file db, so that they can all
+// be fixed at once.
+type SchemaError struct {
+	Problems []SchemaProblem
+	// Sealed is set for the problems of the sealed content of the db, their
+	// lines being counted from the start of that content.
+	Sealed bool
+}
+
+// SchemaProblem is a problem found at a line and column of a file db.
+type SchemaProblem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p SchemaProblem) String() string {
+	return fmt.Sprintf("line %d, column %d: %s", p.Line, p.Column, p.Message)
+}
+
+func (e *SchemaError) Error() string {
+	header := fmt.Sprintf("invalid db, %d problem(s) found:", len(e.Problems))
+	if e.Sealed {
+		header = fmt.Sprintf("invalid sealed content, %d problem(s) found, lines are counted from the start of the decrypted content:", len(e.Problems))
+	}
+
+	lines := []string{header}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Findings returns the problems of e as failures of the db.
+func (e *SchemaError) Findings() []Finding {
+	subject := "db"
+	if e.Sealed {
+		subject = "sealed content"
+	}
+
+	var findings []Finding
+	for _, p := range e.Problems {
+		findings = append(findings, finding(Failure, subject, "%s", p))
+	}
+
+	return findings
+}
+
+// validateSchema checks the whole document b against the format of file
+// dbs, and returns a *SchemaError listing every problem found. Files written
+// by a newer version of alfred are rejected first. The sealed content is
+// checked once decrypted, see validateSealed, and the padding is not.
+func validateSchema(b []byte) error {
+	root, err := parseSchema(b)
+	if err != nil || root == nil {
+		return err
+	}
+
+	if v := mappingValue(root, "version"); v != nil {
+		var version int
+		if v.Decode(&version) == nil {
+			if err := checkVersion(version); err != nil {
+				return err
+			}
+		}
+	}
+
+	var s schema
+	s.check(root, reflect.TypeOf(alfredyaml{}))
+	if stellar := mappingValue(root, "stellar"); stellar != nil {
+		s.checkStellar(stellar)
+	}
+
+	return s.err(false)
+}
+
+// validateSealed checks the decrypted sealed content b of a db.
+func validateSealed(b []byte) error {
+	root, err := parseSchema(b)
+	if err != nil || root == nil {
+		return err
+	}
+
+	var s schema
+	s.check(root, reflect.TypeOf(stellaryaml{}))
+	s.checkStellar(root)
+
+	return s.err(true)
+}
+
+// parseSchema returns the root node of the document b, or nil if it is
+// empty.
+func parseSchema(b []byte) (*yaml3.Node, error) {
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	return resolve(doc.Content[0]), nil
+}
+
+// keepInvalid returns err, unless it is a *SchemaError and the options of a
+// set DiagnoseSchema, in which case it is kept to be reported by Diagnose.
+func (a *Alfred) keepInvalid(err error) error {
+	e, ok := err.(*SchemaError)
+	if !ok || !a.opts.DiagnoseSchema {
+		return err
+	}
+
+	a.invalid = append(a.invalid, e)
+	return nil
+}
+
+type schema struct {
+	problems []SchemaProblem
+}
+
+func (s *schema) err(sealed bool) error {
+	if len(s.problems) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(s.problems, func(i, j int) bool {
+		pi, pj := s.problems[i], s.problems[j]
+		return pi.Line < pj.Line || pi.Line == pj.Line && pi.Column < pj.Column
+	})
+	return &SchemaError{Problems: s.problems, Sealed: sealed}
+}
+
+func (s *schema) add(n *yaml3.Node, format string, args ...interface{}) {
+	s.problems = append(s.problems, SchemaProblem{
+		Line:    n.Line,
+		Column:  n.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+var memoType = reflect.TypeOf(Memo{})
+
+// check reports the nodes of n which could not be decoded as t, or are not
+// part of it.
+func (s *schema) check(n *yaml3.Node, t reflect.Type) {
+	n = resolve(n)
+	if n.Kind == yaml3.ScalarNode && n.ShortTag() == "!!null" {
+		return
+	}
+
+	if t == memoType {
+		s.checkMemo(n)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s.check(n, t.Elem())
+	case reflect.Struct:
+		if n.Kind != yaml3.MappingNode {
+			s.add(n, "expected a mapping")
+			return
+		}
+
+		fields := yamlFields(t)
+		s.eachKey(n, func(k, v *yaml3.Node) {
+			f, ok := fields[k.Value]
+			if !ok {
+				s.add(k, "unknown field '%s'", k.Value)
+				return
+			}
+			s.check(v, f)
+		})
+	case reflect.Map:
+		if n.Kind != yaml3.MappingNode {
+			s.add(n, "expected a mapping")
+			return
+		}
+
+		s.eachKey(n, func(k, v *yaml3.Node) {
+			s.check(v, t.Elem())
+		})
+	case reflect.Slice:
+		if n.Kind != yaml3.SequenceNode {
+			s.add(n, "expected a list")
+			return
+		}
+
+		for _, item := range n.Content {
+			s.check(item, t.Elem())
+		}
+	case reflect.String:
+		if n.Kind != yaml3.ScalarNode {
+			s.add(n, "expected a string")
+		}
+	default:
+		if n.Kind != yaml3.ScalarNode || n.Decode(reflect.New(t).Interface()) != nil {
+			s.add(n, "expected %s, not '%s'", kindName(t), n.Value)
+		}
+	}
+}
+
+// eachKey calls fn for the keys of the mapping n, reporting the keys set
+// twice.
+func (s *schema) eachKey(n *yaml3.Node, fn func(k, v *yaml3.Node)) {
+	seen := make(map[string]*yaml3.Node)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := resolve(n.Content[i]), n.Content[i+1]
+		if first, ok := seen[k.Value]; ok {
+			s.add(k, "'%s' is already set at line %d", k.Value, first.Line)
+			continue
+		}
+		seen[k.Value] = k
+
+		fn(k, v)
+	}
+}
+
+func (s *schema) checkMemo(n *yaml3.Node) {
+	if n.Kind != yaml3.MappingNode {
+		s.add(n, "expected a memo")
+		return
+	}
+
+	var kind, value *yaml3.Node
+	s.eachKey(n, func(k, v *yaml3.Node) {
+		switch k.Value {
+		case "type":
+			kind = resolve(v)
+		case "value":
+			value = resolve(v)
+		default:
+			s.add(k, "unknown field '%s'", k.Value)
+		}
+	})
+
+	if kind == nil {
+		s.add(n, "memo type is missing")
+		return
+	}
+
+	memoKind, err := ParseMemoKind(kind.Value)
+	if err != nil {
+		s.add(kind, "%v", err)
+		return
+	}
+
+	var v interface{}
+	if value == nil || value.Kind != yaml3.ScalarNode || value.Decode(&v) != nil {
+		s.add(n, "%s memo value is missing", memoKind)
+		return
+	}
+
+	switch memoKind {
+	case MEMO_TEXT:
+		str, ok := v.(string)
+		if !ok {
+			s.add(value, "text memo should be a string, not '%s'", value.Value)
+		} else if err := (Memo{Type: MEMO_TEXT, Value: MemoValue{StringValue: str}}).Validate(); err != nil {
+			s.add(value, "%v", err)
+		}
+	case MEMO_ID:
+		if _, err := memoID(v); err != nil {
+			s.add(value, "%v", err)
+		}
+	case MEMO_HASH, MEMO_RETURN:
+		var hash xdr.Hash
+		str, ok := v.(string)
+		if !ok || xdr.SafeUnmarshalBase64(str, &hash) != nil {
+			s.add(value, "%s memo should be 32 bytes encoded in base64, not '%s'", memoKind, value.Value)
+		}
+	}
+}
+
+func (s *schema) checkStellar(n *yaml3.Node) {
+	s.checkWallets(mappingValue(n, "wallets"))
+	s.checkContacts(mappingValue(n, "contacts"))
+}
+
+// checkWallets reports wallets without a name or a valid address, and
+// wallets listed twice.
+func (s *schema) checkWallets(n *yaml3.Node) {
+	if n == nil || n.Kind != yaml3.SequenceNode {
+		return
+	}
+
+	names := make(map[string]*yaml3.Node)
+	addresses := make(map[string]*yaml3.Node)
+	for _, item := range n.Content {
+		item = resolve(item)
+		if item.Kind != yaml3.MappingNode {
+			continue
+		}
+
+		name := mappingValue(item, "name")
+		switch {
+		case name == nil || name.Value == "":
+			s.add(item, "wallet name should not be empty")
+		case names[name.Value] != nil:
+			s.add(name, "wallet name '%s' is already used at line %d", name.Value, names[name.Value].Line)
+		default:
+			names[name.Value] = name
+		}
+
+		address := mappingValue(item, "address")
+		if address == nil || address.Value == "" {
+			s.add(item, "wallet address is missing")
+			continue
+		}
+
+		kp, err := keypair.Parse(address.Value)
+		switch {
+		case err != nil:
+			s.add(address, "invalid wallet address: %v", err)
+		case kp.Address() != address.Value:
+			s.add(address, "wallet address should be an address, not a seed")
+		case addresses[address.Value] != nil:
+			s.add(address, "wallet %s is already listed at line %d", TrimAddress(address.Value), addresses[address.Value].Line)
+		default:
+			addresses[address.Value] = address
+		}
+	}
+}
+
+// checkContacts reports contacts without a name or a valid address.
+func (s *schema) checkContacts(n *yaml3.Node) {
+	if n == nil || n.Kind != yaml3.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := resolve(n.Content[i]), resolve(n.Content[i+1])
+		if k.Value == "" {
+			s.add(k, "contact name should not be empty")
+		}
+		if v.Kind != yaml3.MappingNode {
+			continue
+		}
+
+		address := mappingValue(v, "address")
+		if address == nil || address.Value == "" {
+			s.add(v, "contact address is missing")
+		} else if err := checkContactAddress(address.Value); err != nil {
+			s.add(address, "invalid contact address: %v", err)
+		}
+	}
+}
+
+// mappingValue returns the value of key in the mapping n, or nil.
+func mappingValue(n *yaml3.Node, key string) *yaml3.Node {
+	if n.Kind != yaml3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if resolve(n.Content[i]).Value == key {
+			return resolve(n.Content[i+1])
+		}
+	}
+
+	return nil
+}
+
+func resolve(n *yaml3.Node) *yaml3.Node {
+	for n.Kind == yaml3.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+
+	return n
+}
+
+// yamlFields returns the types of the fields of t by their yaml name.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+
+	return fields
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a positive integer"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSchema(t *testing.T) {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	other, err := keypair.Random()
+	require.NoError(t, err)
+
+	doc := `version: 1
+unknown: true
+stellar:
+  wallets:
+  - name: main
+    address: ` + kp.Address() + `
+  - name: main
+    address: ` + kp.Address() + `
+  - address: ` + other.Seed() + `
+    watch_only: maybe
+  contacts:
+    bob:
+      address: GBOB
+      memo:
+        type: text
+        value: this text is longer than twenty-eight bytes
+    carol:
+      address: ` + other.Address() + `
+      memo: {type: hash, value: "not base64"}
+    dave:
+      address: ` + other.Address() + `
+      memo: {type: id, value: twelve}
+    erin:
+      address: ` + other.Address() + `
+      memo: {type: url, value: x}
+`
+
+	err = validateSchema([]byte(doc))
+	require.IsType(t, &SchemaError{}, err)
+
+	var got []string
+	for _, p := range err.(*SchemaError).Problems {
+		got = append(got, p.String())
+	}
+	require.Equal(t, []string{
+		"line 2, column 1: unknown field 'unknown'",
+		"line 7, column 11: wallet name 'main' is already used at line 5",
+		"line 8, column 14: wallet " + TrimAddress(kp.Address()) + " is already listed at line 6",
+		"line 9, column 5: wallet name should not be empty",
+		"line 9, column 14: wallet address should be an address, not a seed",
+		"line 10, column 17: expected true or false, not 'maybe'",
+		"line 13, column 16: invalid contact address: base32 decode failed: illegal base32 data at input byte 0",
+		"line 16, column 16: text memo is 43 bytes long, it should not exceed 28 bytes",
+		"line 19, column 33: hash memo should be 32 bytes encoded in base64, not 'not base64'",
+		"line 22, column 31: id memo should be a positive integer, not 'twelve'",
+		"line 25, column 20: unknown memo type 'url', should be text, id, hash or return",
+	}, got)
+
+	// the file is not loaded
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(doc), 0600))
+	_, err = Open(path, nil, nil)
+	require.IsType(t, &SchemaError{}, err)
+
+	// files written by alfred are valid
+	require.NoError(t, os.Remove(path))
+	m, err := Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("main", kp)))
+	memo, err := MemoFromString(MEMO_ID, "12")
+	require.NoError(t, err)
+	require.NoError(t, m.AddContact("bob", other.Address(), memo))
+	require.NoError(t, Write(path, m))
+	m.Seal(true)
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, validateSchema(b))
+	_, err = Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+}
+
+func TestValidateSchemaVersion(t *testing.T) {
+	// a newer format is reported as such, not as unknown fields
+	err := validateSchema([]byte("version: 99\nunknown: true\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "please upgrade alfred")
+}
+
+func TestValidateSealed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	m.Stellar.Contacts = map[string]Contact{"eve": {Address: "GEVE"}}
+	m.Seal(true)
+	require.NoError(t, Write(path, m))
+
+	// checked once decrypted
+	_, err = Open(path, secret, nil)
+	require.IsType(t, &SchemaError{}, err)
+	e := err.(*SchemaError)
+	require.True(t, e.Sealed)
+	require.Len(t, e.Problems, 1)
+	require.Contains(t, e.Problems[0].Message, "invalid contact address")
+	require.Contains(t, e.Error(), "invalid sealed content")
+
+	_, err = Open(path, nil, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,125 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// publicyaml lists what can be read from a sealed file without the password.
+// It is not authenticated until the file is unlocked.
+type publicyaml struct {
+	Wallets []string `yaml:"wallets,omitempty"`
+}
+
+// Seal sets whether the whole file is encrypted and authenticated on the next
+// write, instead of only the seeds. Only the addresses of the wallets are left
+// readable without the password, in a separate public section.
+func (a *Alfred) Seal(sealed bool) { a.sealed = sealed }
+
+func (a *Alfred) IsSealed() bool { return a.sealed }
+
+// sealedCheck is the additional data of the check of a sealed file, so that
+// a sealed file cannot be passed off as an unsealed one.
+var sealedCheck = []byte("sealed")
+
+func (a *Alfred) checkAD() []byte {
+	if a.sealed {
+		return sealedCheck
+	}
+	return nil
+}
+
+// additionalData binds the sealed content to the header of the file.
+func (j *alfredyaml) additionalData() ([]byte, error) {
+	return yaml.Marshal(struct {
+		Version int  `yaml:"version"`
+		KDF     *KDF `yaml:"kdf"`
+	}{j.Version, j.KDF})
+}
+
+func (j *alfredyaml) seal(key dbKey) error {
+	body, err := yaml.Marshal(j.Stellar)
+	if err != nil {
+		return err
+	}
+
+	ad, err := j.additionalData()
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := key.encrypt(body, ad)
+	if err != nil {
+		return err
+	}
+
+	j.Public = &publicyaml{}
+	for _, w := range j.Stellar.Wallets {
+		j.Public.Wallets = append(j.Public.Wallets, w.Address)
+	}
+
+	j.Stellar = stellaryaml{}
+	j.Sealed = base64.RawStdEncoding.EncodeToString(encrypted)
+	return nil
+}
+
+// unseal replaces the stellar section by the sealed one. Without key,
+// only the wallet addresses of the public section are available.
+func (j *alfredyaml) unseal(key dbKey) error {
+	if len(j.Stellar.Wallets) > 0 || len(j.Stellar.Contacts) > 0 || len(j.Stellar.Roots) > 0 {
+		return errors.New("sealed file should not have a plaintext stellar section, file may have been tampered with")
+	}
+
+	var public []string
+	if j.Public != nil {
+		public = j.Public.Wallets
+	}
+
+	if key == nil {
+		for _, addr := range public {
+			j.Stellar.Wallets = append(j.Stellar.Wallets, walletyaml{
+				Name:    addr,
+				Address: addr,
+			})
+		}
+		return nil
+	}
+
+	decoded, err := base64.RawStdEncoding.DecodeString(j.Sealed)
+	if err != nil {
+		return err
+	}
+
+	ad, err := j.additionalData()
+	if err != nil {
+		return err
+	}
+
+	body, err := key.decrypt(decoded, ad)
+	if err != nil {
+		return fmt.Errorf("sealed content could not be authenticated, %s or file was tampered with", j.KDF.passwordHint())
+	}
+
+	// reported once the content is known to match the public section
+	invalid := validateSealed(body)
+	if err := yaml.Unmarshal(body, &j.Stellar); err != nil {
+		if invalid != nil {
+			return invalid
+		}
+		return err
+	}
+
+	if len(public) != len(j.Stellar.Wallets) {
+		return errors.New("public section does not match the sealed content, file may have been tampered with")
+	}
+	for i, w := range j.Stellar.Wallets {
+		if public[i] != w.Address {
+			return errors.New("public section does not match the sealed content, file may have been tampered with")
+		}
+	}
+
+	return invalid
+}
@@ -0,0 +1,94 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestSeal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("savings", kp)))
+
+	contact, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddContact("jennifer", contact.Address(), nil))
+
+	m.Seal(true)
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "savings")
+	require.NotContains(t, string(b), "jennifer")
+	require.NotContains(t, string(b), contact.Address())
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.True(t, m.IsSealed())
+	require.Equal(t, "savings", m.Stellar.Wallets[0].Name)
+	require.Equal(t, contact.Address(), m.Stellar.Contacts["jennifer"].Address)
+
+	// public section only
+	m, err = Open(path, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, m.Stellar.Wallets, 1)
+	require.Equal(t, kp.Address(), m.Stellar.Wallets[0].Keypair.Address())
+	require.Empty(t, m.Stellar.Contacts)
+
+	other, err := keypair.Random()
+	require.NoError(t, err)
+
+	tamper := func(fn func(j *alfredyaml)) {
+		var j alfredyaml
+		require.NoError(t, yaml.Unmarshal(b, &j))
+		fn(&j)
+		tampered, err := yaml.Marshal(j)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, tampered, 0600))
+
+		_, err = Open(path, secret, nil)
+		require.Error(t, err)
+	}
+
+	tamper(func(j *alfredyaml) { j.Public.Wallets[0] = other.Address() })
+	tamper(func(j *alfredyaml) { j.Sealed = strings.ToUpper(j.Sealed) })
+	tamper(func(j *alfredyaml) { j.Version-- })
+	tamper(func(j *alfredyaml) {
+		j.Stellar.Contacts = map[string]Contact{"jennifer": {Address: other.Address()}}
+	})
+	// passed off as unsealed, the check still records it was sealed
+	tamper(func(j *alfredyaml) {
+		j.Sealed = ""
+		j.Public = nil
+		j.Stellar.Wallets = []walletyaml{{Name: "savings", Address: kp.Address()}}
+		j.Stellar.Contacts = map[string]Contact{"jennifer": {Address: other.Address()}}
+	})
+
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	m.Seal(false)
+	require.NoError(t, Write(path, m))
+
+	b, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), "jennifer")
+}
@@ -0,0 +1,248 @@
+package wallet
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/stellar/go/keypair"
+)
+
+// ErrWalletPassword is returned when the seed of a wallet having a password
+// of its own is needed before the wallet is unlocked.
+var ErrWalletPassword = errors.New("wallet has a password of its own, it should be unlocked first")
+
+// lockedSeed is the seed of a wallet as stored in the db, encrypted with the
+// key of the db. It is only decrypted when the seed is needed, and written
+// back as it is otherwise, so that a corrupted seed only disables its wallet.
+type lockedSeed struct {
+	// encoded is the seed encrypted with key, as found in the db.
+	encoded string
+	key     dbKey
+	// err is set once the seed could not be decrypted.
+	err error
+}
+
+// walletPassword protects the seed of a wallet with a password of its own,
+// inside the encryption of the db.
+type walletPassword struct {
+	kdf KDF
+	// key is nil until the wallet is unlocked.
+	key []byte
+}
+
+// HasPassword reports whether w has a password of its own.
+func (w *Wallet) HasPassword() bool { return w.password != nil }
+
+// SeedError returns why the seed of w is not available, if known: the
+// wallet has a password of its own and is not unlocked, or its seed is
+// corrupted. Nil is returned for seeds which were not decrypted yet.
+func (w *Wallet) SeedError() error {
+	switch {
+	case !w.hasSeed():
+		return nil
+	case w.seed != nil && w.seed.err != nil:
+		return w.seed.err
+	case w.password != nil && w.password.key == nil:
+		return ErrWalletPassword
+	}
+
+	return nil
+}
+
+// lockedError returns why Full failed for w.
+func (w *Wallet) lockedError() error {
+	if err := w.SeedError(); err != nil {
+		return fmt.Errorf("wallet %s: %v", w, err)
+	}
+
+	return fmt.Errorf("wallet %s is locked", w)
+}
+
+// Unlock decrypts the seed of a wallet having a password of its own.
+func (w *Wallet) Unlock(password []byte) error {
+	if w.password == nil {
+		return fmt.Errorf("wallet %s has no password of its own", w)
+	}
+
+	if _, ok := w.Keypair.(*keypair.Full); ok {
+		return nil
+	}
+
+	key, err := w.password.kdf.DeriveKey(password)
+	if err != nil {
+		return err
+	}
+
+	if err := w.openSeed(key); err != nil {
+		return err
+	}
+
+	w.password.key = key
+	return nil
+}
+
+// SetPassword protects the seed of w with a password of its own, needed
+// along with the password of the db to sign. An empty password removes it.
+func (w *Wallet) SetPassword(password []byte) error {
+	switch {
+	case !w.hasSeed():
+		return fmt.Errorf("seed of %s is not stored in the db", w)
+	case w.vault != nil:
+		return errors.New("wallets of a team vault cannot have a password of their own")
+	}
+
+	if _, ok := w.Full(); !ok {
+		return w.lockedError()
+	}
+
+	if len(password) == 0 {
+		w.password = nil
+		return nil
+	}
+
+	kdf, err := NewKDF()
+	if err != nil {
+		return err
+	}
+
+	key, err := kdf.DeriveKey(password)
+	if err != nil {
+		return err
+	}
+
+	w.password = &walletPassword{kdf: kdf, key: key}
+	return nil
+}
+
+// openSeed decrypts the locked seed of w, with key for wallets having a
+// password of their own.
+func (w *Wallet) openSeed(key []byte) error {
+	if w.seed == nil {
+		return fmt.Errorf("wallet %s is locked", w)
+	}
+	if w.seed.err != nil {
+		return w.seed.err
+	}
+
+	inner, err := w.seed.open()
+	if err != nil {
+		return err
+	}
+	defer wipe(inner)
+
+	seed := inner
+	if w.password != nil {
+		if seed, err = decrypt(key, inner); err != nil {
+			return fmt.Errorf("wallet %s could not be unlocked, password may be incorrect", w)
+		}
+		defer wipe(seed)
+	}
+
+	var seed32 [32]byte
+	copy(seed32[:], seed)
+	kp, err := keypair.FromRawSeed(seed32)
+	if err != nil || kp.Address() != w.Keypair.Address() {
+		w.seed.err = fmt.Errorf("seed of wallet %s does not match its address, it may be corrupted", w.Name)
+		return w.seed.err
+	}
+
+	w.Keypair = kp
+	w.seed = nil
+	return nil
+}
+
+// open returns the seed, still encrypted with the password of the wallet if
+// it has one.
+func (s *lockedSeed) open() ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(s.encoded)
+	if err != nil {
+		s.err = errors.New("seed could not be decoded, it may be corrupted")
+		return nil, s.err
+	}
+
+	inner, err := s.key.decrypt(sealed, nil)
+	if err != nil {
+		s.err = errors.New("seed could not be decrypted, it may be corrupted")
+		return nil, s.err
+	}
+
+	return inner, nil
+}
+
+// innerSeed returns the seed of w, encrypted with the password of the
+// wallet if it has one.
+func (w *Wallet) innerSeed() ([]byte, error) {
+	if kp, ok := w.Keypair.(*keypair.Full); ok {
+		seed := getSeed(kp.Seed())
+		if w.password == nil {
+			return seed, nil
+		}
+		defer wipe(seed)
+
+		return encrypt(w.password.key, seed)
+	}
+
+	if w.seed == nil {
+		return nil, errors.New("you should unlock alfred for writing")
+	}
+
+	inner, err := w.seed.open()
+	if err != nil {
+		return nil, fmt.Errorf("wallet %s: %v", w.Name, err)
+	}
+
+	return inner, nil
+}
+
+// sealSeed returns the seed of w encrypted with key and encoded. Seeds
+// which were not decrypted are kept as they are, unless the key changed.
+func (w *Wallet) sealSeed(key dbKey) (string, error) {
+	if _, ok := w.Keypair.(*keypair.Full); !ok && w.seed != nil && sameKey(w.seed.key, key) {
+		return w.seed.encoded, nil
+	}
+
+	inner, err := w.innerSeed()
+	if err != nil {
+		return "", err
+	}
+	defer wipe(inner)
+
+	sealed, err := key.encrypt(inner, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (w *Wallet) passwordKDF() *KDF {
+	if w.password == nil {
+		return nil
+	}
+
+	kdf := w.password.kdf
+	return &kdf
+}
+
+// checkSeeds returns an error if the seed of a wallet could not be written
+// with another key.
+func (a *Alfred) checkSeeds() error {
+	for _, w := range a.Stellar.Wallets {
+		if !w.hasSeed() || w.vault != nil {
+			continue
+		}
+
+		inner, err := w.innerSeed()
+		if err != nil {
+			return err
+		}
+		wipe(inner)
+	}
+
+	return nil
+}
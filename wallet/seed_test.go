@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestWalletPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	open := func(secret string) *Alfred {
+		m, err := Open(path, []byte(secret), nil)
+		require.NoError(t, err)
+		return m
+	}
+
+	m := open("secret")
+	cold, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("cold", cold)))
+	hot, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("hot", hot)))
+	require.NoError(t, m.WalletByName("cold").SetPassword([]byte("cold password")))
+	require.NoError(t, Write(path, m))
+
+	m = open("secret")
+	w := m.WalletByName("cold")
+	require.True(t, w.HasPassword())
+	_, ok := w.Full()
+	require.False(t, ok)
+	require.Equal(t, ErrWalletPassword, w.SeedError())
+	_, err = w.Signer(nil)
+	require.Error(t, err)
+	require.Error(t, w.Unlock([]byte("secret")))
+	require.NoError(t, w.Unlock([]byte("cold password")))
+	kp, ok := w.Full()
+	require.True(t, ok)
+	require.Equal(t, cold.Seed(), kp.Seed())
+	require.Equal(t, hot.Seed(), seedOf(t, m.WalletByName("hot")))
+
+	// the password of the db changes without the one of the wallet
+	m = open("secret")
+	require.NoError(t, m.ChangePassword([]byte("new secret")))
+	require.NoError(t, Write(path, m))
+	m = open("new secret")
+	require.NoError(t, m.WalletByName("cold").Unlock([]byte("cold password")))
+
+	_, err = Open(path, []byte("secret"), nil)
+	require.Error(t, err)
+}
+
+func TestCorruptedSeed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	for _, name := range []string{"first", "second"} {
+		kp, err := keypair.Random()
+		require.NoError(t, err)
+		require.NoError(t, m.AddWallet(New(name, kp)))
+	}
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var j alfredyaml
+	require.NoError(t, yaml.Unmarshal(b, &j))
+	seed := []byte(j.Stellar.Wallets[0].Seed)
+	seed[10] ^= 1
+	j.Stellar.Wallets[0].Seed = string(seed)
+	b, err = yaml.Marshal(j)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+
+	// only the corrupted wallet is disabled
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	_, ok := m.WalletByName("first").Full()
+	require.False(t, ok)
+	require.Error(t, m.WalletByName("first").SeedError())
+	_, ok = m.WalletByName("second").Full()
+	require.True(t, ok)
+
+	// and kept as it is
+	require.NoError(t, m.RenameWallet(m.WalletByName("second").Keypair.Address(), "other"))
+	require.NoError(t, Write(path, m))
+	b, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), string(seed))
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Error(t, m.ChangePassword([]byte("new secret")))
+
+	_, err = Open(path, []byte("wrong"), nil)
+	require.Error(t, err)
+}
+
+func TestCorruptedRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	secret := []byte("secret")
+
+	m, err := Open(path, secret, nil)
+	require.NoError(t, err)
+	var ids []string
+	for i := 0; i < 2; i++ {
+		mnemonic, err := NewMnemonic(12)
+		require.NoError(t, err)
+		seed, err := SeedFromMnemonic(mnemonic, "")
+		require.NoError(t, err)
+		root, err := NewRoot(seed)
+		require.NoError(t, err)
+		ids = append(ids, m.AddRoot(root).ID)
+	}
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var j alfredyaml
+	require.NoError(t, yaml.Unmarshal(b, &j))
+	seed := []byte(j.Stellar.Roots[0].Seed)
+	seed[10] ^= 1
+	j.Stellar.Roots[0].Seed = string(seed)
+	b, err = yaml.Marshal(j)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+
+	// only the corrupted root is skipped
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"root " + ids[0]}, m.Skipped())
+	require.Nil(t, m.RootByID(ids[0]))
+	require.NotNil(t, m.RootByID(ids[1]).Seed)
+
+	// and kept as it is
+	require.NoError(t, Write(path, m))
+	b, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(b), string(seed))
+
+	m, err = Open(path, secret, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"root " + ids[0]}, m.Skipped())
+
+	_, err = Open(path, []byte("wrong"), nil)
+	require.Error(t, err)
+}
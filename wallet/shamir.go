@@ -0,0 +1,251 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/stellar/go/crc16"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+)
+
+const (
+	shareVersion = 1
+	sharePrefix  = "ALFRED"
+	// version, threshold, index, public key and share of the seed
+	shareSize = 1 + 1 + 1 + 32 + 32
+)
+
+var shareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Share is one of the parts a seed is split into using Shamir's secret
+// sharing. Threshold shares are needed to rebuild the seed.
+type Share struct {
+	Address   string
+	Threshold int
+	Index     int
+	Data      []byte
+}
+
+// SplitSeed splits the seed of kp into n shares, any threshold of them
+// being enough to rebuild it.
+func SplitSeed(kp *keypair.Full, n, threshold int) ([]Share, error) {
+	switch {
+	case threshold < 2:
+		return nil, errors.New("threshold should be at least 2")
+	case n < threshold:
+		return nil, fmt.Errorf("number of shares should be at least the threshold (%d)", threshold)
+	case n > 255:
+		return nil, errors.New("number of shares should be at most 255")
+	}
+
+	parts, err := shamirSplit(getSeed(kp.Seed()), n, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make([]Share, n)
+	for i, part := range parts {
+		shares[i] = Share{
+			Address:   kp.Address(),
+			Threshold: threshold,
+			Index:     i + 1,
+			Data:      part,
+		}
+	}
+
+	return shares, nil
+}
+
+// CombineShares rebuilds the keypair from at least threshold shares. Shares
+// should all belong to the same wallet and the address of the rebuilt keypair
+// is checked against theirs.
+func CombineShares(shares []Share) (*keypair.Full, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+
+	first := shares[0]
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%d shares are needed, got %d", first.Threshold, len(shares))
+	}
+
+	xs := make([]byte, len(shares))
+	ys := make([][]byte, len(shares))
+	seen := map[int]bool{}
+	for i, s := range shares {
+		switch {
+		case s.Address != first.Address:
+			return nil, fmt.Errorf("share #%d belongs to %s and not to %s", s.Index, s.Address, first.Address)
+		case s.Threshold != first.Threshold:
+			return nil, fmt.Errorf("share #%d has a threshold of %d instead of %d", s.Index, s.Threshold, first.Threshold)
+		case seen[s.Index]:
+			return nil, fmt.Errorf("share #%d given twice", s.Index)
+		}
+
+		seen[s.Index] = true
+		xs[i] = byte(s.Index)
+		ys[i] = s.Data
+	}
+
+	seed := shamirCombine(xs, ys)
+
+	var raw [32]byte
+	copy(raw[:], seed)
+	kp, err := keypair.FromRawSeed(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if kp.Address() != first.Address {
+		return nil, errors.New("rebuilt seed does not match the address of the shares, one of them is wrong")
+	}
+
+	return kp, nil
+}
+
+// String encodes the share in a printable form, including a checksum.
+func (s Share) String() string {
+	b := make([]byte, 0, shareSize+2)
+	b = append(b, shareVersion, byte(s.Threshold), byte(s.Index))
+	b = append(b, strkey.MustDecode(strkey.VersionByteAccountID, s.Address)...)
+	b = append(b, s.Data...)
+	b = append(b, crc16.Checksum(b)...)
+
+	encoded := shareEncoding.EncodeToString(b)
+	groups := []string{sharePrefix}
+	for len(encoded) > 0 {
+		n := 8
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		groups = append(groups, encoded[:n])
+		encoded = encoded[n:]
+	}
+
+	return strings.Join(groups, "-")
+}
+
+// ParseShare decodes a share encoded by Share.String, whitespaces and dashes
+// being ignored.
+func ParseShare(str string) (Share, error) {
+	str = strings.ToUpper(strings.Join(strings.Fields(str), ""))
+	str = strings.Replace(str, "-", "", -1)
+	if !strings.HasPrefix(str, sharePrefix) {
+		return Share{}, errors.New("not an alfred share")
+	}
+
+	b, err := shareEncoding.DecodeString(strings.TrimPrefix(str, sharePrefix))
+	if err != nil {
+		return Share{}, fmt.Errorf("invalid share: %v", err)
+	}
+
+	if len(b) != shareSize+2 {
+		return Share{}, errors.New("invalid share length")
+	}
+
+	payload, checksum := b[:shareSize], b[shareSize:]
+	if err := crc16.Validate(payload, checksum); err != nil {
+		return Share{}, errors.New("invalid share checksum, it may have been mistyped")
+	}
+
+	if payload[0] != shareVersion {
+		return Share{}, fmt.Errorf("unsupported share version %d", payload[0])
+	}
+
+	address, err := strkey.Encode(strkey.VersionByteAccountID, payload[3:35])
+	if err != nil {
+		return Share{}, err
+	}
+
+	return Share{
+		Address:   address,
+		Threshold: int(payload[1]),
+		Index:     int(payload[2]),
+		Data:      payload[35:],
+	}, nil
+}
+
+// shamirSplit splits secret into n parts, evaluating at x = 1..n a random
+// polynomial of degree threshold-1 per byte, in GF(2^8).
+func shamirSplit(secret []byte, n, threshold int) ([][]byte, error) {
+	parts := make([][]byte, n)
+	for i := range parts {
+		parts[i] = make([]byte, len(secret))
+	}
+
+	coeffs := make([]byte, threshold)
+	for j, b := range secret {
+		if _, err := io.ReadFull(rand.Reader, coeffs[1:]); err != nil {
+			return nil, err
+		}
+		coeffs[0] = b
+
+		for i := range parts {
+			parts[i][j] = gfEval(coeffs, byte(i+1))
+		}
+	}
+
+	return parts, nil
+}
+
+// shamirCombine interpolates the parts at x = 0.
+func shamirCombine(xs []byte, ys [][]byte) []byte {
+	secret := make([]byte, len(ys[0]))
+	for j := range secret {
+		var value byte
+		for i := range xs {
+			// lagrange basis polynomial evaluated at 0
+			basis := byte(1)
+			for k := range xs {
+				if k == i {
+					continue
+				}
+				basis = gfMul(basis, gfDiv(xs[k], xs[k]^xs[i]))
+			}
+			value ^= gfMul(ys[i][j], basis)
+		}
+		secret[j] = value
+	}
+
+	return secret
+}
+
+// gfEval evaluates the polynomial with coeffs at x using Horner's method.
+func gfEval(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// gfMul multiplies in GF(2^8) modulo the AES polynomial x^8 + x^4 + x^3 + x + 1.
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 {
+			p ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// gfDiv divides a by b, b being non zero, using b^254 = b^-1.
+func gfDiv(a, b byte) byte {
+	inv := b
+	for i := 0; i < 6; i++ {
+		inv = gfMul(gfMul(inv, inv), b)
+	}
+	return gfMul(a, gfMul(inv, inv))
+}
@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShamir(t *testing.T) {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+
+	shares, err := SplitSeed(kp, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	var parsed []Share
+	for _, s := range shares {
+		got, err := ParseShare(strings.ToLower(s.String()))
+		require.NoError(t, err)
+		require.Equal(t, s, got)
+		parsed = append(parsed, got)
+	}
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var selected []Share
+		for _, i := range subset {
+			selected = append(selected, parsed[i])
+		}
+
+		got, err := CombineShares(selected)
+		require.NoError(t, err)
+		require.Equal(t, kp.Seed(), got.Seed())
+	}
+
+	_, err = CombineShares(parsed[:2])
+	require.Error(t, err)
+
+	_, err = CombineShares([]Share{parsed[0], parsed[0], parsed[1]})
+	require.Error(t, err)
+
+	// wrong data is caught by the address check
+	wrong := parsed[2]
+	wrong.Data = append([]byte{}, wrong.Data...)
+	wrong.Data[0] ^= 1
+	_, err = CombineShares([]Share{parsed[0], parsed[1], wrong})
+	require.Error(t, err)
+
+	// mistyped share is caught by the checksum
+	str := []byte(shares[0].String())
+	if str[10] == 'A' {
+		str[10] = 'B'
+	} else {
+		str[10] = 'A'
+	}
+	_, err = ParseShare(string(str))
+	require.Error(t, err)
+
+	other, err := keypair.Random()
+	require.NoError(t, err)
+	otherShares, err := SplitSeed(other, 3, 3)
+	require.NoError(t, err)
+	_, err = CombineShares([]Share{parsed[0], parsed[1], otherShares[2]})
+	require.Error(t, err)
+
+	_, err = SplitSeed(kp, 2, 3)
+	require.Error(t, err)
+	_, err = SplitSeed(kp, 3, 1)
+	require.Error(t, err)
+}
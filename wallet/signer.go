@@ -0,0 +1,425 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+// Signer signs transactions on behalf of a wallet.
+type Signer interface {
+	// Address returns the address of the account signatures are made for.
+	Address() string
+	// SignTransaction signs tx for the network identified by passphrase.
+	SignTransaction(passphrase string, tx *xdr.Transaction) (xdr.DecoratedSignature, error)
+}
+
+// Signer returns the signer of w, remote signers being reached with the
+// tokens and roots of opts. Watch-only wallets cannot sign.
+func (w *Wallet) Signer(opts *Options) (Signer, error) {
+	switch {
+	case w.RemoteSigner != "":
+		return NewRemoteSigner(w.RemoteSigner, w.Keypair.Address(), opts)
+	case w.WatchOnly:
+		return nil, fmt.Errorf("wallet %s is watch-only", w)
+	}
+
+	kp, ok := w.Full()
+	if !ok {
+		return nil, w.lockedError()
+	}
+
+	return LocalSigner{kp}, nil
+}
+
+// LocalSigner signs with a seed held in memory.
+type LocalSigner struct {
+	Keypair *keypair.Full
+}
+
+func (s LocalSigner) Address() string {
+	return s.Keypair.Address()
+}
+
+func (s LocalSigner) SignTransaction(passphrase string, tx *xdr.Transaction) (xdr.DecoratedSignature, error) {
+	hash, err := network.HashTransaction(tx, passphrase)
+	if err != nil {
+		return xdr.DecoratedSignature{}, err
+	}
+
+	return s.Keypair.SignDecorated(hash[:])
+}
+
+// RemoteSigner asks a signing process to sign, the seed never leaves it.
+// The signer is reached over HTTPS or, for unix:// urls, over a Unix socket.
+type RemoteSigner struct {
+	URL     string
+	address string
+	token   string
+	client  *http.Client
+	base    string
+}
+
+// NewRemoteSigner returns a signer for address served at rawurl, sending it
+// its token in opts.
+func NewRemoteSigner(rawurl, address string, opts *Options) (*RemoteSigner, error) {
+	if err := CheckSignerURL(rawurl); err != nil {
+		return nil, err
+	}
+
+	opts = opts.orDefault()
+	client, base := signerClient(rawurl, opts.SignerRoots)
+	return &RemoteSigner{
+		URL:     rawurl,
+		address: address,
+		token:   signerToken(rawurl, opts.SignerTokens),
+		client:  client,
+		base:    base,
+	}, nil
+}
+
+// CheckSignerURL returns an error unless rawurl is an https:// url or a
+// unix:// socket, tokens and transactions are never sent in the clear.
+func CheckSignerURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return fmt.Errorf("invalid signer url %s: %v", rawurl, err)
+	}
+
+	switch {
+	case u.Scheme == "https" && u.Host != "":
+		return nil
+	case u.Scheme == "unix" && strings.TrimPrefix(rawurl, "unix://") != "":
+		return nil
+	}
+
+	return fmt.Errorf("signer url %s should be https://host:port or unix:///path/to/socket", rawurl)
+}
+
+// signerToken returns the token of tokens to send to the signer at rawurl.
+func signerToken(rawurl string, tokens map[string]string) string {
+	for u, token := range tokens {
+		if strings.TrimSuffix(u, "/") == strings.TrimSuffix(rawurl, "/") {
+			return token
+		}
+	}
+
+	return ""
+}
+
+func (s *RemoteSigner) Address() string {
+	return s.address
+}
+
+func (s *RemoteSigner) SignTransaction(passphrase string, tx *xdr.Transaction) (xdr.DecoratedSignature, error) {
+	var sig xdr.DecoratedSignature
+
+	txB64, err := xdr.MarshalBase64(tx)
+	if err != nil {
+		return sig, err
+	}
+
+	var resp signResponse
+	err = signerCall(s.client, s.token, http.MethodPost, s.base+"/sign", signRequest{
+		Address:     s.address,
+		Passphrase:  passphrase,
+		Transaction: txB64,
+	}, &resp)
+	if err != nil {
+		return sig, err
+	}
+
+	if err := xdr.SafeUnmarshalBase64(resp.Signature, &sig); err != nil {
+		return sig, fmt.Errorf("invalid signature from remote signer: %v", err)
+	}
+
+	// do not trust the signer blindly, a wrong signature would only be
+	// noticed when horizon rejects the transaction
+	hash, err := network.HashTransaction(tx, passphrase)
+	if err != nil {
+		return sig, err
+	}
+
+	kp, err := keypair.Parse(s.address)
+	if err != nil {
+		return sig, err
+	}
+
+	if err := kp.Verify(hash[:], sig.Signature); err != nil {
+		return sig, fmt.Errorf("remote signer returned an invalid signature for %s", s.address)
+	}
+
+	return sig, nil
+}
+
+// RemoteWallet is a wallet served by a remote signer.
+type RemoteWallet struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// ListRemoteWallets returns the wallets a remote signer can sign for, sending
+// it its token in opts.
+func ListRemoteWallets(rawurl string, opts *Options) ([]RemoteWallet, error) {
+	if err := CheckSignerURL(rawurl); err != nil {
+		return nil, err
+	}
+
+	opts = opts.orDefault()
+	client, base := signerClient(rawurl, opts.SignerRoots)
+
+	var resp walletsResponse
+	if err := signerCall(client, signerToken(rawurl, opts.SignerTokens), http.MethodGet, base+"/wallets", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Wallets, nil
+}
+
+type signRequest struct {
+	Address     string `json:"address"`
+	Passphrase  string `json:"network_passphrase"`
+	Transaction string `json:"transaction"`
+}
+
+type signResponse struct {
+	Signature string `json:"signature"`
+}
+
+type walletsResponse struct {
+	Wallets []RemoteWallet `json:"wallets"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// signerClient returns the http client and the base url to reach the signer
+// at rawurl, unix:///path/to/socket urls being dialed as Unix sockets. The
+// certificates of https signers are verified with roots, if set.
+func signerClient(rawurl string, roots *x509.CertPool) (*http.Client, string) {
+	if !strings.HasPrefix(rawurl, "unix://") {
+		return &http.Client{
+			Timeout: time.Minute,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{RootCAs: roots},
+			},
+		}, strings.TrimSuffix(rawurl, "/")
+	}
+
+	socket := strings.TrimPrefix(rawurl, "unix://")
+	return &http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}, "http://unix"
+}
+
+// signerCall sends in to rawurl and decodes the response in out, token being
+// sent as a bearer token if it is set.
+func signerCall(client *http.Client, token, method, rawurl string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, rawurl, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote signer unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			return fmt.Errorf("remote signer: %s", resp.Status)
+		}
+		return fmt.Errorf("remote signer: %s", e.Error)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// SignerServer serves the remote signer protocol for its wallets.
+type SignerServer struct {
+	Wallets []*Wallet
+	// Token, if set, has to be sent by clients as a bearer token.
+	Token string
+	// Authorize, if set, is called before each signature and can refuse it.
+	Authorize func(w *Wallet, passphrase string, tx *xdr.Transaction) error
+}
+
+func (s *SignerServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if s.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			writeSignerError(rw, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/wallets":
+		var resp walletsResponse
+		for _, w := range s.Wallets {
+			resp.Wallets = append(resp.Wallets, RemoteWallet{
+				Name:    w.Name,
+				Address: w.Keypair.Address(),
+			})
+		}
+		json.NewEncoder(rw).Encode(resp)
+	case r.Method == http.MethodPost && r.URL.Path == "/sign":
+		sig, err := s.sign(r)
+		if err != nil {
+			writeSignerError(rw, http.StatusBadRequest, err)
+			return
+		}
+
+		sigB64, err := xdr.MarshalBase64(sig)
+		if err != nil {
+			writeSignerError(rw, http.StatusInternalServerError, err)
+			return
+		}
+		json.NewEncoder(rw).Encode(signResponse{Signature: sigB64})
+	default:
+		writeSignerError(rw, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *SignerServer) sign(r *http.Request) (xdr.DecoratedSignature, error) {
+	var req signRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return xdr.DecoratedSignature{}, err
+	}
+
+	var w *Wallet
+	for _, candidate := range s.Wallets {
+		if candidate.Keypair.Address() == req.Address {
+			w = candidate
+		}
+	}
+	if w == nil {
+		return xdr.DecoratedSignature{}, fmt.Errorf("no wallet for %s", req.Address)
+	}
+
+	var tx xdr.Transaction
+	if err := xdr.SafeUnmarshalBase64(req.Transaction, &tx); err != nil {
+		return xdr.DecoratedSignature{}, fmt.Errorf("invalid transaction: %v", err)
+	}
+
+	if s.Authorize != nil {
+		if err := s.Authorize(w, req.Passphrase, &tx); err != nil {
+			return xdr.DecoratedSignature{}, err
+		}
+	}
+
+	signer, err := w.Signer(nil)
+	if err != nil {
+		return xdr.DecoratedSignature{}, err
+	}
+
+	return signer.SignTransaction(req.Passphrase, &tx)
+}
+
+func writeSignerError(rw http.ResponseWriter, code int, err error) {
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(errorResponse{Error: err.Error()})
+}
+
+// ListenSigner listens on addr, either unix:///path/to/socket or host:port.
+// Unix sockets are only accessible to the current user. Tokens and
+// transactions should not travel in the clear, host:port is served over TLS
+// with config, and refused if it is nil.
+func ListenSigner(addr string, config *tls.Config) (net.Listener, error) {
+	if !strings.HasPrefix(addr, "unix://") {
+		if config == nil {
+			return nil, fmt.Errorf("%s is not a unix socket, a TLS certificate is required to listen on the network", addr)
+		}
+		if u, err := url.Parse(addr); err == nil && u.Host != "" {
+			addr = u.Host
+		}
+		return tls.Listen("tcp", addr, config)
+	}
+
+	socket := strings.TrimPrefix(addr, "unix://")
+	if fi, err := os.Stat(socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		// stale socket of a previous process
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use", socket)
+		}
+		os.Remove(socket)
+	}
+
+	// the socket is created in a directory only the current user can
+	// enter, and only moved in place once it is private
+	dir, err := ioutil.TempDir(filepath.Dir(socket), ".alfred")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "socket")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, socket); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return &socketListener{Listener: l, path: socket}, nil
+}
+
+// socketListener removes its socket, which was moved after being created,
+// when it is closed.
+type socketListener struct {
+	net.Listener
+	path string
+}
+
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
@@ -0,0 +1,132 @@
+package wallet
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	w := New("treasury", kp)
+
+	tx, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: kp.Address()},
+		build.Sequence{Sequence: 1},
+		build.TestNetwork,
+		build.SetData("key", []byte("value")),
+	)
+	require.NoError(t, err)
+	hash, err := tx.Hash()
+	require.NoError(t, err)
+
+	local, err := w.Signer(nil)
+	require.NoError(t, err)
+	want, err := local.SignTransaction(network.TestNetworkPassphrase, tx.TX)
+	require.NoError(t, err)
+	require.NoError(t, kp.Verify(hash[:], want.Signature))
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	server := &SignerServer{Wallets: []*Wallet{w}}
+	socket := "unix://" + filepath.Join(dir, "signer.sock")
+	l, err := ListenSigner(socket, nil)
+	require.NoError(t, err)
+	go http.Serve(l, server)
+	defer l.Close()
+
+	remotes, err := ListRemoteWallets(socket, nil)
+	require.NoError(t, err)
+	require.Equal(t, []RemoteWallet{{Name: "treasury", Address: kp.Address()}}, remotes)
+
+	remote, err := NewRemote("", kp.Address(), socket)
+	require.NoError(t, err)
+	signer, err := remote.Signer(nil)
+	require.NoError(t, err)
+	got, err := signer.SignTransaction(network.TestNetworkPassphrase, tx.TX)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	other, err := keypair.Random()
+	require.NoError(t, err)
+	signer, err = NewRemoteSigner(socket, other.Address(), nil)
+	require.NoError(t, err)
+	_, err = signer.SignTransaction(network.TestNetworkPassphrase, tx.TX)
+	require.Error(t, err)
+
+	// tokens do not travel in the clear
+	_, err = ListenSigner("127.0.0.1:0", nil)
+	require.Error(t, err)
+
+	server.Token = "token"
+	hs := httptest.NewTLSServer(server)
+	defer hs.Close()
+	opts := &Options{SignerRoots: x509.NewCertPool()}
+	opts.SignerRoots.AddCert(hs.Certificate())
+
+	signer, err = NewRemoteSigner(hs.URL, kp.Address(), opts)
+	require.NoError(t, err)
+	_, err = signer.SignTransaction(network.TestNetworkPassphrase, tx.TX)
+	require.Error(t, err)
+
+	// tokens are only sent to the signer they belong to
+	opts.SignerTokens = map[string]string{"https://signer.example.com": "token"}
+	signer, err = NewRemoteSigner(hs.URL, kp.Address(), opts)
+	require.NoError(t, err)
+	_, err = signer.SignTransaction(network.TestNetworkPassphrase, tx.TX)
+	require.Error(t, err)
+
+	opts.SignerTokens[hs.URL+"/"] = "token"
+	signer, err = NewRemoteSigner(hs.URL, kp.Address(), opts)
+	require.NoError(t, err)
+	got, err = signer.SignTransaction(network.TestNetworkPassphrase, tx.TX)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+
+	// nor in the clear
+	for _, rawurl := range []string{"http://signer.example.com", "https://", "unix://", "signer.example.com:7500"} {
+		_, err = NewRemote("", kp.Address(), rawurl)
+		require.Error(t, err, rawurl)
+		_, err = ListRemoteWallets(rawurl, nil)
+		require.Error(t, err, rawurl)
+	}
+}
+
+func TestRemoteWallet(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	path := f.Name()
+	require.NoError(t, f.Close())
+
+	m, err := Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+
+	kp, err := keypair.Random()
+	require.NoError(t, err)
+	w, err := NewRemote("prod", kp.Address(), "https://signer.example.com")
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(w))
+	require.NoError(t, Write(path, m))
+
+	m, err = Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+
+	w = m.WalletByName("prod")
+	require.False(t, w.WatchOnly)
+	require.Equal(t, "https://signer.example.com", w.RemoteSigner)
+	_, ok := w.Full()
+	require.False(t, ok)
+	require.NoError(t, m.ChangePassword([]byte("new secret")))
+}
@@ -0,0 +1,55 @@
+package wallet
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Store persists a db. The content of the db is encrypted by the Alfred
+// being saved, stores only decide how it is laid out.
+type Store interface {
+	// Load decodes the db into a, decrypting it with the password or the
+	// key a holds. It reports whether the db exists.
+	Load(a *Alfred) (bool, error)
+	// Save writes a to the db. ErrModified is returned if the db changed
+	// since a was loaded.
+	Save(a *Alfred) error
+	// String returns the absolute location of the db, in the form
+	// accepted by NewStore.
+	String() string
+}
+
+const (
+	fileScheme = "file://"
+	dirScheme  = "dir://"
+)
+
+// NewStore returns the store of the db at uri, set up with opts or
+// DefaultOptions if nil:
+//
+//	path/to/alfred.yaml         a single YAML file (FileStore)
+//	file://path/to/alfred.yaml  same as above
+//	dir://path/to/vault         one file per wallet and contact (DirStore)
+func NewStore(uri string, opts *Options) (Store, error) {
+	opts = opts.orDefault()
+	switch {
+	case strings.HasPrefix(uri, fileScheme):
+		return &FileStore{Path: strings.TrimPrefix(uri, fileScheme), Backups: opts.Backups}, nil
+	case strings.HasPrefix(uri, dirScheme):
+		return &DirStore{Path: strings.TrimPrefix(uri, dirScheme)}, nil
+	case strings.Contains(uri, "://"):
+		return nil, fmt.Errorf("unsupported db %s, expected a path, file:// or dir://", uri)
+	}
+
+	return &FileStore{Path: uri, Backups: opts.Backups}, nil
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	return abs
+}
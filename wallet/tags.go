@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tags are free-form labels on wallets and contacts. Contacts sharing a tag
+// form a group that can be paid at once.
+type Tags []string
+
+// Has reports whether tag is one of t, ignoring case.
+func (t Tags) Has(tag string) bool {
+	for _, existing := range t {
+		if strings.EqualFold(existing, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match reports whether t has all the tags of filter.
+func (t Tags) Match(filter []string) bool {
+	for _, tag := range filter {
+		if !t.Has(tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Add adds the tags not already present.
+func (t *Tags) Add(tags ...string) {
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !t.Has(tag) {
+			*t = append(*t, tag)
+		}
+	}
+}
+
+// Remove removes tags, ignoring case.
+func (t *Tags) Remove(tags ...string) {
+	var kept Tags
+	for _, existing := range *t {
+		if !Tags(tags).Has(existing) {
+			kept = append(kept, existing)
+		}
+	}
+	*t = kept
+}
+
+func (t Tags) String() string {
+	return strings.Join(t, ", ")
+}
+
+// TagContact adds tags to the contact name.
+func (m *Alfred) TagContact(name string, tags ...string) error {
+	c, ok := m.Stellar.Contacts[name]
+	if !ok {
+		return fmt.Errorf("contact '%s' not found", name)
+	}
+
+	c.Tags.Add(tags...)
+	m.Stellar.Contacts[name] = c
+	return nil
+}
+
+// UntagContact removes tags from the contact name.
+func (m *Alfred) UntagContact(name string, tags ...string) error {
+	c, ok := m.Stellar.Contacts[name]
+	if !ok {
+		return fmt.Errorf("contact '%s' not found", name)
+	}
+
+	c.Tags.Remove(tags...)
+	m.Stellar.Contacts[name] = c
+	return nil
+}
+
+// SetContactNotes replaces the notes of the contact name.
+func (m *Alfred) SetContactNotes(name, notes string) error {
+	c, ok := m.Stellar.Contacts[name]
+	if !ok {
+		return fmt.Errorf("contact '%s' not found", name)
+	}
+
+	c.Notes = notes
+	m.Stellar.Contacts[name] = c
+	return nil
+}
+
+// ContactGroup returns the names of the contacts tagged with group, sorted.
+func (m *Alfred) ContactGroup(group string) []string {
+	var names []string
+	for name, c := range m.Stellar.Contacts {
+		if c.Tags.Has(group) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
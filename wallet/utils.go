@@ -1,6 +1,7 @@
 package wallet
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -19,6 +20,12 @@ func getSeed(seed string) []byte {
 }
 
 func encrypt(secret, plaintext []byte) ([]byte, error) {
+	return encryptWithAD(secret, plaintext, nil)
+}
+
+// encryptWithAD encrypts plaintext and authenticates it along with ad,
+// which is not encrypted.
+func encryptWithAD(secret, plaintext, ad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(secret)
 	if err != nil {
 		return nil, err
@@ -33,10 +40,14 @@ func encrypt(secret, plaintext []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+	return gcm.Seal(nonce, nonce, plaintext, ad), nil
 }
 
 func decrypt(secret, ciphertext []byte) ([]byte, error) {
+	return decryptWithAD(secret, ciphertext, nil)
+}
+
+func decryptWithAD(secret, ciphertext, ad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(secret)
 	if err != nil {
 		return nil, err
@@ -52,7 +63,44 @@ func decrypt(secret, ciphertext []byte) ([]byte, error) {
 	}
 
 	nonce, ciphertext := ciphertext[:size], ciphertext[size:]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	return gcm.Open(nil, nonce, ciphertext, ad)
+}
+
+// dbKey encrypts and decrypts with the key of a db, which is either held in
+// memory or by an agent that never hands it out.
+type dbKey interface {
+	encrypt(plaintext, ad []byte) ([]byte, error)
+	decrypt(ciphertext, ad []byte) ([]byte, error)
+}
+
+// secretKey is a key held in memory.
+type secretKey []byte
+
+func (k secretKey) encrypt(plaintext, ad []byte) ([]byte, error) {
+	return encryptWithAD(k, plaintext, ad)
+}
+
+func (k secretKey) decrypt(ciphertext, ad []byte) ([]byte, error) {
+	return decryptWithAD(k, ciphertext, ad)
+}
+
+// sameKey reports whether what was encrypted with a is encrypted with b.
+func sameKey(a, b dbKey) bool {
+	switch a := a.(type) {
+	case secretKey:
+		b, ok := b.(secretKey)
+		return ok && bytes.Equal(a, b)
+	case *agentKey:
+		b, ok := b.(*agentKey)
+		return ok && *a == *b
+	}
+	return false
+}
+
+func wipeKey(k dbKey) {
+	if k, ok := k.(secretKey); ok {
+		wipe(k)
+	}
 }
 
 func TrimAddress(addr string) string {
@@ -0,0 +1,759 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/stellar/go/keypair"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Vault shares the wallets of a db between members. Each seed is encrypted
+// with its own key, which is wrapped for every member granted access to the
+// wallet using their X25519 public key. Members unlock the vault either with
+// their private key or with a password, their private key being then stored
+// in the vault encrypted under it.
+//
+// The key of the db, used for anything but the seeds, is wrapped for every
+// member.
+type Vault struct {
+	Members []*Member
+
+	// member is the member the vault was opened as, and private its key.
+	member  *Member
+	private *MemberKey
+}
+
+// Member of a vault.
+type Member struct {
+	Name      string
+	PublicKey *MemberPublicKey
+	// KDF is set for members unlocking the vault with a password.
+	KDF *KDF
+
+	// private is the private key encrypted under the password, if any.
+	private []byte
+	// key is the key of the db wrapped for the member.
+	key []byte
+}
+
+// HasPassword reports whether the member unlocks the vault with a password.
+func (m *Member) HasPassword() bool { return m.KDF != nil }
+
+// walletKey holds the seed of a vault wallet, encrypted with its own key.
+type walletKey struct {
+	// key is nil unless the member the vault was opened as was granted
+	// the wallet.
+	key    []byte
+	seed   []byte
+	access map[string][]byte
+}
+
+type vaultyaml struct {
+	Members []memberyaml `yaml:"members"`
+}
+
+type memberyaml struct {
+	Name       string `yaml:"name"`
+	PublicKey  string `yaml:"public_key"`
+	KDF        *KDF   `yaml:"kdf,omitempty"`
+	PrivateKey string `yaml:"private_key,omitempty"`
+	Key        string `yaml:"key"`
+}
+
+const vaultKeyInfo = "alfred vault"
+
+// vaultKeyAD is the additional data of the key of the db wrapped for members,
+// the keys of the wallets being bound to their address.
+var vaultKeyAD = []byte("alfred")
+
+// MemberKey is the X25519 private key of a vault member.
+type MemberKey [32]byte
+
+// MemberPublicKey is the X25519 public key of a vault member.
+type MemberPublicKey [32]byte
+
+// GenerateMemberKey returns a new private key for a vault member.
+func GenerateMemberKey() (*MemberKey, error) {
+	var k MemberKey
+	if _, err := io.ReadFull(rand.Reader, k[:]); err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// ParseMemberKey decodes a base64 encoded private key.
+func ParseMemberKey(str string) (*MemberKey, error) {
+	b, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid member key: %v", err)
+	}
+
+	return newMemberKey(b)
+}
+
+// ParseMemberPublicKey decodes a base64 encoded public key.
+func ParseMemberPublicKey(str string) (*MemberPublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid member public key: %v", err)
+	}
+
+	return newMemberPublicKey(b)
+}
+
+func newMemberKey(b []byte) (*MemberKey, error) {
+	var k MemberKey
+	if len(b) != len(k) {
+		return nil, errors.New("invalid member key length")
+	}
+
+	copy(k[:], b)
+	return &k, nil
+}
+
+func newMemberPublicKey(b []byte) (*MemberPublicKey, error) {
+	var pub MemberPublicKey
+	if len(b) != len(pub) {
+		return nil, errors.New("invalid member public key length")
+	}
+
+	copy(pub[:], b)
+	return &pub, nil
+}
+
+// PublicKey returns the public key of k.
+func (k *MemberKey) PublicKey() *MemberPublicKey {
+	var pub MemberPublicKey
+	curve25519.ScalarBaseMult((*[32]byte)(&pub), (*[32]byte)(k))
+	return &pub
+}
+
+// Bytes returns a copy of k.
+func (k *MemberKey) Bytes() []byte {
+	return append([]byte{}, k[:]...)
+}
+
+// ECDH returns the secret shared by k and pub. Low order public keys, which
+// give an all zero secret, are refused.
+func (k *MemberKey) ECDH(pub *MemberPublicKey) ([]byte, error) {
+	var shared, zero [32]byte
+	curve25519.ScalarMult(&shared, (*[32]byte)(k), (*[32]byte)(pub))
+	if subtle.ConstantTimeCompare(shared[:], zero[:]) == 1 {
+		return nil, errors.New("invalid member public key")
+	}
+
+	return shared[:], nil
+}
+
+// Bytes returns a copy of pub.
+func (pub *MemberPublicKey) Bytes() []byte {
+	return append([]byte{}, pub[:]...)
+}
+
+// Equal reports whether pub and other are the same key.
+func (pub *MemberPublicKey) Equal(other *MemberPublicKey) bool {
+	return subtle.ConstantTimeCompare(pub[:], other[:]) == 1
+}
+
+// NewKeyMember returns a member unlocking the vault with the private key of pub.
+func NewKeyMember(name string, pub *MemberPublicKey) (*Member, error) {
+	if name == "" {
+		return nil, errors.New("member name should not be empty")
+	}
+
+	return &Member{Name: name, PublicKey: pub}, nil
+}
+
+// NewPasswordMember returns a member unlocking the vault with password.
+func NewPasswordMember(name string, password []byte) (*Member, *MemberKey, error) {
+	if len(password) == 0 {
+		return nil, nil, errors.New("password should not be empty")
+	}
+
+	private, err := GenerateMemberKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := NewKeyMember(name, private.PublicKey())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.setPassword(private, password); err != nil {
+		return nil, nil, err
+	}
+
+	return m, private, nil
+}
+
+func (m *Member) setPassword(private *MemberKey, password []byte) error {
+	kdf, err := NewKDF()
+	if err != nil {
+		return err
+	}
+
+	key, err := kdf.DeriveKey(password)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := encryptWithAD(key, private.Bytes(), []byte(m.Name))
+	if err != nil {
+		return err
+	}
+
+	m.KDF = &kdf
+	m.private = encrypted
+	return nil
+}
+
+// Vault returns the vault of the db, nil if it is not a team vault.
+func (a *Alfred) Vault() *Vault { return a.vault }
+
+// Member returns the member the vault was opened as, nil if it is locked.
+func (v *Vault) Member() *Member { return v.member }
+
+// MemberByName returns the member called name, or nil.
+func (v *Vault) MemberByName(name string) *Member {
+	for _, m := range v.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// InitVault turns the db into a team vault, granting every wallet to
+// member, which the vault is then opened as. Mnemonic roots cannot be kept
+// in a vault since they give access to every wallet derived from them.
+func (a *Alfred) InitVault(member *Member, private *MemberKey) error {
+	switch {
+	case a.vault != nil:
+		return errors.New("db is already a team vault")
+	case !a.IsUnlocked():
+		return errors.New("you should unlock alfred to turn it into a team vault")
+	case len(a.Stellar.Roots) > 0:
+		return errors.New("mnemonic roots cannot be kept in a team vault, they give access to every wallet derived from them")
+	case a.sealed:
+		return errors.New("sealed dbs cannot be turned into a team vault, unseal it first")
+	case a.decoy != nil:
+		return errors.New("dbs with a decoy set cannot be turned into a team vault")
+	}
+
+	for _, w := range a.Stellar.Wallets {
+		if w.HasPassword() {
+			return fmt.Errorf("wallet '%s' has a password of its own, remove it first", w.Name)
+		}
+		if _, ok := w.Full(); !ok && w.hasSeed() {
+			return fmt.Errorf("wallet '%s' is not decrypted", w.Name)
+		}
+	}
+
+	key, err := randomKey()
+	if err != nil {
+		return err
+	}
+
+	v := &Vault{member: member, private: private}
+	if err := v.addMember(member, key); err != nil {
+		return err
+	}
+
+	for _, w := range a.Stellar.Wallets {
+		if w.hasSeed() {
+			if err := v.newWalletKey(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	wipe(a.password)
+	wipeKey(a.secret)
+	a.password, a.agent = nil, nil
+	a.secret = secretKey(key)
+	a.kdf = KDF{}
+	a.vault = v
+	return nil
+}
+
+// AddMember adds member to the vault, without granting any wallet.
+func (a *Alfred) AddMember(member *Member) error {
+	v, err := a.unlockedVault()
+	if err != nil {
+		return err
+	}
+
+	if v.MemberByName(member.Name) != nil {
+		return fmt.Errorf("member '%s' already exists", member.Name)
+	}
+
+	return v.addMember(member, a.secret.(secretKey))
+}
+
+// RemoveMember revokes every wallet granted to name and removes it from the
+// vault. The key of the db is renewed, so that name cannot read the vault
+// anymore. It fails if name was granted wallets the current member cannot
+// access.
+func (a *Alfred) RemoveMember(name string) error {
+	v, err := a.unlockedVault()
+	if err != nil {
+		return err
+	}
+
+	m := v.MemberByName(name)
+	switch {
+	case m == nil:
+		return fmt.Errorf("member '%s' not found", name)
+	case m == v.member:
+		return errors.New("you cannot remove yourself from the vault")
+	}
+
+	var granted []*Wallet
+	for _, w := range a.Stellar.Wallets {
+		if w.vault == nil || w.vault.access[name] == nil {
+			continue
+		}
+
+		if w.vault.key == nil {
+			return fmt.Errorf("member '%s' has access to wallet '%s' which you cannot revoke", name, w.Name)
+		}
+		granted = append(granted, w)
+	}
+
+	for _, w := range granted {
+		if err := a.Revoke(name, w); err != nil {
+			return err
+		}
+	}
+
+	key, err := randomKey()
+	if err != nil {
+		return err
+	}
+
+	var members []*Member
+	for _, other := range v.Members {
+		if other == m {
+			continue
+		}
+
+		if other.key, err = wrapKey(other.PublicKey, key, vaultKeyAD); err != nil {
+			return err
+		}
+		members = append(members, other)
+	}
+
+	v.Members = members
+	wipeKey(a.secret)
+	a.secret = secretKey(key)
+	return nil
+}
+
+// Grant gives member name access to the seed of w.
+func (a *Alfred) Grant(name string, w *Wallet) error {
+	v, err := a.unlockedVault()
+	if err != nil {
+		return err
+	}
+
+	m := v.MemberByName(name)
+	if m == nil {
+		return fmt.Errorf("member '%s' not found", name)
+	}
+
+	if err := v.checkAccess(w); err != nil {
+		return err
+	}
+
+	wrapped, err := wrapKey(m.PublicKey, w.vault.key, []byte(w.Keypair.Address()))
+	if err != nil {
+		return err
+	}
+
+	w.vault.access[name] = wrapped
+	return nil
+}
+
+// Revoke removes the access of member name to the seed of w. The seed is
+// encrypted with a new key, wrapped for the remaining members, so that name
+// cannot decrypt it anymore. Note that name may have kept the seed itself,
+// the only way to be sure is to move the funds to another account.
+func (a *Alfred) Revoke(name string, w *Wallet) error {
+	v, err := a.unlockedVault()
+	if err != nil {
+		return err
+	}
+
+	if err := v.checkAccess(w); err != nil {
+		return err
+	}
+
+	if w.vault.access[name] == nil {
+		return fmt.Errorf("member '%s' has no access to wallet '%s'", name, w.Name)
+	}
+
+	if len(w.vault.access) == 1 {
+		return fmt.Errorf("member '%s' is the last one having access to wallet '%s'", name, w.Name)
+	}
+
+	var granted []*Member
+	for other := range w.vault.access {
+		if other == name {
+			continue
+		}
+
+		m := v.MemberByName(other)
+		if m == nil {
+			// member removed by hand from the file
+			continue
+		}
+		granted = append(granted, m)
+	}
+
+	return v.newWalletKey(w, granted...)
+}
+
+// Access returns the names of the members granted w, sorted.
+func (w *Wallet) Access() []string {
+	if w.vault == nil {
+		return nil
+	}
+
+	var names []string
+	for name := range w.vault.access {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (a *Alfred) unlockedVault() (*Vault, error) {
+	switch {
+	case a.vault == nil:
+		return nil, errors.New("db is not a team vault")
+	case a.vault.member == nil || !a.IsUnlocked():
+		return nil, errors.New("you should unlock the vault as one of its members")
+	}
+
+	return a.vault, nil
+}
+
+func (v *Vault) checkAccess(w *Wallet) error {
+	switch {
+	case !w.hasSeed():
+		return fmt.Errorf("wallet '%s' has no seed to share", w.Name)
+	case w.vault == nil:
+		// added since the vault was opened
+		return v.newWalletKey(w)
+	case w.vault.key == nil:
+		return fmt.Errorf("you have no access to wallet '%s'", w.Name)
+	}
+
+	return nil
+}
+
+func (v *Vault) addMember(m *Member, key []byte) error {
+	wrapped, err := wrapKey(m.PublicKey, key, vaultKeyAD)
+	if err != nil {
+		return err
+	}
+
+	m.key = wrapped
+	v.Members = append(v.Members, m)
+	return nil
+}
+
+// newWalletKey encrypts the seed of w with a new key, wrapped for members,
+// or for the current member if none is given.
+func (v *Vault) newWalletKey(w *Wallet, members ...*Member) error {
+	kp, ok := w.Full()
+	if !ok {
+		return fmt.Errorf("wallet '%s' is not decrypted", w.Name)
+	}
+
+	if len(members) == 0 {
+		members = []*Member{v.member}
+	}
+
+	key, err := randomKey()
+	if err != nil {
+		return err
+	}
+
+	address := []byte(kp.Address())
+	seed, err := encryptWithAD(key, getSeed(kp.Seed()), address)
+	if err != nil {
+		return err
+	}
+
+	wk := &walletKey{key: key, seed: seed, access: map[string][]byte{}}
+	for _, m := range members {
+		if wk.access[m.Name], err = wrapKey(m.PublicKey, key, address); err != nil {
+			return err
+		}
+	}
+
+	w.vault = wk
+	return nil
+}
+
+// unlock unwraps the key of the db for member, using password if the member
+// has one and its private key otherwise.
+func (v *Vault) unlock(password []byte, member string, memberKey *MemberKey) ([]byte, error) {
+	if member == "" {
+		if password != nil || memberKey != nil {
+			return nil, errors.New("db is a team vault, the member to open it as should be set")
+		}
+		return nil, nil
+	}
+
+	m := v.MemberByName(member)
+	if m == nil {
+		return nil, fmt.Errorf("no member named '%s' in the vault", member)
+	}
+
+	private := memberKey
+	if m.HasPassword() {
+		if password == nil {
+			return nil, nil
+		}
+
+		key, err := m.KDF.DeriveKey(password)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := decryptWithAD(key, m.private, []byte(m.Name))
+		if err != nil {
+			return nil, fmt.Errorf("password of member '%s' is incorrect", m.Name)
+		}
+
+		if private, err = newMemberKey(b); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case private == nil:
+		return nil, nil
+	case !private.PublicKey().Equal(m.PublicKey):
+		return nil, fmt.Errorf("key does not belong to member '%s'", m.Name)
+	}
+
+	key, err := unwrapKey(private, m.key, vaultKeyAD)
+	if err != nil {
+		return nil, fmt.Errorf("key of member '%s' could not be unwrapped", m.Name)
+	}
+
+	v.member = m
+	v.private = private
+	return key, nil
+}
+
+// changePassword re-encrypts the private key of the current member.
+func (v *Vault) changePassword(password []byte) error {
+	switch {
+	case v.member == nil:
+		return errors.New("you should unlock the vault to change your password")
+	case !v.member.HasPassword():
+		return fmt.Errorf("member '%s' unlocks the vault with a key, not a password", v.member.Name)
+	}
+
+	return v.member.setPassword(v.private, password)
+}
+
+func (v *Vault) lock() {
+	v.member, v.private = nil, nil
+}
+
+// openWallet decrypts the seed of w if the current member was granted it.
+func (v *Vault) openWallet(w *Wallet, j walletyaml) error {
+	wk := &walletKey{access: map[string][]byte{}}
+	for name, str := range j.Access {
+		wrapped, err := base64.RawStdEncoding.DecodeString(str)
+		if err != nil {
+			return fmt.Errorf("wallet %s: %v", j.Name, err)
+		}
+		wk.access[name] = wrapped
+	}
+
+	seed, err := base64.RawStdEncoding.DecodeString(j.Seed)
+	if err != nil {
+		return fmt.Errorf("wallet %s: %v", j.Name, err)
+	}
+	wk.seed = seed
+	w.vault = wk
+
+	if v.member == nil || wk.access[v.member.Name] == nil {
+		return nil
+	}
+
+	address := []byte(j.Address)
+	key, err := unwrapKey(v.private, wk.access[v.member.Name], address)
+	if err != nil {
+		return fmt.Errorf("wallet %s: key could not be unwrapped", j.Name)
+	}
+
+	raw, err := decryptWithAD(key, seed, address)
+	if err != nil {
+		return fmt.Errorf("wallet %s: seed could not be decrypted", j.Name)
+	}
+
+	var seed32 [32]byte
+	copy(seed32[:], raw)
+	kp, err := keypair.FromRawSeed(seed32)
+	if err != nil {
+		return err
+	}
+
+	if kp.Address() != j.Address {
+		return fmt.Errorf("wallet %s: address mismatch", j.Name)
+	}
+
+	wk.key = key
+	w.Keypair = kp
+	return nil
+}
+
+// sealWallet sets the encrypted seed and the access of w in j, encrypting
+// the seed of wallets added since the vault was opened.
+func (v *Vault) sealWallet(w *Wallet, j *walletyaml) error {
+	if w.vault == nil {
+		if err := v.newWalletKey(w); err != nil {
+			return err
+		}
+	}
+
+	j.Seed = base64.RawStdEncoding.EncodeToString(w.vault.seed)
+	j.Access = map[string]string{}
+	for name, wrapped := range w.vault.access {
+		j.Access[name] = base64.RawStdEncoding.EncodeToString(wrapped)
+	}
+
+	return nil
+}
+
+func (v *Vault) yaml() *vaultyaml {
+	j := &vaultyaml{}
+	for _, m := range v.Members {
+		mj := memberyaml{
+			Name:      m.Name,
+			PublicKey: base64.StdEncoding.EncodeToString(m.PublicKey.Bytes()),
+			KDF:       m.KDF,
+			Key:       base64.RawStdEncoding.EncodeToString(m.key),
+		}
+		if m.private != nil {
+			mj.PrivateKey = base64.RawStdEncoding.EncodeToString(m.private)
+		}
+		j.Members = append(j.Members, mj)
+	}
+
+	return j
+}
+
+func (j *vaultyaml) vault() (*Vault, error) {
+	v := &Vault{}
+	for _, mj := range j.Members {
+		pub, err := ParseMemberPublicKey(mj.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("member %s: %v", mj.Name, err)
+		}
+
+		m := &Member{Name: mj.Name, PublicKey: pub, KDF: mj.KDF}
+		if m.key, err = base64.RawStdEncoding.DecodeString(mj.Key); err != nil {
+			return nil, fmt.Errorf("member %s: %v", mj.Name, err)
+		}
+		if mj.PrivateKey != "" {
+			if m.private, err = base64.RawStdEncoding.DecodeString(mj.PrivateKey); err != nil {
+				return nil, fmt.Errorf("member %s: %v", mj.Name, err)
+			}
+		}
+
+		v.Members = append(v.Members, m)
+	}
+
+	return v, nil
+}
+
+// wrapKey encrypts key for the owner of pub, using an ephemeral X25519 key
+// which is prepended to the result.
+func wrapKey(pub *MemberPublicKey, key, ad []byte) ([]byte, error) {
+	ephemeral, err := GenerateMemberKey()
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := ephemeral.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := wrappingKey(shared, ephemeral.PublicKey(), pub)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := encryptWithAD(kek, key, ad)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ephemeral.PublicKey().Bytes(), wrapped...), nil
+}
+
+func unwrapKey(private *MemberKey, wrapped, ad []byte) ([]byte, error) {
+	size := len(private.PublicKey().Bytes())
+	if len(wrapped) < size {
+		return nil, errors.New("wrapped key too short")
+	}
+
+	ephemeral, err := newMemberPublicKey(wrapped[:size])
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := private.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := wrappingKey(shared, ephemeral, private.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	return decryptWithAD(kek, wrapped[size:], ad)
+}
+
+// wrappingKey derives the key encrypting wrapped keys from the shared
+// secret, bound to both public keys.
+func wrappingKey(shared []byte, ephemeral, recipient *MemberPublicKey) ([]byte, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(vaultKeyInfo)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func randomKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
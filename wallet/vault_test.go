@@ -0,0 +1,104 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "alfred.yaml")
+	open := func(member string, key *MemberKey, secret []byte) *Alfred {
+		m, err := Open(path, secret, &Options{Member: member, MemberKey: key})
+		require.NoError(t, err)
+		return m
+	}
+
+	m, err := Open(path, []byte("secret"), nil)
+	require.NoError(t, err)
+
+	payroll, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("payroll", payroll)))
+	treasury, err := keypair.Random()
+	require.NoError(t, err)
+	require.NoError(t, m.AddWallet(New("treasury", treasury)))
+
+	alice, private, err := NewPasswordMember("alice", []byte("alice"))
+	require.NoError(t, err)
+	require.NoError(t, m.InitVault(alice, private))
+	require.NoError(t, Write(path, m))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "kdf:\n  name")
+
+	// anyone can open the vault, without the seeds
+	m = open("", nil, nil)
+	require.Len(t, m.Stellar.Wallets, 2)
+	_, ok := m.WalletByName("payroll").Full()
+	require.False(t, ok)
+
+	_, err = Open(path, []byte("secret"), nil)
+	require.Error(t, err) // member not set
+	_, err = Open(path, []byte("secret"), &Options{Member: "alice"})
+	require.Error(t, err)
+
+	m = open("alice", nil, []byte("alice"))
+	_, ok = m.WalletByName("treasury").Full()
+	require.True(t, ok)
+
+	bobKey, err := GenerateMemberKey()
+	require.NoError(t, err)
+	bob, err := NewKeyMember("bob", bobKey.PublicKey())
+	require.NoError(t, err)
+	require.NoError(t, m.AddMember(bob))
+	require.Error(t, m.AddMember(bob))
+	require.Error(t, m.Grant("carol", m.WalletByName("payroll")))
+	require.NoError(t, m.Grant("bob", m.WalletByName("payroll")))
+	require.Equal(t, []string{"alice", "bob"}, m.WalletByName("payroll").Access())
+	require.NoError(t, Write(path, m))
+
+	// bob only decrypts what he was granted, and keeps the rest intact
+	m = open("bob", bobKey, nil)
+	_, ok = m.WalletByName("payroll").Full()
+	require.True(t, ok)
+	_, ok = m.WalletByName("treasury").Full()
+	require.False(t, ok)
+	require.Error(t, m.Grant("bob", m.WalletByName("treasury")))
+	require.Error(t, m.RemoveMember("bob"))
+	require.NoError(t, m.RenameWallet(treasury.Address(), "reserve"))
+	require.NoError(t, Write(path, m))
+
+	m = open("alice", nil, []byte("alice"))
+	kp, ok := m.WalletByName("reserve").Full()
+	require.True(t, ok)
+	require.Equal(t, treasury.Seed(), kp.Seed())
+
+	require.Error(t, m.Revoke("bob", m.WalletByName("reserve")))
+	require.NoError(t, m.Revoke("bob", m.WalletByName("payroll")))
+	require.Error(t, m.Revoke("alice", m.WalletByName("payroll"))) // last one
+	require.NoError(t, m.ChangePassword([]byte("new alice")))
+	require.NoError(t, Write(path, m))
+
+	m = open("bob", bobKey, nil)
+	_, ok = m.WalletByName("payroll").Full()
+	require.False(t, ok)
+
+	m = open("alice", nil, []byte("new alice"))
+	require.NoError(t, m.RemoveMember("bob"))
+	require.Error(t, m.RemoveMember("alice"))
+	require.Len(t, m.Vault().Members, 1)
+	require.NoError(t, Write(path, m))
+
+	_, err = Open(path, nil, &Options{Member: "bob", MemberKey: bobKey})
+	require.Error(t, err)
+}
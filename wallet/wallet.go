@@ -11,6 +11,25 @@ import (
 type Wallet struct {
 	Name    string
 	Keypair keypair.KP
+	// WatchOnly wallets are stored without a seed, transactions from
+	// them have to be signed elsewhere.
+	WatchOnly bool
+	// Derivation is set for wallets derived from a mnemonic.
+	Derivation *Derivation
+	// RemoteSigner is the url of the signer holding the seed of the
+	// wallet, which is then not stored.
+	RemoteSigner string
+	Tags         Tags
+	Notes        string
+	// Network the wallet is bound to, nil if it was created before
+	// wallets were bound to a network.
+	Network *Network
+
+	// vault is set for the wallets of a team vault.
+	vault *walletKey
+	// seed is set until the seed is decrypted, see Full.
+	seed     *lockedSeed
+	password *walletPassword
 }
 
 func (w *Wallet) String() string {
@@ -18,9 +37,48 @@ func (w *Wallet) String() string {
 	if w.Name != w.Keypair.Address() {
 		str = fmt.Sprintf("%s (%s)", w.Name, str)
 	}
+	switch {
+	case w.WatchOnly:
+		str += " [watch-only]"
+	case w.RemoteSigner != "":
+		str += " [remote]"
+	}
 	return str
 }
 
+// NewRemote returns a wallet whose transactions are signed by the remote
+// signer at url, which should be an https:// url or a unix:// socket.
+func NewRemote(name, address, url string) (*Wallet, error) {
+	if err := CheckSignerURL(url); err != nil {
+		return nil, err
+	}
+
+	w, err := NewWatchOnly(name, address)
+	if err != nil {
+		return nil, err
+	}
+
+	w.WatchOnly = false
+	w.RemoteSigner = url
+	return w, nil
+}
+
+// hasSeed reports whether the seed of w is stored in the file.
+func (w *Wallet) hasSeed() bool {
+	return !w.WatchOnly && w.RemoteSigner == ""
+}
+
+// Full returns the keypair of the wallet if its seed is available. The seed
+// is decrypted on first use, SeedError tells why it is not available.
+func (w *Wallet) Full() (*keypair.Full, bool) {
+	if w.seed != nil && w.password == nil {
+		w.openSeed(nil)
+	}
+
+	kp, ok := w.Keypair.(*keypair.Full)
+	return kp, ok
+}
+
 func New(name string, keypair *keypair.Full) *Wallet {
 	if name == "" {
 		name = keypair.Address()
@@ -31,8 +89,43 @@ func New(name string, keypair *keypair.Full) *Wallet {
 	}
 }
 
-func (w *Wallet) Balances(testnet bool) ([]horizon.Balance, error) {
-	client := getClient(testnet)
+// NewWatchOnly returns a wallet tracking address without knowing its seed.
+func NewWatchOnly(name string, address string) (*Wallet, error) {
+	kp, err := keypair.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = kp.Address()
+	}
+
+	return &Wallet{
+		Name:      name,
+		Keypair:   keypair.MustParse(kp.Address()),
+		WatchOnly: true,
+	}, nil
+}
+
+// CheckNetwork returns an error if w is bound to a network other than n.
+func (w *Wallet) CheckNetwork(n Network) error {
+	if w.Network != nil && !w.Network.Equal(n) {
+		return fmt.Errorf("wallet %s belongs to the %s network, not %s", w, w.Network, n)
+	}
+
+	return nil
+}
+
+func (w *Wallet) networkYAML() *Network {
+	if w.Network == nil {
+		return nil
+	}
+
+	return w.Network.toYAML()
+}
+
+func (w *Wallet) Balances(n Network) ([]horizon.Balance, error) {
+	client := n.Client()
 	account, _, err := getAccount(client, w.Keypair.Address())
 	if err != nil {
 		return nil, err
@@ -61,12 +154,3 @@ func getAccount(client *horizon.Client, account string) (horizon.Account, bool,
 
 	return hAccount, true, nil
 }
-
-func getClient(testnet bool) *horizon.Client {
-	client := horizon.DefaultPublicNetClient
-	if testnet {
-		client = horizon.DefaultTestNetClient
-	}
-
-	return client
-}